@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+)
+
+const (
+	defaultPort            = "8080"
+	defaultReadTimeout     = 10 * time.Second
+	defaultWriteTimeout    = 30 * time.Second
+	defaultIdleTimeout     = time.Minute
+	defaultShutdownTimeout = 20 * time.Second
+	defaultLowStockCheck   = 5 * time.Minute
+	defaultPurgeInterval   = time.Hour
+	defaultPurgeRetention  = 30 * 24 * time.Hour
+	defaultScheduleCheck   = time.Minute
+	defaultExpiryCheck     = time.Minute
+	defaultOrderExpiry     = 30 * time.Minute
+)
+
+// Supported values of the --store flag.
+const (
+	storePostgres = "postgres"
+	storeMemory   = "memory"
+)
+
+// Config holds the runtime settings of the API server.
+type Config struct {
+	Port            string
+	Store           string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	ErrorFormat     helpers.ErrorFormat
+
+	// LowStockCheck is the interval of the low-stock checker; zero disables
+	// it. Alerts are always logged and also sent to AlertWebhookURL and
+	// appended to AlertOutbox when those are set.
+	LowStockCheck   time.Duration
+	AlertWebhookURL string
+	AlertOutbox     string
+
+	// PurgeInterval is the interval of the job removing coffees deleted more
+	// than PurgeRetention ago; zero for either disables it.
+	PurgeInterval  time.Duration
+	PurgeRetention time.Duration
+
+	// ScheduleCheck is the interval of the job logging price schedules as
+	// they start and end; zero disables it. Scheduled prices apply either way.
+	ScheduleCheck time.Duration
+
+	// TaxRate is the sales tax rate charged on orders, as a fraction.
+	TaxRate *big.Rat
+
+	// ExpiryCheck is the interval of the job cancelling orders left pending
+	// for longer than OrderExpiry, releasing their stock; zero for either
+	// disables it and pending orders hold their stock until cancelled.
+	ExpiryCheck time.Duration
+	OrderExpiry time.Duration
+}
+
+// loadConfig reads the configuration from the environment. Timeouts accept any
+// time.ParseDuration value, e.g. READ_TIMEOUT=5s or SHUTDOWN_TIMEOUT=1m.
+// ERROR_FORMAT=problem makes RFC 7807 problem details the default error body.
+// LOW_STOCK_CHECK=0 turns the low-stock checker off, PURGE_INTERVAL=0 keeps
+// deleted coffees forever and SCHEDULE_CHECK=0 stops logging price schedule
+// transitions. TAX_RATE=0.19 charges 19% sales tax on orders and
+// ORDER_EXPIRY=1h cancels orders still pending an hour after they were placed.
+func loadConfig() Config {
+	return Config{
+		Port:            stringEnv("PORT", defaultPort),
+		Store:           storePostgres,
+		ReadTimeout:     durationEnv("READ_TIMEOUT", defaultReadTimeout),
+		WriteTimeout:    durationEnv("WRITE_TIMEOUT", defaultWriteTimeout),
+		IdleTimeout:     durationEnv("IDLE_TIMEOUT", defaultIdleTimeout),
+		ShutdownTimeout: durationEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		ErrorFormat:     errorFormatEnv("ERROR_FORMAT"),
+		LowStockCheck:   durationEnv("LOW_STOCK_CHECK", defaultLowStockCheck),
+		AlertWebhookURL: os.Getenv("ALERT_WEBHOOK_URL"),
+		AlertOutbox:     os.Getenv("ALERT_OUTBOX"),
+		PurgeInterval:   durationEnv("PURGE_INTERVAL", defaultPurgeInterval),
+		PurgeRetention:  durationEnv("PURGE_RETENTION", defaultPurgeRetention),
+		ScheduleCheck:   durationEnv("SCHEDULE_CHECK", defaultScheduleCheck),
+		TaxRate:         taxRateEnv("TAX_RATE"),
+		ExpiryCheck:     durationEnv("EXPIRY_CHECK", defaultExpiryCheck),
+		OrderExpiry:     durationEnv("ORDER_EXPIRY", defaultOrderExpiry),
+	}
+}
+
+// stringEnv returns the value stored in key, or def when it is unset.
+func stringEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// durationEnv parses the duration stored in key, falling back to def when the
+// variable is unset or malformed.
+func durationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Server: invalid %s %q, using %s", key, value, def)
+		return def
+	}
+
+	return d
+}
+
+// taxRateEnv parses the tax rate stored in key, falling back to no tax when
+// the variable is unset or malformed.
+func taxRateEnv(key string) *big.Rat {
+	value := os.Getenv(key)
+	if value == "" {
+		return new(big.Rat)
+	}
+
+	rate, err := services.ParseTaxRate(value)
+	if err != nil {
+		log.Printf("Server: invalid %s: %v, charging no tax", key, err)
+		return new(big.Rat)
+	}
+
+	return rate
+}
+
+// errorFormatEnv parses the error format stored in key, falling back to the
+// legacy envelope when the variable is unset or malformed.
+func errorFormatEnv(key string) helpers.ErrorFormat {
+	format, err := helpers.ParseErrorFormat(os.Getenv(key))
+	if err != nil {
+		log.Printf("Server: invalid %s: %v, using the JSON envelope", key, err)
+	}
+
+	return format
+}
@@ -1,72 +1,247 @@
-package server
+package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/davidandw190/coffeeshop-api-go/db"
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
 	"github.com/joho/godotenv"
 )
 
-type Config struct {
-	Port string
-}
-
 type Application struct {
 	Config Config
-	// Model 1
-	// ...
-	// Model n
+	Models services.Models
 }
 
+// Serve runs the HTTP server until SIGINT or SIGTERM is received, then stops
+// accepting connections and waits up to Config.ShutdownTimeout for in-flight
+// requests to finish.
 func (app *Application) Serve() error {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("error loading .env file")
+	s := &http.Server{
+		Addr:         fmt.Sprintf(":%s", app.Config.Port),
+		Handler:      app.Routes(),
+		ReadTimeout:  app.Config.ReadTimeout,
+		WriteTimeout: app.Config.WriteTimeout,
+		IdleTimeout:  app.Config.IdleTimeout,
 	}
 
-	port := os.Getenv("PORT")
-	log.Println("Server: API listening on port", port)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	s := &http.Server{
-		Addr: fmt.Sprintf(":%s", port),
-		// TODO: add router
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Println("Server: API listening on port", app.Config.Port)
+		serveErr <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
 	}
 
-	return s.ListenAndServe()
+	// A second signal falls back to the default behaviour and kills the process.
+	stop()
+	log.Println("Server: shutting down, draining in-flight requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Config.ShutdownTimeout)
+	defer cancel()
 
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	log.Println("Server: stopped")
+
+	return nil
 }
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
+	store := flag.String("store", storePostgres, "storage backend: postgres or memory")
+	ratesFile := flag.String("rates", "", "CSV file of base,quote,rate exchange rates to load at startup")
+	flag.Parse()
+
+	// The in-memory store needs no DSN, so a missing .env is only fatal for Postgres
+	if err := godotenv.Load(); err != nil && *store != storeMemory {
 		log.Fatal("error loading .env file")
 	}
 
 	// Load configuration settings
-	c := Config{
-		Port: os.Getenv("PORT"),
+	c := loadConfig()
+	c.Store = *store
+	helpers.DefaultErrorFormat = c.ErrorFormat
+
+	// Create the application instance
+	app := &Application{
+		Config: c,
 	}
 
-	// Connect to the database
-	dsn := os.Getenv("DSN")
-	dbConn, err := db.ConnectPostgres(dsn)
-	if err != nil {
-		log.Fatalf("Server: Connot connect to the database")
+	var dbConn *db.DB
+	switch c.Store {
+	case storeMemory:
+		log.Println("Server: using the in-memory store, data is lost on exit")
+		app.Models = services.NewMemory()
+	case storePostgres:
+		// Connect to the database
+		var err error
+		dbConn, err = db.ConnectPostgres(os.Getenv("DSN"))
+		if err != nil {
+			log.Fatalf("Server: Connot connect to the database")
+		}
+		app.Models = services.New(dbConn.DB)
+	default:
+		log.Fatalf("Server: unknown store %q, expected %s or %s", c.Store, storePostgres, storeMemory)
 	}
 
-	defer dbConn.DB.Close()
+	if *ratesFile != "" {
+		if err := loadRates(app.Models.Rates, *ratesFile); err != nil {
+			log.Fatalf("Server: loading exchange rates: %v", err)
+		}
+	}
 
-	// Create the application instance
-	app := &Application{
-		Config: c,
-		// TODO: add the models
+	// Background jobs stop before the pool is released
+	jobs, stopJobs := context.WithCancel(context.Background())
+	jobsDone := app.startJobs(jobs)
+
+	// Start the HTTP server and release the pool only once it has drained
+	serveErr := app.Serve()
+
+	stopJobs()
+	<-jobsDone
+
+	if dbConn != nil {
+		if err := dbConn.DB.Close(); err != nil {
+			log.Println("Server: closing the database:", err)
+		}
+	}
+
+	if serveErr != nil {
+		log.Fatal(serveErr)
+	}
+}
+
+// startJobs starts the background jobs enabled by the configuration. The
+// returned channel is closed once all of them have returned after ctx is done.
+func (app *Application) startJobs(ctx context.Context) <-chan struct{} {
+	var wg sync.WaitGroup
+
+	if app.Config.LowStockCheck > 0 {
+		checker := &services.StockChecker{
+			Reorder:  app.Models.Reorder,
+			Channels: app.alertChannels(),
+			Interval: app.Config.LowStockCheck,
+			Logger:   helpers.MessageLogs.ErrorLog,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Run(ctx)
+		}()
+	}
+
+	if app.Config.PurgeInterval > 0 && app.Config.PurgeRetention > 0 {
+		purger := &services.CoffeePurger{
+			Coffees:   app.Models.Coffees,
+			Retention: app.Config.PurgeRetention,
+			Interval:  app.Config.PurgeInterval,
+			Logger:    helpers.MessageLogs.ErrorLog,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			purger.Run(ctx)
+		}()
+	}
+
+	if app.Config.ScheduleCheck > 0 {
+		applier := &services.ScheduleApplier{
+			Schedules: app.Models.Schedules,
+			Interval:  app.Config.ScheduleCheck,
+			InfoLog:   helpers.MessageLogs.InfoLog,
+			ErrorLog:  helpers.MessageLogs.ErrorLog,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applier.Run(ctx)
+		}()
+	}
+
+	if app.Config.ExpiryCheck > 0 && app.Config.OrderExpiry > 0 {
+		expirer := &services.OrderExpirer{
+			Orders:   app.Models.Orders,
+			Expiry:   app.Config.OrderExpiry,
+			Interval: app.Config.ExpiryCheck,
+			Logger:   helpers.MessageLogs.ErrorLog,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expirer.Run(ctx)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	return done
+}
+
+// alertChannels builds the channels of low-stock alerts from the
+// configuration. The names are stored with the claims of each channel, see
+// the low_stock_alert_channels migration.
+func (app *Application) alertChannels() []services.AlertChannel {
+	channels := []services.AlertChannel{{Name: "log", Notifier: services.LogNotifier{Logger: helpers.MessageLogs.InfoLog}}}
+
+	if app.Config.AlertWebhookURL != "" {
+		channels = append(channels, services.AlertChannel{Name: "webhook", Notifier: services.NewWebhookNotifier(app.Config.AlertWebhookURL)})
 	}
+	if app.Config.AlertOutbox != "" {
+		channels = append(channels, services.AlertChannel{Name: "outbox", Notifier: &services.FileNotifier{Path: app.Config.AlertOutbox}})
+	}
+
+	return channels
+}
 
-	// Start the HTTP server
-	if err = app.Serve(); err != nil {
-		log.Fatal(err)
+// loadRates saves the exchange rates listed in the CSV file at path.
+func loadRates(rates services.RateRepository, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+
+	list, err := services.ReadExchangeRates(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := rates.Save(context.Background(), list); err != nil {
+		return err
+	}
+
+	log.Printf("Server: loaded %d exchange rates from %s", len(list), path)
+
+	return nil
 }
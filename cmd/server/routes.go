@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/davidandw190/coffeeshop-api-go/controllers"
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Routes builds the HTTP router with every API endpoint mounted under /api/v1.
+func (app *Application) Routes() http.Handler {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.RealIP)
+	router.Use(auditContext)
+	router.Use(middleware.Logger)
+	router.Use(middleware.Recoverer)
+
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		helpers.WriteError(w, r, errors.New("the requested resource could not be found"), http.StatusNotFound)
+	})
+	router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		helpers.WriteError(w, r, fmt.Errorf("the %s method is not supported for this resource", r.Method), http.StatusMethodNotAllowed)
+	})
+
+	coffees := controllers.NewCoffeeController(app.Models)
+	prices := controllers.NewPriceController(app.Models)
+	lookups := controllers.NewLookupController(app.Models)
+	origins := controllers.NewOriginController(app.Models)
+	variants := controllers.NewVariantController(app.Models)
+	inventory := controllers.NewInventoryController(app.Models)
+	audit := controllers.NewAuditController(app.Models)
+	orders := controllers.NewOrderController(app.Models, app.Config.TaxRate)
+
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Get("/coffees", coffees.GetAllCoffees)
+		r.Get("/coffees/search", coffees.SearchCoffees)
+		r.Get("/coffees/{id}", coffees.GetCoffeeByID)
+		r.Post("/coffees/coffee", coffees.CreateCoffee)
+		r.Put("/coffees/{id}", coffees.UpdateCoffee)
+		r.Patch("/coffees/{id}", coffees.PatchCoffee)
+		r.Delete("/coffees/{id}", coffees.DeleteCoffee)
+		r.Post("/coffees/{id}/restore", coffees.RestoreCoffee)
+		r.Get("/coffees/{id}/history", audit.GetCoffeeHistory)
+
+		r.Get("/coffees/{id}/prices", prices.GetPrices)
+		r.Put("/coffees/{id}/prices/{currency}", prices.SetPrice)
+		r.Delete("/coffees/{id}/prices/{currency}", prices.DeletePrice)
+		r.Get("/coffees/{id}/price-history", prices.GetPriceHistory)
+		r.Get("/coffees/{id}/price-schedules", prices.GetSchedules)
+		r.Post("/coffees/{id}/price-schedules", prices.CreateSchedule)
+		r.Delete("/coffees/{id}/price-schedules/{scheduleID}", prices.CancelSchedule)
+
+		r.Get("/coffees/{id}/variants", variants.GetVariants)
+		r.Post("/coffees/{id}/variants", variants.CreateVariant)
+		r.Get("/coffees/{id}/variants/{variantID}", variants.GetVariant)
+		r.Put("/coffees/{id}/variants/{variantID}", variants.UpdateVariant)
+		r.Delete("/coffees/{id}/variants/{variantID}", variants.DeleteVariant)
+
+		r.Get("/coffees/{id}/stock", inventory.GetStock)
+		r.Get("/coffees/{id}/stock/adjustments", inventory.GetAdjustments)
+		r.Post("/coffees/{id}/stock/adjustments", inventory.AdjustStock)
+		r.Get("/coffees/{id}/stock/threshold", inventory.GetThreshold)
+		r.Put("/coffees/{id}/stock/threshold", inventory.SetThreshold)
+		r.Delete("/coffees/{id}/stock/threshold", inventory.DeleteThreshold)
+
+		r.Post("/inventory/reservations", inventory.CreateReservation)
+		r.Get("/inventory/reservations/{id}", inventory.GetReservation)
+		r.Post("/inventory/reservations/{id}/release", inventory.ReleaseReservation)
+		r.Post("/inventory/reservations/{id}/commit", inventory.CommitReservation)
+
+		r.Post("/orders", orders.CreateOrder)
+		r.Get("/orders/{id}", orders.GetOrder)
+		r.Post("/orders/{id}/transitions", orders.TransitionOrder)
+
+		r.Get("/origins", origins.GetAllOrigins)
+		r.Post("/origins", origins.CreateOrigin)
+		r.Get("/origins/{id}", origins.GetOriginByID)
+		r.Put("/origins/{id}", origins.UpdateOrigin)
+		r.Delete("/origins/{id}", origins.DeleteOrigin)
+		r.Get("/origins/{id}/coffees", coffees.GetOriginCoffees)
+
+		r.Get("/audit", audit.GetAudit)
+
+		r.Get("/roasts", lookups.GetRoasts)
+		r.Get("/grinds", lookups.GetGrinds)
+
+		r.Get("/admin/coffees", coffees.GetAdminCoffees)
+		r.Get("/admin/exchange-rates", prices.ListRates)
+		r.Put("/admin/exchange-rates", prices.SaveRates)
+		r.Delete("/admin/exchange-rates/{base}/{quote}", prices.DeleteRate)
+		r.Get("/admin/low-stock", inventory.GetLowStock)
+	})
+
+	return router
+}
+
+// anonymousActor is recorded for requests that do not name their actor.
+const anonymousActor = "anonymous"
+
+// auditContext attributes the mutations made by a request to the actor named
+// in its X-Actor header, set by the gateway that authenticates staff, and to
+// its request ID.
+func auditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+		if actor == "" {
+			actor = anonymousActor
+		}
+
+		ctx := services.WithAuditInfo(r.Context(), services.AuditInfo{Actor: actor, RequestID: middleware.GetReqID(r.Context())})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
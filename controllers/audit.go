@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// AuditController serves the audit trail from an AuditRepository.
+type AuditController struct {
+	Audit services.AuditRepository
+}
+
+// NewAuditController creates a controller backed by the given models.
+func NewAuditController(models services.Models) *AuditController {
+	return &AuditController{Audit: models.Audit}
+}
+
+// GET/coffees/{id}/history
+func (c *AuditController) GetCoffeeHistory(w http.ResponseWriter, r *http.Request) {
+	filter, err := readAuditFilter(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	// Deleted and purged coffees keep their history, so the coffee itself
+	// is not looked up.
+	filter.Entity = services.EntityCoffee
+	filter.EntityID = chi.URLParam(r, "id")
+
+	entries, err := c.Audit.List(r.Context(), filter)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"history": entries})
+}
+
+// GET/audit
+func (c *AuditController) GetAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := readAuditFilter(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	filter.Actor = r.URL.Query().Get("actor")
+	filter.Entity = r.URL.Query().Get("entity")
+
+	entries, err := c.Audit.List(r.Context(), filter)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"audit": entries})
+}
+
+// readAuditFilter reads the time range and page of an audit query.
+func readAuditFilter(r *http.Request) (services.AuditFilter, error) {
+	qs := r.URL.Query()
+
+	var filter services.AuditFilter
+	var err error
+
+	if filter.Limit, err = helpers.ReadInt(qs, "limit", services.DefaultAuditLimit); err != nil {
+		return filter, err
+	}
+	if filter.Limit < 1 || filter.Limit > services.MaxAuditLimit {
+		return filter, fmt.Errorf("limit must be between 1 and %d", services.MaxAuditLimit)
+	}
+
+	if filter.Offset, err = helpers.ReadInt(qs, "offset", 0); err != nil {
+		return filter, err
+	}
+	if filter.Offset < 0 {
+		return filter, errors.New("offset must not be negative")
+	}
+
+	if filter.Since, err = helpers.ReadTime(qs, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = helpers.ReadTime(qs, "until"); err != nil {
+		return filter, err
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return filter, errors.New("since must be before until")
+	}
+
+	return filter, nil
+}
@@ -1,40 +1,542 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/davidandw190/coffeeshop-api-go/helpers"
 	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
 )
 
-var coffee services.Coffee
+// CoffeeController serves the coffee endpoints from a CoffeeRepository.
+type CoffeeController struct {
+	Coffees   services.CoffeeRepository
+	Origins   services.OriginRepository
+	Variants  services.VariantRepository
+	Inventory services.InventoryRepository
+	Schedules services.ScheduleRepository
+	History   services.HistoryRepository
+	Lookups   services.LookupRepository
+	Pricing   services.Pricing
+}
+
+// NewCoffeeController creates a controller backed by the given models.
+func NewCoffeeController(models services.Models) *CoffeeController {
+	return &CoffeeController{Coffees: models.Coffees, Origins: models.Origins, Variants: models.Variants, Inventory: models.Inventory, Schedules: models.Schedules, History: models.History, Lookups: models.Lookups, Pricing: models.Pricing()}
+}
 
 // GET/coffees
-func GetAllCoffees(w http.ResponseWriter, r *http.Request) {
-	coffees, err := coffee.GetAllCoffees()
+func (c *CoffeeController) GetAllCoffees(w http.ResponseWriter, r *http.Request) {
+	c.listCoffees(w, r, services.ListOptions{})
+}
+
+// GET/admin/coffees
+func (c *CoffeeController) GetAdminCoffees(w http.ResponseWriter, r *http.Request) {
+	includeDeleted, err := helpers.ReadBool(r.URL.Query(), "include_deleted", false)
 	if err != nil {
-		helpers.MessageLogs.ErrorLog.Println(err)
+		badRequest(w, r, err)
+		return
+	}
+
+	c.listCoffees(w, r, services.ListOptions{IncludeDeleted: includeDeleted})
+}
+
+// GET/origins/{id}/coffees
+func (c *CoffeeController) GetOriginCoffees(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := c.Origins.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
 	}
 
-	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"coffees": coffees})
+	c.listCoffees(w, r, services.ListOptions{OriginID: id})
+}
+
+// listCoffees responds with one page of the coffees matching the query
+// string, within the scope set by the origin and deletion options of scope.
+func (c *CoffeeController) listCoffees(w http.ResponseWriter, r *http.Request, scope services.ListOptions) {
+	qs := r.URL.Query()
+
+	limit, err := helpers.ReadInt(qs, "limit", services.DefaultPageSize)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	offset, err := helpers.ReadInt(qs, "offset", 0)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if limit < 1 || limit > services.MaxPageSize {
+		badRequest(w, r, fmt.Errorf("limit must be between 1 and %d", services.MaxPageSize))
+		return
+	}
+
+	if offset < 0 {
+		badRequest(w, r, errors.New("offset must not be negative"))
+		return
+	}
+
+	// Price bounds only apply to coffees selling in price_currency, so that an
+	// amount is never compared across currencies. Bounds and sort=price use
+	// the scheduled price at as_of, which the listing shows as effective_price.
+	priceCurrency := strings.ToUpper(strings.TrimSpace(qs.Get("price_currency")))
+	if priceCurrency == "" {
+		priceCurrency = services.DefaultCurrency
+	}
+	if !services.IsCurrencyCode(priceCurrency) {
+		badRequest(w, r, errors.New("price_currency must be a three-letter ISO 4217 code"))
+		return
+	}
+
+	priceMin, err := helpers.ReadAmount(qs, "price_min", priceCurrency)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	priceMax, err := helpers.ReadAmount(qs, "price_max", priceCurrency)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	sortFields, err := services.ParseSort(qs.Get("sort"))
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	view, err := readCoffeeView(qs)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	inStock, err := helpers.ReadBool(qs, "in_stock", false)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if inStock && view.past {
+		badRequest(w, r, errors.New("in_stock cannot be combined with an as_of in the past"))
+		return
+	}
+
+	roasts := helpers.ReadCSV(qs, "roast")
+	for i := range roasts {
+		roasts[i] = services.NormalizeRoast(roasts[i])
+	}
+
+	opts := services.ListOptions{
+		Limit:    limit,
+		Offset:   offset,
+		Cursor:   qs.Get("cursor"),
+		Roasts:   roasts,
+		Regions:  helpers.ReadCSV(qs, "region"),
+		PriceMin: priceMin,
+		PriceMax: priceMax,
+		OriginID: scope.OriginID,
+		InStock:  inStock,
+		Sort:     sortFields,
+
+		PriceCurrency:  priceCurrency,
+		IncludeDeleted: scope.IncludeDeleted,
+	}
+
+	opts.PricedAt = view.asOf
+
+	var page *services.CoffeePage
+	if view.past {
+		page, err = c.History.List(r.Context(), view.asOf, opts)
+	} else {
+		page, err = c.Coffees.List(r.Context(), opts)
+	}
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if err := c.decorate(r.Context(), page.Coffees, view); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	var nextCursor interface{}
+	if page.NextCursor != "" {
+		nextCursor = page.NextCursor
+	}
+
+	headers := http.Header{}
+	if link := paginationLinks(r.URL, opts, page); link != "" {
+		headers.Set("Link", link)
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{
+		"coffees":     page.Coffees,
+		"total":       page.Total,
+		"next_cursor": nextCursor,
+	}, headers)
+}
+
+// coffeeView holds the query parameters shaping coffee representations:
+// ?currency= for a local price, ?expand= for embedded relations and ?as_of=
+// for the moment whose scheduled prices apply. An as_of in the past
+// reconstructs the catalog as it was then.
+type coffeeView struct {
+	currency     string
+	expandOrigin bool
+	asOf         time.Time
+	past         bool
+}
+
+// readCoffeeView reads the currency, an upper-case ISO 4217 code, the
+// relations to expand and the as_of time, now by default, from the query
+// string. List prices and exchange rates have no history, so a currency
+// cannot be combined with an as_of in the past.
+func readCoffeeView(qs url.Values) (coffeeView, error) {
+	var view coffeeView
+	var err error
+
+	if view.asOf, err = helpers.ReadTime(qs, "as_of"); err != nil {
+		return view, err
+	}
+	if now := time.Now(); view.asOf.IsZero() {
+		view.asOf = now
+	} else {
+		view.past = view.asOf.Before(now)
+	}
+
+	view.currency = strings.ToUpper(strings.TrimSpace(qs.Get("currency")))
+	if view.currency != "" && !services.IsCurrencyCode(view.currency) {
+		return view, errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	if view.currency != "" && view.past {
+		return view, errors.New("currency cannot be combined with an as_of in the past")
+	}
+
+	for _, relation := range helpers.ReadCSV(qs, "expand") {
+		switch relation {
+		case "origin":
+			view.expandOrigin = true
+		default:
+			return view, fmt.Errorf("cannot expand %q, expected origin", relation)
+		}
+	}
+
+	return view, nil
+}
+
+// decorate applies the price schedules in effect at view.asOf, attaches the
+// variants and stock of the coffees and fills in the parts requested by view.
+// Variants and stock are not historical and are left out of past views.
+func (c *CoffeeController) decorate(ctx context.Context, coffees []*services.Coffee, view coffeeView) error {
+	if err := services.ApplySchedules(ctx, c.Schedules, coffees, view.asOf); err != nil {
+		return err
+	}
+
+	if !view.past {
+		if err := services.AttachVariants(ctx, c.Variants, coffees); err != nil {
+			return err
+		}
+
+		if err := services.AttachStock(ctx, c.Inventory, coffees); err != nil {
+			return err
+		}
+	}
+
+	if view.currency != "" {
+		if err := c.Pricing.Quote(ctx, coffees, view.currency); err != nil {
+			return err
+		}
+	}
+
+	if view.expandOrigin {
+		if err := services.ExpandOrigins(ctx, c.Origins, coffees); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks the fields of coffee, then its roast and grind unit
+// against the lookup tables and its origin against the origins.
+func (c *CoffeeController) validate(ctx context.Context, coffee *services.Coffee) error {
+	errs, err := services.CheckCoffeeLookups(ctx, c.Lookups, coffee, coffee.Validate())
+	if err != nil {
+		return err
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	return c.checkOrigin(ctx, coffee)
+}
+
+// checkOrigin reports a field error when coffee names an origin that does
+// not exist.
+func (c *CoffeeController) checkOrigin(ctx context.Context, coffee *services.Coffee) error {
+	if coffee.OriginID == "" {
+		return nil
+	}
+
+	_, err := c.Origins.Get(ctx, coffee.OriginID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return services.ValidationErrors{"origin_id": "must be the id of an existing origin"}
+	}
+
+	return err
+}
+
+// paginationLinks builds the Link header of a listing page. Offset requests
+// and custom sorts get offset links, including prev; all other requests page
+// by cursor.
+func paginationLinks(requestURL *url.URL, opts services.ListOptions, page *services.CoffeePage) string {
+	pageURL := func(set map[string]string) string {
+		u := *requestURL
+		qs := u.Query()
+		qs.Del("cursor")
+		qs.Del("offset")
+		for key, value := range set {
+			qs.Set(key, value)
+		}
+		u.RawQuery = qs.Encode()
+		return u.RequestURI()
+	}
+
+	links := map[string]string{"first": pageURL(nil)}
+
+	if requestURL.Query().Has("offset") || len(opts.Sort) > 0 {
+		if opts.Offset+opts.Limit < page.Total {
+			links["next"] = pageURL(map[string]string{"offset": strconv.Itoa(opts.Offset + opts.Limit)})
+		}
+		if opts.Offset > 0 {
+			links["prev"] = pageURL(map[string]string{"offset": strconv.Itoa(max(opts.Offset-opts.Limit, 0))})
+		}
+	} else if page.NextCursor != "" {
+		links["next"] = pageURL(map[string]string{"cursor": page.NextCursor})
+	}
+
+	return helpers.LinkHeader(links, "first", "prev", "next")
+}
+
+// GET/coffees/search?q=
+func (c *CoffeeController) SearchCoffees(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	limit, err := helpers.ReadInt(qs, "limit", services.DefaultPageSize)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if limit < 1 || limit > services.MaxPageSize {
+		badRequest(w, r, fmt.Errorf("limit must be between 1 and %d", services.MaxPageSize))
+		return
+	}
+
+	results, err := c.Coffees.Search(r.Context(), qs.Get("q"), limit)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	// Results are priced like the listing, under the schedules now in effect.
+	coffees := make([]*services.Coffee, len(results))
+	for i, result := range results {
+		coffees[i] = result.Coffee
+	}
+	if err := services.ApplySchedules(r.Context(), c.Schedules, coffees, time.Now()); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"results": results})
 }
 
 // POST/coffees/coffee
-func CreateCoffee(w http.ResponseWriter, r *http.Request) {
+func (c *CoffeeController) CreateCoffee(w http.ResponseWriter, r *http.Request) {
+	var coffeeData services.Coffee
+	if err := helpers.ReadJSON(w, r, &coffeeData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if err := c.validate(r.Context(), &coffeeData); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	coffeeCreated, err := c.Coffees.Create(r.Context(), coffeeData)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{
+		"Location": []string{path.Join(path.Dir(r.URL.Path), coffeeCreated.ID)},
+		"ETag":     []string{helpers.ETag(coffeeCreated.UpdatedAt)},
+	}
+	helpers.WriteJSON(w, http.StatusCreated, coffeeCreated, headers)
+}
+
+// GET/coffees/{id}
+func (c *CoffeeController) GetCoffeeByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	view, err := readCoffeeView(r.URL.Query())
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	var coffeeFound *services.Coffee
+	if view.past {
+		coffeeFound, err = c.History.Get(r.Context(), id, view.asOf)
+	} else {
+		coffeeFound, err = c.Coffees.Get(r.Context(), id)
+	}
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if err := c.decorate(r.Context(), []*services.Coffee{coffeeFound}, view); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"ETag": []string{helpers.ETag(coffeeFound.UpdatedAt)}}
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"coffee": coffeeFound}, headers)
+}
+
+// PUT/coffees/{id}
+func (c *CoffeeController) UpdateCoffee(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	current, err := c.Coffees.Get(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
 	var coffeeData services.Coffee
-	err := json.NewDecoder(r.Body).Decode(&coffeeData)
+	if err := helpers.ReadJSON(w, r, &coffeeData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	c.saveCoffee(w, r, current, coffeeData)
+}
 
+// PATCH/coffees/{id}
+func (c *CoffeeController) PatchCoffee(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/merge-patch+json") && !strings.HasPrefix(contentType, "application/json") {
+		helpers.WriteError(w, r, errors.New("PATCH requires an application/merge-patch+json body"), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	current, err := c.Coffees.Get(r.Context(), id)
 	if err != nil {
-		helpers.MessageLogs.ErrorLog.Println(err)
+		errorResponse(w, r, err)
+		return
+	}
+
+	var patch json.RawMessage
+	if err := helpers.ReadJSON(w, r, &patch); err != nil {
+		badRequest(w, r, err)
 		return
 	}
 
-	coffeeCreated, err := coffee.CreateCoffee(coffeeData)
+	original, err := json.Marshal(current)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	merged, err := helpers.MergePatch(original, patch)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	var coffeeData services.Coffee
+	if err := json.Unmarshal(merged, &coffeeData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	c.saveCoffee(w, r, current, coffeeData)
+}
+
+// saveCoffee validates coffeeData and writes it over current, honouring any
+// If-Match precondition sent by the client, and responds with the stored coffee.
+func (c *CoffeeController) saveCoffee(w http.ResponseWriter, r *http.Request, current *services.Coffee, coffeeData services.Coffee) {
+	var version time.Time
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !helpers.IfMatch(ifMatch, helpers.ETag(current.UpdatedAt)) {
+			errorResponse(w, r, services.ErrEditConflict)
+			return
+		}
+		version = current.UpdatedAt
+	}
+
+	coffeeData.ID = current.ID
+
+	if err := c.validate(r.Context(), &coffeeData); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	coffeeUpdated, err := c.Coffees.Update(r.Context(), coffeeData, version)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"ETag": []string{helpers.ETag(coffeeUpdated.UpdatedAt)}}
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"coffee": coffeeUpdated}, headers)
+}
+
+// DELETE/coffees/{id}
+func (c *CoffeeController) DeleteCoffee(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := c.Coffees.Delete(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "coffee deleted"})
+}
+
+// POST/coffees/{id}/restore
+func (c *CoffeeController) RestoreCoffee(w http.ResponseWriter, r *http.Request) {
+	coffeeRestored, err := c.Coffees.Restore(r.Context(), chi.URLParam(r, "id"))
 	if err != nil {
-		helpers.MessageLogs.ErrorLog.Println(err)
+		errorResponse(w, r, err)
 		return
 	}
 
-	helpers.WriteJSON(w, http.StatusOK, coffeeCreated)
+	headers := http.Header{"ETag": []string{helpers.ETag(coffeeRestored.UpdatedAt)}}
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"coffee": coffeeRestored}, headers)
 }
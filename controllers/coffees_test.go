@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// withID routes r as if its path held id as the {id} parameter.
+func withID(r *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestSearchCoffees(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Scheduled Price", func(t *testing.T) {
+		// Test that search results are priced like the listing.
+		ctx := context.Background()
+		models := services.NewMemory()
+
+		coffee, err := models.Coffees.Create(ctx, services.Coffee{Name: "Yirgacheffe", Region: "Ethiopia", Price: services.Money{Amount: 1300, Currency: "EUR"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := models.Schedules.Create(ctx, services.PriceSchedule{CoffeeID: coffee.ID, Price: services.Money{Amount: 900, Currency: "EUR"}, EffectiveFrom: time.Now().Add(-time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/coffees/search?q=ethiopia", nil)
+		NewCoffeeController(models).SearchCoffees(w, r)
+
+		var body struct {
+			Results []struct {
+				Coffee map[string]interface{} `json:"coffee"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(body.Results) != 1 || body.Results[0].Coffee["price"] != 13.0 || body.Results[0].Coffee["effective_price"] != 9.0 || body.Results[0].Coffee["price_schedule"] == nil {
+			t.Errorf("SearchCoffees() = %s, want the scheduled price", w.Body.String())
+		}
+	})
+}
+
+func TestUpdateCoffee(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Scheduled Price Round Trip", func(t *testing.T) {
+		// Test that writing back a coffee read during a schedule keeps its base price.
+		ctx := context.Background()
+		models := services.NewMemory()
+		controller := NewCoffeeController(models)
+
+		coffee, err := models.Coffees.Create(ctx, services.Coffee{Name: "Yirgacheffe", Roast: "light", Image: "https://example.com/y.jpg", Region: "Ethiopia", Price: services.Money{Amount: 1300, Currency: "EUR"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := models.Schedules.Create(ctx, services.PriceSchedule{CoffeeID: coffee.ID, Price: services.Money{Amount: 900, Currency: "EUR"}, EffectiveFrom: time.Now().Add(-time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		controller.GetCoffeeByID(w, withID(httptest.NewRequest(http.MethodGet, "/api/v1/coffees/"+coffee.ID, nil), coffee.ID))
+
+		var read struct {
+			Coffee json.RawMessage `json:"coffee"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &read); err != nil {
+			t.Fatal(err)
+		}
+
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/coffees/"+coffee.ID, bytes.NewReader(read.Coffee))
+		controller.UpdateCoffee(w, withID(r, coffee.ID))
+		if w.Code != http.StatusOK {
+			t.Fatalf("UpdateCoffee() status = %d, body %s", w.Code, w.Body.String())
+		}
+
+		stored, err := models.Coffees.Get(ctx, coffee.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Price != coffee.Price {
+			t.Errorf("stored price = %+v, want the %+v base price", stored.Price, coffee.Price)
+		}
+	})
+}
+
+func TestReadCoffeeView(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Past Currency", func(t *testing.T) {
+		// Test that a past view cannot be quoted with today's list prices and rates.
+		qs := url.Values{"currency": {"usd"}, "as_of": {time.Now().Add(-time.Hour).Format(time.RFC3339)}}
+		if _, err := readCoffeeView(qs); err == nil {
+			t.Error("readCoffeeView() accepted a currency with an as_of in the past")
+		}
+	})
+
+	t.Run("Current Currency", func(t *testing.T) {
+		// Test that the currency is read without an as_of.
+		view, err := readCoffeeView(url.Values{"currency": {"usd"}})
+		if err != nil || view.currency != "USD" || view.past {
+			t.Errorf("readCoffeeView() = %+v, %v, want USD now", view, err)
+		}
+	})
+}
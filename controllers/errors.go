@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+)
+
+var (
+	errNotFound    = errors.New("the requested resource could not be found")
+	errConflict    = errors.New("the request conflicts with the current state of the resource")
+	errUnavailable = errors.New("the service is temporarily unavailable, please retry later")
+	errInternal    = errors.New("the server encountered a problem and could not process your request")
+)
+
+// errorResponse writes err with the HTTP status matching its services.Kind,
+// as problem details or the legacy envelope depending on the request.
+// Messages of unexpected and database errors are logged, never sent.
+func errorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var kindErr *services.Error
+	ownMessage := errors.As(err, &kindErr)
+
+	switch services.KindOf(err) {
+	case services.KindInvalid:
+		helpers.WriteError(w, r, err, http.StatusBadRequest)
+	case services.KindValidation:
+		var fieldErrors services.ValidationErrors
+		errors.As(err, &fieldErrors)
+		helpers.WriteValidationError(w, r, fieldErrors)
+	case services.KindNotFound:
+		helpers.WriteError(w, r, errNotFound, http.StatusNotFound)
+	case services.KindConflict:
+		if !ownMessage {
+			err = errConflict
+		}
+		helpers.WriteError(w, r, err, http.StatusConflict)
+	case services.KindPrecondition:
+		helpers.WriteError(w, r, err, http.StatusPreconditionFailed)
+	case services.KindUnavailable:
+		helpers.MessageLogs.ErrorLog.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		helpers.WriteError(w, r, errUnavailable, http.StatusServiceUnavailable)
+	default:
+		helpers.MessageLogs.ErrorLog.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		helpers.WriteError(w, r, errInternal, http.StatusInternalServerError)
+	}
+}
+
+// badRequest reports a malformed body or parameter as 400, or as 413 when the
+// body exceeded the size accepted by helpers.ReadJSON. Field errors raised
+// while decoding, such as an unrepresentable price, are reported as 422.
+func badRequest(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		helpers.WriteError(w, r, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var fieldErrors services.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		errorResponse(w, r, err)
+		return
+	}
+
+	errorResponse(w, r, services.NewError(services.KindInvalid, err))
+}
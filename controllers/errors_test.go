@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidandw190/coffeeshop-api-go/services"
+)
+
+func TestErrorResponse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Status Mapping", func(t *testing.T) {
+		// Test that every error kind is reported with its HTTP status.
+		cases := []struct {
+			err  error
+			want int
+		}{
+			{services.ErrInvalidCursor, http.StatusBadRequest},
+			{services.ValidationErrors{"price": "must be greater than zero"}, http.StatusUnprocessableEntity},
+			{fmt.Errorf("get coffee: %w", sql.ErrNoRows), http.StatusNotFound},
+			{services.NewError(services.KindConflict, errors.New("duplicate")), http.StatusConflict},
+			{services.ErrEditConflict, http.StatusPreconditionFailed},
+			{context.DeadlineExceeded, http.StatusServiceUnavailable},
+			{errors.New("connection reset by peer"), http.StatusInternalServerError},
+		}
+
+		for _, tc := range cases {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/coffees", nil)
+
+			errorResponse(w, r, tc.err)
+
+			if w.Code != tc.want {
+				t.Errorf("errorResponse(%v) status code = %d, want %d", tc.err, w.Code, tc.want)
+			}
+		}
+	})
+
+	t.Run("Internal Details Hidden", func(t *testing.T) {
+		// Test that unexpected error messages never reach the client.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/coffees", nil)
+
+		errorResponse(w, r, errors.New("pq: password authentication failed"))
+
+		if strings.Contains(w.Body.String(), "password") {
+			t.Errorf("errorResponse() leaked the internal error: %s", w.Body.String())
+		}
+	})
+
+	t.Run("Body Too Large", func(t *testing.T) {
+		// Test that oversized bodies are reported as 413.
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/coffees/coffee", nil)
+
+		badRequest(w, r, &http.MaxBytesError{Limit: 1048576})
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("badRequest() status code = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+		}
+	})
+}
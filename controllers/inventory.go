@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxAdjustments caps the adjustment history returned by one call.
+const maxAdjustments = 500
+
+// InventoryController serves the stock levels and adjustments of coffees and
+// the stock reservations taken against them.
+type InventoryController struct {
+	Coffees   services.CoffeeRepository
+	Variants  services.VariantRepository
+	Inventory services.InventoryRepository
+	Reorder   services.ReorderRepository
+}
+
+// NewInventoryController creates a controller backed by the given models.
+func NewInventoryController(models services.Models) *InventoryController {
+	return &InventoryController{Coffees: models.Coffees, Variants: models.Variants, Inventory: models.Inventory, Reorder: models.Reorder}
+}
+
+// GET/coffees/{id}/stock
+func (c *InventoryController) GetStock(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	levels, err := c.Inventory.Levels(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"stock": levels})
+}
+
+// GET/coffees/{id}/stock/adjustments
+func (c *InventoryController) GetAdjustments(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	limit, err := helpers.ReadInt(r.URL.Query(), "limit", services.DefaultPageSize)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if limit < 1 || limit > maxAdjustments {
+		badRequest(w, r, fmt.Errorf("limit must be between 1 and %d", maxAdjustments))
+		return
+	}
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	adjustments, err := c.Inventory.Adjustments(r.Context(), id, limit)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"adjustments": adjustments})
+}
+
+// POST/coffees/{id}/stock/adjustments
+func (c *InventoryController) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var adjustment services.Adjustment
+	if err := helpers.ReadJSON(w, r, &adjustment); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	adjustment.CoffeeID = id
+	adjustment.ReservationID = ""
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if errs := adjustment.Validate(); errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	if err := c.checkVariant(r.Context(), adjustment.StockKey); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	level, err := c.Inventory.Adjust(r.Context(), adjustment)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"stock": level})
+}
+
+// POST/inventory/reservations
+func (c *InventoryController) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	var reservation services.Reservation
+	if err := helpers.ReadJSON(w, r, &reservation); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if errs := reservation.Validate(); errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	_, err := c.Coffees.Get(r.Context(), reservation.CoffeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorResponse(w, r, services.ValidationErrors{"coffee_id": "must be the id of an existing coffee"})
+		return
+	}
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if err := c.checkVariant(r.Context(), reservation.StockKey); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	reservationCreated, err := c.Inventory.Reserve(r.Context(), reservation)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"Location": []string{path.Join(r.URL.Path, reservationCreated.ID)}}
+	helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"reservation": reservationCreated}, headers)
+}
+
+// GET/inventory/reservations/{id}
+func (c *InventoryController) GetReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, err := c.Inventory.GetReservation(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"reservation": reservation})
+}
+
+// POST/inventory/reservations/{id}/release
+func (c *InventoryController) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, err := c.Inventory.Release(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"reservation": reservation})
+}
+
+// POST/inventory/reservations/{id}/commit
+func (c *InventoryController) CommitReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, err := c.Inventory.Commit(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"reservation": reservation})
+}
+
+// GET/coffees/{id}/stock/threshold
+func (c *InventoryController) GetThreshold(w http.ResponseWriter, r *http.Request) {
+	threshold, err := c.Reorder.Threshold(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"threshold": threshold})
+}
+
+// PUT/coffees/{id}/stock/threshold
+func (c *InventoryController) SetThreshold(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var input struct {
+		Threshold *int `json:"threshold"`
+	}
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if input.Threshold == nil || *input.Threshold < 0 {
+		errorResponse(w, r, services.ValidationErrors{"threshold": "must be provided and not negative"})
+		return
+	}
+
+	if err := c.Reorder.SetThreshold(r.Context(), id, *input.Threshold); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"threshold": *input.Threshold})
+}
+
+// DELETE/coffees/{id}/stock/threshold
+func (c *InventoryController) DeleteThreshold(w http.ResponseWriter, r *http.Request) {
+	if err := c.Reorder.DeleteThreshold(r.Context(), chi.URLParam(r, "id")); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "threshold deleted"})
+}
+
+// GET/admin/low-stock
+func (c *InventoryController) GetLowStock(w http.ResponseWriter, r *http.Request) {
+	checks, err := c.Reorder.Checks(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	low := []services.StockCheck{}
+	for _, check := range checks {
+		if check.Low() {
+			low = append(low, check)
+		}
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"low_stock": low})
+}
+
+// checkVariant reports a field error when key names a variant that does not
+// belong to its coffee.
+func (c *InventoryController) checkVariant(ctx context.Context, key services.StockKey) error {
+	if key.VariantID == "" {
+		return nil
+	}
+
+	_, err := c.Variants.Get(ctx, key.CoffeeID, key.VariantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return services.ValidationErrors{"variant_id": "must be the id of a variant of the coffee"}
+	}
+
+	return err
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+)
+
+// LookupController serves the roast level and grind setting enumerations.
+type LookupController struct {
+	Lookups services.LookupRepository
+}
+
+// NewLookupController creates a controller backed by the given models.
+func NewLookupController(models services.Models) *LookupController {
+	return &LookupController{Lookups: models.Lookups}
+}
+
+// GET/roasts
+func (c *LookupController) GetRoasts(w http.ResponseWriter, r *http.Request) {
+	roasts, err := c.Lookups.Roasts(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"roasts": roasts})
+}
+
+// GET/grinds
+func (c *LookupController) GetGrinds(w http.ResponseWriter, r *http.Request) {
+	grinds, err := c.Lookups.Grinds(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"grinds": grinds})
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"math/big"
+	"net/http"
+	"path"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// OrderController serves the orders placed with the shop.
+type OrderController struct {
+	Orders services.OrderRepository
+	// TaxRate is charged on every new order and stored with it.
+	TaxRate *big.Rat
+}
+
+// NewOrderController creates a controller backed by the given models that
+// charges taxRate on new orders.
+func NewOrderController(models services.Models, taxRate *big.Rat) *OrderController {
+	return &OrderController{Orders: models.Orders, TaxRate: taxRate}
+}
+
+// POST/orders
+func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	// Clients only choose what to buy; names, prices and reservations are
+	// filled in by the repository.
+	var orderData struct {
+		Items []struct {
+			CoffeeID  string `json:"coffee_id"`
+			VariantID string `json:"variant_id"`
+			Quantity  int    `json:"quantity"`
+		} `json:"items"`
+	}
+	if err := helpers.ReadJSON(w, r, &orderData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	order := services.Order{Items: make([]*services.OrderItem, len(orderData.Items))}
+	for i, item := range orderData.Items {
+		order.Items[i] = &services.OrderItem{CoffeeID: item.CoffeeID, VariantID: item.VariantID, Quantity: item.Quantity}
+	}
+	if c.TaxRate != nil {
+		order.TaxRate = new(big.Rat).Set(c.TaxRate)
+	}
+
+	if errs := order.Validate(); errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	orderCreated, err := c.Orders.Create(r.Context(), order)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"Location": []string{path.Join(r.URL.Path, orderCreated.ID)}}
+	helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"order": orderCreated}, headers)
+}
+
+// GET/orders/{id}
+func (c *OrderController) GetOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := c.Orders.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"order": order})
+}
+
+// POST/orders/{id}/transitions
+func (c *OrderController) TransitionOrder(w http.ResponseWriter, r *http.Request) {
+	var transition struct {
+		Status string `json:"status"`
+	}
+	if err := helpers.ReadJSON(w, r, &transition); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	order, err := c.Orders.Transition(r.Context(), chi.URLParam(r, "id"), transition.Status)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"order": order})
+}
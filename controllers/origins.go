@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// OriginController serves the origin endpoints from an OriginRepository.
+type OriginController struct {
+	Origins services.OriginRepository
+}
+
+// NewOriginController creates a controller backed by the given models.
+func NewOriginController(models services.Models) *OriginController {
+	return &OriginController{Origins: models.Origins}
+}
+
+// GET/origins
+func (c *OriginController) GetAllOrigins(w http.ResponseWriter, r *http.Request) {
+	origins, err := c.Origins.List(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"origins": origins})
+}
+
+// GET/origins/{id}
+func (c *OriginController) GetOriginByID(w http.ResponseWriter, r *http.Request) {
+	origin, err := c.Origins.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"origin": origin})
+}
+
+// POST/origins
+func (c *OriginController) CreateOrigin(w http.ResponseWriter, r *http.Request) {
+	var originData services.Origin
+	if err := helpers.ReadJSON(w, r, &originData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if errs := originData.Validate(); errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	originCreated, err := c.Origins.Create(r.Context(), originData)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"Location": []string{path.Join(r.URL.Path, originCreated.ID)}}
+	helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"origin": originCreated}, headers)
+}
+
+// PUT/origins/{id}
+func (c *OriginController) UpdateOrigin(w http.ResponseWriter, r *http.Request) {
+	var originData services.Origin
+	if err := helpers.ReadJSON(w, r, &originData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	originData.ID = chi.URLParam(r, "id")
+
+	if errs := originData.Validate(); errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	originUpdated, err := c.Origins.Update(r.Context(), originData)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"origin": originUpdated})
+}
+
+// DELETE/origins/{id}
+func (c *OriginController) DeleteOrigin(w http.ResponseWriter, r *http.Request) {
+	if err := c.Origins.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "origin deleted"})
+}
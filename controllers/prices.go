@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// PriceController serves the per-coffee price lists and the exchange rates
+// maintained by staff.
+type PriceController struct {
+	Coffees   services.CoffeeRepository
+	Prices    services.PriceRepository
+	Rates     services.RateRepository
+	Schedules services.ScheduleRepository
+	History   services.HistoryRepository
+}
+
+// NewPriceController creates a controller backed by the given models.
+func NewPriceController(models services.Models) *PriceController {
+	return &PriceController{Coffees: models.Coffees, Prices: models.Prices, Rates: models.Rates, Schedules: models.Schedules, History: models.History}
+}
+
+// GET/coffees/{id}/prices
+func (c *PriceController) GetPrices(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	prices, err := c.Prices.List(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"prices": prices})
+}
+
+// GET/coffees/{id}/price-history
+func (c *PriceController) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	// Like the audit trail, the price history outlives deleted and purged
+	// coffees, so the coffee itself is not looked up.
+	changes, err := c.History.Prices(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"price_history": changes})
+}
+
+// PUT/coffees/{id}/prices/{currency}
+func (c *PriceController) SetPrice(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	currency := strings.ToUpper(chi.URLParam(r, "currency"))
+
+	if !services.IsCurrencyCode(currency) {
+		badRequest(w, r, fmt.Errorf("%q is not a three-letter ISO 4217 code", chi.URLParam(r, "currency")))
+		return
+	}
+
+	var input struct {
+		Amount json.Number `json:"amount"`
+	}
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	price, err := services.ParseMoney(input.Amount.String(), currency)
+	if err != nil || price.Amount <= 0 {
+		errorResponse(w, r, services.ValidationErrors{
+			"amount": fmt.Sprintf("must be greater than zero with at most %d decimal places for %s", services.CurrencyExponent(currency), currency),
+		})
+		return
+	}
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if err := c.Prices.Set(r.Context(), id, price); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"price": price})
+}
+
+// DELETE/coffees/{id}/prices/{currency}
+func (c *PriceController) DeletePrice(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	currency := strings.ToUpper(chi.URLParam(r, "currency"))
+
+	if err := c.Prices.Delete(r.Context(), id, currency); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "price deleted"})
+}
+
+// GET/coffees/{id}/price-schedules
+func (c *PriceController) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	schedules, err := c.Schedules.List(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"price_schedules": schedules})
+}
+
+// POST/coffees/{id}/price-schedules
+func (c *PriceController) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	coffee, err := c.Coffees.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	// Scheduled prices are in the coffee's currency unless the client says
+	// otherwise.
+	scheduleData := services.PriceSchedule{Price: services.Money{Currency: coffee.Price.Currency}}
+	if err := helpers.ReadJSON(w, r, &scheduleData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	scheduleData.CoffeeID = coffee.ID
+
+	if errs := scheduleData.Validate(time.Now()); errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	scheduleCreated, err := c.Schedules.Create(r.Context(), scheduleData)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"Location": []string{path.Join(r.URL.Path, scheduleCreated.ID)}}
+	helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"price_schedule": scheduleCreated}, headers)
+}
+
+// DELETE/coffees/{id}/price-schedules/{scheduleID}
+//
+// Cancels a schedule: one that has not started is removed and one in effect
+// ends now.
+func (c *PriceController) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	if err := c.Schedules.Cancel(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "scheduleID"), time.Now()); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "price schedule cancelled"})
+}
+
+// GET/admin/exchange-rates
+func (c *PriceController) ListRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := c.Rates.List(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"rates": rates})
+}
+
+// PUT/admin/exchange-rates
+//
+// Creates or replaces the given rates. The body is either JSON, {"rates":
+// [{"base": "EUR", "quote": "GBP", "rate": 0.8612}]}, or a text/csv upload of
+// base,quote,rate lines. Rates not mentioned are kept.
+func (c *PriceController) SaveRates(w http.ResponseWriter, r *http.Request) {
+	var rates []services.ExchangeRate
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		var err error
+		rates, err = services.ReadExchangeRates(http.MaxBytesReader(w, r.Body, 1048576))
+		if err != nil {
+			badRequest(w, r, services.NewError(services.KindInvalid, err))
+			return
+		}
+	} else {
+		var input struct {
+			Rates []services.ExchangeRate `json:"rates"`
+		}
+		if err := helpers.ReadJSON(w, r, &input); err != nil {
+			badRequest(w, r, err)
+			return
+		}
+		rates = input.Rates
+	}
+
+	if len(rates) == 0 {
+		badRequest(w, r, errors.New("at least one exchange rate must be provided"))
+		return
+	}
+
+	fieldErrors := services.ValidationErrors{}
+	for i := range rates {
+		for field, message := range rates[i].Validate() {
+			fieldErrors[fmt.Sprintf("rates[%d].%s", i, field)] = message
+		}
+	}
+	if len(fieldErrors) > 0 {
+		errorResponse(w, r, fieldErrors)
+		return
+	}
+
+	if err := c.Rates.Save(r.Context(), rates); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	c.ListRates(w, r)
+}
+
+// DELETE/admin/exchange-rates/{base}/{quote}
+func (c *PriceController) DeleteRate(w http.ResponseWriter, r *http.Request) {
+	base := strings.ToUpper(chi.URLParam(r, "base"))
+	quote := strings.ToUpper(chi.URLParam(r, "quote"))
+
+	if err := c.Rates.Delete(r.Context(), base, quote); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "exchange rate deleted"})
+}
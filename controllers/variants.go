@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/davidandw190/coffeeshop-api-go/helpers"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// VariantController serves the variants nested under a coffee.
+type VariantController struct {
+	Coffees  services.CoffeeRepository
+	Variants services.VariantRepository
+	Lookups  services.LookupRepository
+}
+
+// NewVariantController creates a controller backed by the given models.
+func NewVariantController(models services.Models) *VariantController {
+	return &VariantController{Coffees: models.Coffees, Variants: models.Variants, Lookups: models.Lookups}
+}
+
+// GET/coffees/{id}/variants
+func (c *VariantController) GetVariants(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if _, err := c.Coffees.Get(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	variants, err := c.Variants.List(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"variants": variants})
+}
+
+// GET/coffees/{id}/variants/{variantID}
+func (c *VariantController) GetVariant(w http.ResponseWriter, r *http.Request) {
+	variant, err := c.Variants.Get(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "variantID"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"variant": variant})
+}
+
+// POST/coffees/{id}/variants
+func (c *VariantController) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	coffee, err := c.Coffees.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	// Variants are priced in the coffee's currency and on sale unless the
+	// client says otherwise.
+	variantData := services.Variant{Price: services.Money{Currency: coffee.Price.Currency}, Available: true}
+	if err := helpers.ReadJSON(w, r, &variantData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	variantData.CoffeeID = coffee.ID
+
+	errs, err := services.CheckVariantLookups(r.Context(), c.Lookups, &variantData, variantData.Validate())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	variantCreated, err := c.Variants.Create(r.Context(), variantData)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	headers := http.Header{"Location": []string{path.Join(r.URL.Path, variantCreated.ID)}}
+	helpers.WriteJSON(w, http.StatusCreated, helpers.Envelope{"variant": variantCreated}, headers)
+}
+
+// PUT/coffees/{id}/variants/{variantID}
+func (c *VariantController) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	current, err := c.Variants.Get(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "variantID"))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	variantData := services.Variant{Price: services.Money{Currency: current.Price.Currency}, Available: true}
+	if err := helpers.ReadJSON(w, r, &variantData); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	variantData.ID = current.ID
+	variantData.CoffeeID = current.CoffeeID
+
+	errs, err := services.CheckVariantLookups(r.Context(), c.Lookups, &variantData, variantData.Validate())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if errs != nil {
+		errorResponse(w, r, errs)
+		return
+	}
+
+	variantUpdated, err := c.Variants.Update(r.Context(), variantData)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, helpers.Envelope{"variant": variantUpdated})
+}
+
+// DELETE/coffees/{id}/variants/{variantID}
+func (c *VariantController) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	if err := c.Variants.Delete(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "variantID")); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, services.JsonResponse{Message: "variant deleted"})
+}
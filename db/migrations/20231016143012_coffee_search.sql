@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Names weigh more than regions when ranking full-text matches.
+ALTER TABLE coffees
+    ADD COLUMN IF NOT EXISTS "search_vector" tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple'::regconfig, coalesce("name", '')), 'A') ||
+        setweight(to_tsvector('simple'::regconfig, coalesce("region", '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS coffees_search_vector_idx ON coffees USING GIN ("search_vector");
+CREATE INDEX IF NOT EXISTS coffees_name_trgm_idx ON coffees USING GIN ("name" gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS coffees_region_trgm_idx ON coffees USING GIN ("region" gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS coffees_region_trgm_idx;
+DROP INDEX IF EXISTS coffees_name_trgm_idx;
+DROP INDEX IF EXISTS coffees_search_vector_idx;
+ALTER TABLE coffees DROP COLUMN IF EXISTS "search_vector";
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Prices become exact integer amounts in the minor unit of their currency.
+-- Existing rows were entered in euros, so cents are 100 per unit.
+ALTER TABLE coffees ADD COLUMN IF NOT EXISTS "currency" CHAR(3) NOT NULL DEFAULT 'EUR';
+ALTER TABLE coffees ALTER COLUMN "price" TYPE BIGINT USING ROUND("price"::numeric * 100)::bigint;
+ALTER TABLE coffees RENAME COLUMN "price" TO "price_minor";
+ALTER TABLE coffees ADD CONSTRAINT coffees_currency_check CHECK ("currency" ~ '^[A-Z]{3}$');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE coffees DROP CONSTRAINT IF EXISTS coffees_currency_check;
+ALTER TABLE coffees RENAME COLUMN "price_minor" TO "price";
+ALTER TABLE coffees ALTER COLUMN "price" TYPE FLOAT USING "price" / 100.0;
+ALTER TABLE coffees DROP COLUMN IF EXISTS "currency";
+-- +goose StatementEnd
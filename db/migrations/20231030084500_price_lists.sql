@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Explicit prices of a coffee in currencies other than its base price.
+CREATE TABLE IF NOT EXISTS coffee_prices (
+    "coffee_id" uuid NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "currency" CHAR(3) NOT NULL CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "price_minor" BIGINT NOT NULL CHECK ("price_minor" > 0),
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("coffee_id", "currency")
+);
+
+-- One unit of base is worth rate units of quote. Maintained by staff.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    "base" CHAR(3) NOT NULL CHECK ("base" ~ '^[A-Z]{3}$'),
+    "quote" CHAR(3) NOT NULL CHECK ("quote" ~ '^[A-Z]{3}$'),
+    "rate" NUMERIC(24, 12) NOT NULL CHECK ("rate" > 0),
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("base", "quote"),
+    CHECK ("base" <> "quote")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS coffee_prices;
+-- +goose StatementEnd
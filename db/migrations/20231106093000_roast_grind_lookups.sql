@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS roast_levels (
+    "code" varchar PRIMARY KEY,
+    "name" varchar NOT NULL,
+    "position" INT NOT NULL UNIQUE,
+    "description" varchar NOT NULL DEFAULT ''
+);
+
+INSERT INTO roast_levels ("code", "name", "position", "description") VALUES
+    ('light', 'Light', 1, 'Light brown, no surface oil; bright acidity and origin character.'),
+    ('medium', 'Medium', 2, 'Medium brown, dry surface; balanced acidity, aroma and body.'),
+    ('medium-dark', 'Medium-Dark', 3, 'Rich brown with some oil; heavier body, bittersweet finish.'),
+    ('dark', 'Dark', 4, 'Dark brown to black and oily; low acidity, smoky and bitter notes.')
+ON CONFLICT ("code") DO NOTHING;
+
+-- Higher units are finer; 0 is whole bean.
+CREATE TABLE IF NOT EXISTS grind_settings (
+    "unit" INT PRIMARY KEY,
+    "code" varchar NOT NULL UNIQUE,
+    "name" varchar NOT NULL,
+    "description" varchar NOT NULL DEFAULT ''
+);
+
+INSERT INTO grind_settings ("unit", "code", "name", "description") VALUES
+    (0, 'whole-bean', 'Whole Bean', 'Unground beans, for grinding at home.'),
+    (1, 'extra-coarse', 'Extra Coarse', 'Cold brew and cowboy coffee.'),
+    (2, 'coarse', 'Coarse', 'French press and percolators.'),
+    (3, 'medium-coarse', 'Medium-Coarse', 'Chemex and Clever dripper.'),
+    (4, 'medium', 'Medium', 'Drip machines and siphon brewers.'),
+    (5, 'medium-fine', 'Medium-Fine', 'Pour-over cones and AeroPress.'),
+    (6, 'fine', 'Fine', 'Espresso and moka pots.'),
+    (7, 'extra-fine', 'Extra Fine', 'Turkish coffee.')
+ON CONFLICT ("unit") DO NOTHING;
+
+-- Normalize legacy spellings the same way services.NormalizeRoast does:
+-- lower-case, spaces and underscores to hyphens, no "-roast" suffix, aliases.
+UPDATE coffees
+SET "roast" = regexp_replace(replace(replace(lower(trim("roast")), ' ', '-'), '_', '-'), '-roast$', '');
+
+UPDATE coffees
+SET "roast" = CASE "roast"
+    WHEN 'med' THEN 'medium'
+    WHEN 'med-dark' THEN 'medium-dark'
+    WHEN 'mediumdark' THEN 'medium-dark'
+    ELSE "roast"
+END;
+
+-- Refuse to guess: unknown values must be fixed by hand before migrating.
+DO $$
+DECLARE
+    unknown text;
+BEGIN
+    SELECT string_agg(DISTINCT c."roast", ', ') INTO unknown
+    FROM coffees c LEFT JOIN roast_levels r ON r."code" = c."roast"
+    WHERE r."code" IS NULL;
+    IF unknown IS NOT NULL THEN
+        RAISE EXCEPTION 'coffees.roast has unknown roast levels: %', unknown;
+    END IF;
+
+    SELECT string_agg(DISTINCT c."grind_unit"::text, ', ') INTO unknown
+    FROM coffees c LEFT JOIN grind_settings g ON g."unit" = c."grind_unit"
+    WHERE g."unit" IS NULL;
+    IF unknown IS NOT NULL THEN
+        RAISE EXCEPTION 'coffees.grind_unit has unknown grind settings: %', unknown;
+    END IF;
+END $$;
+
+ALTER TABLE coffees
+    ADD CONSTRAINT coffees_roast_fkey FOREIGN KEY ("roast") REFERENCES roast_levels ("code") ON UPDATE CASCADE,
+    ADD CONSTRAINT coffees_grind_unit_fkey FOREIGN KEY ("grind_unit") REFERENCES grind_settings ("unit") ON UPDATE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE coffees
+    DROP CONSTRAINT IF EXISTS coffees_grind_unit_fkey,
+    DROP CONSTRAINT IF EXISTS coffees_roast_fkey;
+DROP TABLE IF EXISTS grind_settings;
+DROP TABLE IF EXISTS roast_levels;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS origins (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "country" varchar NOT NULL,
+    "region" varchar NOT NULL DEFAULT '',
+    "farm" varchar NOT NULL DEFAULT '',
+    "altitude_min" INT CHECK ("altitude_min" >= 0),
+    "altitude_max" INT CHECK ("altitude_max" >= 0),
+    "process" varchar NOT NULL DEFAULT '',
+    "harvest_season" varchar NOT NULL DEFAULT '',
+    "story" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK ("altitude_min" <= "altitude_max")
+);
+
+-- Deleting an origin that coffees still point at is refused.
+ALTER TABLE coffees ADD COLUMN IF NOT EXISTS "origin_id" uuid REFERENCES origins ("id") ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS coffees_origin_id_idx ON coffees ("origin_id");
+
+-- Existing regions are country names; give each one an origin to build on.
+INSERT INTO origins ("country")
+SELECT DISTINCT trim("region") FROM coffees WHERE trim("region") <> '';
+
+UPDATE coffees c
+SET "origin_id" = o."id"
+FROM origins o
+WHERE o."country" = trim(c."region");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS coffees_origin_id_idx;
+ALTER TABLE coffees DROP COLUMN IF EXISTS "origin_id";
+DROP TABLE IF EXISTS origins;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS coffee_variants (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "coffee_id" uuid NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "sku" varchar(64) NOT NULL,
+    "format" varchar NOT NULL CHECK ("format" IN ('bag', 'cup')),
+    "size" varchar(32) NOT NULL,
+    "weight_grams" INT CHECK ("weight_grams" > 0),
+    "price_minor" BIGINT NOT NULL CHECK ("price_minor" > 0),
+    "currency" CHAR(3) NOT NULL DEFAULT 'EUR' CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "grind_units" SMALLINT[] NOT NULL DEFAULT '{}',
+    "available" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    -- Bags are sold by weight; cups have neither a weight nor a grind.
+    CHECK (("format" = 'bag') = ("weight_grams" IS NOT NULL)),
+    CHECK ("format" = 'bag' OR cardinality("grind_units") = 0),
+    CHECK ("grind_units" <@ ARRAY[0, 1, 2, 3, 4, 5, 6, 7]::SMALLINT[])
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS coffee_variants_sku_idx ON coffee_variants (lower("sku"));
+CREATE INDEX IF NOT EXISTS coffee_variants_coffee_id_idx ON coffee_variants ("coffee_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS coffee_variants;
+-- +goose StatementEnd
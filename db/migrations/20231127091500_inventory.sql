@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Stock can be held for a variant only together with the coffee it belongs to.
+ALTER TABLE coffee_variants ADD CONSTRAINT coffee_variants_coffee_id_id_key UNIQUE ("coffee_id", "id");
+
+CREATE TABLE IF NOT EXISTS stock_levels (
+    "coffee_id" uuid NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "variant_id" uuid,
+    "location" varchar(32) NOT NULL DEFAULT 'main',
+    "on_hand" INT NOT NULL DEFAULT 0,
+    "reserved" INT NOT NULL DEFAULT 0,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY ("coffee_id", "variant_id") REFERENCES coffee_variants ("coffee_id", "id") ON DELETE CASCADE,
+    -- Reserved units are on hand, so a reservation can never oversell.
+    CHECK ("reserved" >= 0 AND "reserved" <= "on_hand")
+);
+
+-- One level per coffee or variant and location; coffee level stock has no variant.
+CREATE UNIQUE INDEX IF NOT EXISTS stock_levels_key_idx
+    ON stock_levels ("coffee_id", COALESCE("variant_id", '00000000-0000-0000-0000-000000000000'), "location");
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "coffee_id" uuid NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "variant_id" uuid,
+    "location" varchar(32) NOT NULL,
+    "quantity" INT NOT NULL CHECK ("quantity" > 0),
+    "status" varchar NOT NULL DEFAULT 'held' CHECK ("status" IN ('held', 'released', 'committed')),
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY ("coffee_id", "variant_id") REFERENCES coffee_variants ("coffee_id", "id") ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "coffee_id" uuid NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "variant_id" uuid,
+    "location" varchar(32) NOT NULL,
+    "delta" INT NOT NULL CHECK ("delta" <> 0),
+    "reason" varchar NOT NULL CHECK ("reason" IN ('received', 'sale', 'returned', 'damaged', 'expired', 'count', 'other')),
+    "note" TEXT NOT NULL DEFAULT '',
+    "reservation_id" uuid REFERENCES stock_reservations ("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY ("coffee_id", "variant_id") REFERENCES coffee_variants ("coffee_id", "id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS stock_adjustments_coffee_id_idx ON stock_adjustments ("coffee_id", "created_at" DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_adjustments;
+DROP TABLE IF EXISTS stock_reservations;
+DROP TABLE IF EXISTS stock_levels;
+ALTER TABLE coffee_variants DROP CONSTRAINT IF EXISTS coffee_variants_coffee_id_id_key;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS reorder_thresholds (
+    "coffee_id" uuid PRIMARY KEY NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "threshold" INT NOT NULL CHECK ("threshold" >= 0),
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- A row marks a shortage that has been alerted on; it is removed once stock recovers.
+CREATE TABLE IF NOT EXISTS low_stock_alerts (
+    "coffee_id" uuid PRIMARY KEY NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "alerted_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS low_stock_alerts;
+DROP TABLE IF EXISTS reorder_thresholds;
+-- +goose StatementEnd
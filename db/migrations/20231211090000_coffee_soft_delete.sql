@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE coffees ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP WITH TIME ZONE;
+
+-- The purge job only ever looks at deleted coffees.
+CREATE INDEX IF NOT EXISTS coffees_deleted_at_idx ON coffees ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS coffees_deleted_at_idx;
+ALTER TABLE coffees DROP COLUMN IF EXISTS "deleted_at";
+-- +goose StatementEnd
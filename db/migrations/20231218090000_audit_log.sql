@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Entries outlive the rows they describe, so entity_id has no foreign key.
+CREATE TABLE IF NOT EXISTS audit_log (
+    "id" BIGSERIAL PRIMARY KEY,
+    "entity" varchar(32) NOT NULL,
+    "entity_id" uuid NOT NULL,
+    "action" varchar(16) NOT NULL CHECK ("action" IN ('create', 'update', 'delete', 'restore', 'purge')),
+    "actor" varchar NOT NULL,
+    "request_id" varchar NOT NULL DEFAULT '',
+    "before" jsonb NOT NULL DEFAULT 'null',
+    "after" jsonb NOT NULL DEFAULT 'null',
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log ("entity", "entity_id", "created_at" DESC);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log ("actor", "created_at" DESC);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log ("created_at" DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS price_schedules (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "coffee_id" uuid NOT NULL REFERENCES coffees ("id") ON DELETE CASCADE,
+    "price_minor" BIGINT NOT NULL CHECK ("price_minor" > 0),
+    "currency" CHAR(3) NOT NULL DEFAULT 'EUR' CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "effective_from" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "effective_to" TIMESTAMP WITH TIME ZONE CHECK ("effective_to" > "effective_from"),
+    -- Set by the applier once it has seen the schedule start and end.
+    "started_at" TIMESTAMP WITH TIME ZONE,
+    "ended_at" TIMESTAMP WITH TIME ZONE,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Schedules of a coffee never overlap; the repository checks this while
+-- holding a lock on the coffee row.
+CREATE INDEX IF NOT EXISTS price_schedules_coffee_id_idx ON price_schedules ("coffee_id", "effective_from");
+CREATE INDEX IF NOT EXISTS price_schedules_pending_idx ON price_schedules ("effective_from") WHERE "started_at" IS NULL;
+CREATE INDEX IF NOT EXISTS price_schedules_running_idx ON price_schedules ("effective_to") WHERE "ended_at" IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS price_schedules;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Like the audit trail, the price history outlives the coffees it describes,
+-- so coffee_id has no foreign key.
+CREATE TABLE IF NOT EXISTS price_history (
+    "id" BIGSERIAL PRIMARY KEY,
+    "coffee_id" uuid NOT NULL,
+    "price_minor" BIGINT NOT NULL CHECK ("price_minor" > 0),
+    "currency" CHAR(3) NOT NULL CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "actor" varchar NOT NULL,
+    "changed_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS price_history_coffee_id_idx ON price_history ("coffee_id", "changed_at" DESC);
+CREATE INDEX IF NOT EXISTS price_history_changed_at_idx ON price_history ("changed_at" DESC);
+
+-- The current price is the only one known for existing coffees, so it is
+-- taken to have applied since their creation. Legacy rows without a positive
+-- price never had a valid one and get their first entry when repriced.
+INSERT INTO price_history ("coffee_id", "price_minor", "currency", "actor", "changed_at")
+SELECT "id", "price_minor", "currency", 'system', COALESCE("created_at", NOW()) FROM coffees
+WHERE "price_minor" > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS price_history;
+-- +goose StatementEnd
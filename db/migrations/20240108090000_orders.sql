@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS orders (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "status" varchar NOT NULL DEFAULT 'pending',
+    "currency" CHAR(3) NOT NULL CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "subtotal_minor" BIGINT NOT NULL CHECK ("subtotal_minor" >= 0),
+    "tax_rate" NUMERIC(13, 12) NOT NULL DEFAULT 0 CHECK ("tax_rate" >= 0 AND "tax_rate" < 1),
+    "tax_minor" BIGINT NOT NULL CHECK ("tax_minor" >= 0),
+    "total_minor" BIGINT NOT NULL CHECK ("total_minor" = "subtotal_minor" + "tax_minor"),
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Items snapshot the name and price of what was sold, so they keep no
+-- foreign key to the coffee or variant, which may change or go away.
+CREATE TABLE IF NOT EXISTS order_items (
+    "id" uuid PRIMARY KEY NOT NULL DEFAULT (uuid_generate_v4()),
+    "order_id" uuid NOT NULL REFERENCES orders ("id") ON DELETE CASCADE,
+    "position" INT NOT NULL CHECK ("position" > 0),
+    "coffee_id" uuid NOT NULL,
+    "variant_id" uuid,
+    "name" TEXT NOT NULL,
+    "unit_price_minor" BIGINT NOT NULL CHECK ("unit_price_minor" >= 0),
+    "quantity" INT NOT NULL CHECK ("quantity" > 0),
+    "line_total_minor" BIGINT NOT NULL CHECK ("line_total_minor" = "unit_price_minor" * "quantity"),
+    "reservation_id" uuid REFERENCES stock_reservations ("id") ON DELETE SET NULL,
+    UNIQUE ("order_id", "position")
+);
+
+CREATE INDEX IF NOT EXISTS orders_created_at_idx ON orders ("created_at" DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+-- +goose StatementEnd
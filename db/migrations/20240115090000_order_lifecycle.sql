@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The time an order entered pending is its created_at.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS "paid_at" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS "preparing_at" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS "ready_at" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS "completed_at" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS "cancelled_at" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS "refunded_at" TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT orders_status_check
+        CHECK ("status" IN ('pending', 'paid', 'preparing', 'ready', 'completed', 'cancelled', 'refunded'));
+
+CREATE INDEX IF NOT EXISTS orders_status_idx ON orders ("status", "created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS orders_status_idx;
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_status_check,
+    DROP COLUMN IF EXISTS "paid_at",
+    DROP COLUMN IF EXISTS "preparing_at",
+    DROP COLUMN IF EXISTS "ready_at",
+    DROP COLUMN IF EXISTS "completed_at",
+    DROP COLUMN IF EXISTS "cancelled_at",
+    DROP COLUMN IF EXISTS "refunded_at";
+-- +goose StatementEnd
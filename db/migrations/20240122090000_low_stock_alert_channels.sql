@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Shortages are claimed once per alert channel, so that a channel that failed
+-- is retried on its own without alerting the others again.
+ALTER TABLE low_stock_alerts ADD COLUMN IF NOT EXISTS "channel" varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE low_stock_alerts DROP CONSTRAINT IF EXISTS low_stock_alerts_pkey;
+ALTER TABLE low_stock_alerts ADD PRIMARY KEY ("coffee_id", "channel");
+
+-- Existing claims were delivered on every channel the server sends to.
+INSERT INTO low_stock_alerts ("coffee_id", "alerted_at", "channel")
+SELECT a."coffee_id", a."alerted_at", c."channel"
+FROM low_stock_alerts a CROSS JOIN (VALUES ('log'), ('webhook'), ('outbox')) AS c ("channel")
+WHERE a."channel" = '';
+DELETE FROM low_stock_alerts WHERE "channel" = '';
+
+ALTER TABLE low_stock_alerts ALTER COLUMN "channel" DROP DEFAULT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM low_stock_alerts a
+USING low_stock_alerts b
+WHERE a."coffee_id" = b."coffee_id" AND a."channel" > b."channel";
+ALTER TABLE low_stock_alerts DROP CONSTRAINT IF EXISTS low_stock_alerts_pkey;
+ALTER TABLE low_stock_alerts DROP COLUMN IF EXISTS "channel";
+ALTER TABLE low_stock_alerts ADD PRIMARY KEY ("coffee_id");
+-- +goose StatementEnd
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// migrationsDir holds the goose migrations, relative to this package.
+const migrationsDir = "migrations"
+
+// upMigrations returns the Up sections of the migrations in version order,
+// keyed by file name, with the goose annotations left in as comments.
+func upMigrations(t *testing.T) ([]string, map[string]string) {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+
+	names := make([]string, 0, len(paths))
+	ups := make(map[string]string, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		up, _, found := strings.Cut(string(raw), "-- +goose Down")
+		if !found {
+			t.Fatalf("%s has no Down section", path)
+		}
+
+		name := filepath.Base(path)
+		names = append(names, name)
+		ups[name] = up
+	}
+
+	return names, ups
+}
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Price History Skips Non-Positive Prices", func(t *testing.T) {
+		// Test that legacy coffees priced at zero or less do not break the price history backfill.
+		conn, err := sql.Open("pgx", os.Getenv("TEST_DSN"))
+		if err != nil {
+			t.Fatalf("sql.Open() error = %v", err)
+		}
+		defer conn.Close()
+
+		ctx := context.Background()
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("BeginTx() error = %v", err)
+		}
+		// Everything runs in a scratch schema that is rolled back at the end.
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `CREATE SCHEMA migration_test; SET LOCAL search_path TO migration_test, public`); err != nil {
+			t.Fatalf("creating the scratch schema: %v", err)
+		}
+
+		names, ups := upMigrations(t)
+		for _, name := range names {
+			if name == "20240101090000_price_history.sql" {
+				seed := `INSERT INTO coffees ("name", "roast", "region", "image", "price_minor", "currency", "grind_unit")
+				VALUES ('Free Sample', 'light', 'Kenya', 'https://example.com/a.jpg', 0, 'EUR', 0),
+					('Refund', 'dark', 'Brazil', 'https://example.com/b.jpg', -150, 'EUR', 0),
+					('Yirgacheffe', 'light', 'Ethiopia', 'https://example.com/c.jpg', 1300, 'EUR', 0)`
+				if _, err := tx.ExecContext(ctx, seed); err != nil {
+					t.Fatalf("seeding legacy coffees: %v", err)
+				}
+			}
+
+			if _, err := tx.ExecContext(ctx, ups[name]); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		var count int
+		var price int64
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*), MIN(price_minor) FROM price_history`).Scan(&count, &price); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 || price != 1300 {
+			t.Errorf("price_history has %d rows from %d, want only the 13.00 price", count, price)
+		}
+	})
+}
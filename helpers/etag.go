@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETag formats a modification timestamp as a strong entity tag. Timestamps are
+// truncated to microseconds, the precision PostgreSQL stores them with.
+func ETag(modified time.Time) string {
+	return `"` + strconv.FormatInt(modified.UnixMicro(), 10) + `"`
+}
+
+// IfMatch reports whether the current entity tag satisfies an If-Match header
+// value. Weak tags never match, as required for If-Match by RFC 7232.
+func IfMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIfMatch(t *testing.T) {
+	t.Parallel()
+
+	etag := ETag(time.Date(2023, 9, 29, 12, 24, 59, 123456789, time.UTC))
+
+	t.Run("Microsecond Precision", func(t *testing.T) {
+		// Test that nanoseconds below PostgreSQL precision do not change the tag.
+		truncated := ETag(time.Date(2023, 9, 29, 12, 24, 59, 123456000, time.UTC))
+		if etag != truncated {
+			t.Errorf("ETag() = %s, want %s", etag, truncated)
+		}
+	})
+
+	t.Run("Matching Tag", func(t *testing.T) {
+		// Test when one of the listed tags equals the current tag.
+		if !IfMatch(`"1", `+etag, etag) {
+			t.Errorf("IfMatch() = false, want true")
+		}
+	})
+
+	t.Run("Wildcard", func(t *testing.T) {
+		// Test when the client accepts any current representation.
+		if !IfMatch("*", etag) {
+			t.Errorf("IfMatch() = false, want true")
+		}
+	})
+
+	t.Run("Stale Tag", func(t *testing.T) {
+		// Test when the client holds an outdated tag.
+		if IfMatch(`"1"`, etag) {
+			t.Errorf("IfMatch() = true, want false")
+		}
+	})
+
+	t.Run("Weak Tag", func(t *testing.T) {
+		// Test that weak tags never satisfy If-Match.
+		if IfMatch("W/"+etag, etag) {
+			t.Errorf("IfMatch() = true, want false")
+		}
+	})
+}
@@ -30,6 +30,12 @@ var MessageLogs = &Message{
 // ReadJSON reads and decodes JSON data from the request body.
 func ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
 	const maxBytes = 1048576
+
+	// Reject declared oversized bodies up front instead of reading them first.
+	if r.ContentLength > maxBytes {
+		return &http.MaxBytesError{Limit: maxBytes}
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(data); err != nil {
@@ -57,7 +63,10 @@ func WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...h
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	// Callers may pass a more specific JSON media type such as problem+json.
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 
 	if _, err = w.Write(out); err != nil {
@@ -81,3 +90,15 @@ func ErrorJSON(w http.ResponseWriter, err error, status ...int) {
 
 	WriteJSON(w, statusCode, payload)
 }
+
+// ValidationErrorJSON responds with 422 Unprocessable Entity and the
+// per-field validation messages in the data member.
+func ValidationErrorJSON(w http.ResponseWriter, fieldErrors map[string]string) {
+	var payload = services.JsonResponse{
+		Error:   true,
+		Message: "the request contains invalid fields",
+		Data:    fieldErrors,
+	}
+
+	WriteJSON(w, http.StatusUnprocessableEntity, payload)
+}
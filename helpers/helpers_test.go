@@ -234,3 +234,30 @@ func TestErrorJSON(t *testing.T) {
 	})
 
 }
+
+func TestValidationErrorJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Field Errors", func(t *testing.T) {
+		// Test that field errors are returned in the data member with 422.
+		w := httptest.NewRecorder()
+
+		ValidationErrorJSON(w, map[string]string{"price": "must be greater than zero"})
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("ValidationErrorJSON() status code = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+		}
+
+		var response struct {
+			Error bool              `json:"error"`
+			Data  map[string]string `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("ValidationErrorJSON() could not decode response body: %v", err)
+		}
+
+		if !response.Error || response.Data["price"] != "must be greater than zero" {
+			t.Errorf("ValidationErrorJSON() response = %+v", response)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// MergePatch applies an RFC 7386 JSON merge patch to the original document.
+// Members set to null in the patch are removed, objects are merged recursively
+// and every other value replaces the original one.
+func MergePatch(original, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+
+	if err := decodeNumbers(original, &target); err != nil {
+		return nil, err
+	}
+
+	if err := decodeNumbers(patch, &changes); err != nil {
+		return nil, err
+	}
+
+	if _, ok := changes.(map[string]interface{}); !ok {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	return json.Marshal(mergeValue(target, changes))
+}
+
+// mergeValue implements the MergePatch algorithm from RFC 7386 section 2.
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// decodeNumbers unmarshals JSON keeping numbers as json.Number so that prices
+// survive the round trip without float rounding.
+func decodeNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+
+	original := `{"name": "Yirgacheffe", "price": 12.10, "origin": {"country": "Ethiopia", "farm": "Konga"}}`
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"Replace Member", `{"name": "Sidamo"}`, `{"name": "Sidamo", "price": 12.10, "origin": {"country": "Ethiopia", "farm": "Konga"}}`},
+		{"Remove Member", `{"price": null}`, `{"name": "Yirgacheffe", "origin": {"country": "Ethiopia", "farm": "Konga"}}`},
+		{"Nested Merge", `{"origin": {"farm": null, "region": "Gedeo"}}`, `{"name": "Yirgacheffe", "price": 12.10, "origin": {"country": "Ethiopia", "region": "Gedeo"}}`},
+		{"Empty Patch", `{}`, original},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(original), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch() error = %v, want nil", err)
+			}
+
+			var gotJSON, wantJSON map[string]interface{}
+			if err := json.Unmarshal(got, &gotJSON); err != nil {
+				t.Fatalf("Failed to unmarshal patched JSON: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantJSON); err != nil {
+				t.Fatalf("Failed to unmarshal expected JSON: %v", err)
+			}
+
+			if !reflect.DeepEqual(gotJSON, wantJSON) {
+				t.Errorf("MergePatch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Non Object Patch", func(t *testing.T) {
+		// Test that a patch replacing the whole document is rejected.
+		if _, err := MergePatch([]byte(original), []byte(`["name"]`)); err == nil {
+			t.Error("MergePatch() expected an error, got nil")
+		}
+	})
+
+	t.Run("Exact Numbers", func(t *testing.T) {
+		// Test that numbers are carried over without float formatting.
+		got, err := MergePatch([]byte(`{"price": 9.99}`), []byte(`{"name": "Kenya AA"}`))
+		if err != nil {
+			t.Fatalf("MergePatch() error = %v, want nil", err)
+		}
+
+		if string(got) != `{"name":"Kenya AA","price":9.99}` {
+			t.Errorf("MergePatch() = %s", got)
+		}
+	})
+}
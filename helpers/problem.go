@@ -0,0 +1,117 @@
+package helpers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// ErrorFormat selects how error responses are serialized.
+type ErrorFormat int
+
+const (
+	// FormatEnvelope writes the legacy services.JsonResponse envelope.
+	FormatEnvelope ErrorFormat = iota
+	// FormatProblem writes RFC 7807 application/problem+json documents.
+	FormatProblem
+)
+
+// DefaultErrorFormat is used when the client does not ask for problem+json
+// in its Accept header. It is set once at startup from the configuration.
+var DefaultErrorFormat = FormatEnvelope
+
+// ParseErrorFormat converts a configuration value ("envelope" or "problem")
+// into an ErrorFormat.
+func ParseErrorFormat(s string) (ErrorFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "envelope", "json":
+		return FormatEnvelope, nil
+	case "problem", "problem+json":
+		return FormatProblem, nil
+	default:
+		return FormatEnvelope, fmt.Errorf("unknown error format %q", s)
+	}
+}
+
+// Problem is an RFC 7807 problem details object. Errors and RequestID are
+// extension members carrying per-field validation messages and the ID of the
+// request that failed.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// NewProblem builds the problem details for a failed request. The type is
+// about:blank, so the title is the standard text of the status code.
+func NewProblem(r *http.Request, status int, detail string) *Problem {
+	return &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.RequestURI(),
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// ProblemJSON writes problem as application/problem+json.
+func ProblemJSON(w http.ResponseWriter, problem *Problem) {
+	WriteJSON(w, problem.Status, problem, http.Header{"Content-Type": {ProblemContentType}})
+}
+
+// WantsProblem reports whether the error response to r should be problem
+// details, either because the client accepts problem+json or because it is
+// the configured default.
+func WantsProblem(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != ProblemContentType {
+			continue
+		}
+
+		// An explicit q=0 means the client refuses the media type.
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+
+		return true
+	}
+
+	return DefaultErrorFormat == FormatProblem
+}
+
+// WriteError responds with err in the format negotiated for r: problem
+// details or the legacy JsonResponse envelope.
+func WriteError(w http.ResponseWriter, r *http.Request, err error, status int) {
+	if WantsProblem(r) {
+		ProblemJSON(w, NewProblem(r, status, err.Error()))
+		return
+	}
+
+	ErrorJSON(w, err, status)
+}
+
+// WriteValidationError responds with 422 and the per-field messages in the
+// format negotiated for r.
+func WriteValidationError(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) {
+	if WantsProblem(r) {
+		problem := NewProblem(r, http.StatusUnprocessableEntity, "the request contains invalid fields")
+		problem.Errors = fieldErrors
+		ProblemJSON(w, problem)
+		return
+	}
+
+	ValidationErrorJSON(w, fieldErrors)
+}
@@ -0,0 +1,138 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestWantsProblem(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/problem+json", true},
+		{"application/json, application/problem+json;q=0.5", true},
+		{"application/problem+json;q=0", false},
+		{"text/html, */*", false},
+	}
+
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", tc.accept)
+
+		if got := WantsProblem(r); got != tc.want {
+			t.Errorf("WantsProblem(Accept: %q) = %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrorFormat(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]ErrorFormat{
+		"":         FormatEnvelope,
+		"envelope": FormatEnvelope,
+		"Problem":  FormatProblem,
+	}
+
+	for input, want := range cases {
+		got, err := ParseErrorFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseErrorFormat(%q) = %v, %v, want %v, nil", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseErrorFormat("xml"); err == nil {
+		t.Error("ParseErrorFormat(\"xml\") error = nil, want an error")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Problem Details", func(t *testing.T) {
+		// Test that clients accepting problem+json receive RFC 7807 members.
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/coffees/42?expand=origin", nil)
+		r.Header.Set("Accept", ProblemContentType)
+		r = r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, "host/req-000001"))
+		w := httptest.NewRecorder()
+
+		WriteError(w, r, errors.New("the requested resource could not be found"), http.StatusNotFound)
+
+		if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+			t.Errorf("WriteError() Content-Type = %q, want %q", got, ProblemContentType)
+		}
+
+		var problem Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+
+		want := Problem{
+			Type:      "about:blank",
+			Title:     "Not Found",
+			Status:    http.StatusNotFound,
+			Detail:    "the requested resource could not be found",
+			Instance:  "/api/v1/coffees/42?expand=origin",
+			RequestID: "host/req-000001",
+		}
+		if !reflect.DeepEqual(problem, want) {
+			t.Errorf("WriteError() problem = %+v, want %+v", problem, want)
+		}
+	})
+
+	t.Run("Legacy Envelope", func(t *testing.T) {
+		// Test that other clients keep receiving the JsonResponse envelope.
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/coffees/42", nil)
+		w := httptest.NewRecorder()
+
+		WriteError(w, r, errors.New("boom"), http.StatusBadRequest)
+
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("WriteError() Content-Type = %q, want application/json", got)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body["error"] != true || body["message"] != "boom" {
+			t.Errorf("WriteError() body = %v, want the error envelope", body)
+		}
+	})
+
+	t.Run("Validation Problem", func(t *testing.T) {
+		// Test that field errors are carried in the errors extension member.
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/coffees/coffee", nil)
+		r.Header.Set("Accept", ProblemContentType)
+		w := httptest.NewRecorder()
+
+		fieldErrors := map[string]string{"price": "must be greater than zero"}
+		WriteValidationError(w, r, fieldErrors)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("WriteValidationError() status code = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+		}
+
+		var problem Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(problem.Errors, fieldErrors) {
+			t.Errorf("WriteValidationError() errors = %v, want %v", problem.Errors, fieldErrors)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidandw190/coffeeshop-api-go/services"
+)
+
+// ReadInt reads an integer query string parameter, returning def when the
+// parameter is absent.
+func ReadInt(qs url.Values, key string, def int) (int, error) {
+	value := qs.Get(key)
+	if value == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return def, fmt.Errorf("%s must be an integer value", key)
+	}
+
+	return i, nil
+}
+
+// ReadBool reads a boolean query string parameter such as ?in_stock=true,
+// returning def when the parameter is absent.
+func ReadBool(qs url.Values, key string, def bool) (bool, error) {
+	value := qs.Get(key)
+	if value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return def, fmt.Errorf("%s must be true or false", key)
+	}
+
+	return b, nil
+}
+
+// ReadTime reads an RFC 3339 timestamp query string parameter such as
+// ?since=2023-12-01T00:00:00Z, returning the zero time when it is absent.
+func ReadTime(qs url.Values, key string) (time.Time, error) {
+	value := qs.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp such as 2023-12-01T09:00:00Z", key)
+	}
+
+	return t, nil
+}
+
+// LinkHeader formats RFC 8288 web links, keyed by relation type, into a Link
+// header value. Relations are written in the order given by rels.
+func LinkHeader(links map[string]string, rels ...string) string {
+	var parts []string
+	for _, rel := range rels {
+		if target, ok := links[rel]; ok {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, target, rel))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// ReadAmount reads an optional decimal amount query string parameter such as
+// ?price_min=9.99, returning it in the minor units of currency, or nil when
+// the parameter is absent.
+func ReadAmount(qs url.Values, key, currency string) (*int64, error) {
+	value := qs.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	m, err := services.ParseMoney(value, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a decimal amount with at most %d decimal places", key, services.CurrencyExponent(currency))
+	}
+
+	return &m.Amount, nil
+}
+
+// ReadCSV collects a list query string parameter given either repeatedly
+// (?roast=light&roast=dark) or comma separated (?roast=light,dark).
+func ReadCSV(qs url.Values, key string) []string {
+	var values []string
+	for _, value := range qs[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadInt(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Missing Parameter", func(t *testing.T) {
+		// Test that the default is returned when the parameter is absent.
+		got, err := ReadInt(url.Values{}, "limit", 20)
+		if err != nil || got != 20 {
+			t.Errorf("ReadInt() = %d, %v, want 20, nil", got, err)
+		}
+	})
+
+	t.Run("Valid Integer", func(t *testing.T) {
+		// Test parsing a well-formed integer.
+		got, err := ReadInt(url.Values{"limit": {"5"}}, "limit", 20)
+		if err != nil || got != 5 {
+			t.Errorf("ReadInt() = %d, %v, want 5, nil", got, err)
+		}
+	})
+
+	t.Run("Invalid Integer", func(t *testing.T) {
+		// Test that a non-numeric value is reported.
+		if _, err := ReadInt(url.Values{"limit": {"ten"}}, "limit", 20); err == nil {
+			t.Error("ReadInt() expected an error, got nil")
+		}
+	})
+}
+
+func TestReadBool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Missing Parameter", func(t *testing.T) {
+		// Test that the default is returned when the parameter is absent.
+		got, err := ReadBool(url.Values{}, "in_stock", false)
+		if err != nil || got {
+			t.Errorf("ReadBool() = %t, %v, want false, nil", got, err)
+		}
+	})
+
+	t.Run("Valid Boolean", func(t *testing.T) {
+		// Test parsing a well-formed boolean.
+		got, err := ReadBool(url.Values{"in_stock": {"true"}}, "in_stock", false)
+		if err != nil || !got {
+			t.Errorf("ReadBool() = %t, %v, want true, nil", got, err)
+		}
+	})
+
+	t.Run("Invalid Boolean", func(t *testing.T) {
+		// Test that a value other than a boolean is reported.
+		if _, err := ReadBool(url.Values{"in_stock": {"yes"}}, "in_stock", false); err == nil {
+			t.Error("ReadBool() expected an error, got nil")
+		}
+	})
+}
+
+func TestReadTime(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Missing Parameter", func(t *testing.T) {
+		// Test that an absent parameter reads as the zero time.
+		got, err := ReadTime(url.Values{}, "since")
+		if err != nil || !got.IsZero() {
+			t.Errorf("ReadTime() = %v, %v, want the zero time", got, err)
+		}
+	})
+
+	t.Run("Valid Timestamp", func(t *testing.T) {
+		// Test parsing a timestamp with an offset.
+		got, err := ReadTime(url.Values{"since": {"2023-12-01T10:30:00+01:00"}}, "since")
+		want := time.Date(2023, 12, 1, 9, 30, 0, 0, time.UTC)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ReadTime() = %v, %v, want %v", got, err, want)
+		}
+	})
+
+	t.Run("Invalid Timestamp", func(t *testing.T) {
+		// Test that a date without a time is reported.
+		if _, err := ReadTime(url.Values{"since": {"2023-12-01"}}, "since"); err == nil {
+			t.Error("ReadTime() expected an error, got nil")
+		}
+	})
+}
+
+func TestReadAmount(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Missing Parameter", func(t *testing.T) {
+		// Test that an absent parameter yields nil.
+		got, err := ReadAmount(url.Values{}, "price_min", "EUR")
+		if err != nil || got != nil {
+			t.Errorf("ReadAmount() = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	t.Run("Valid Amount", func(t *testing.T) {
+		// Test parsing a decimal value into minor units.
+		got, err := ReadAmount(url.Values{"price_min": {"9.5"}}, "price_min", "EUR")
+		if err != nil || got == nil || *got != 950 {
+			t.Errorf("ReadAmount() = %v, %v, want 950, nil", got, err)
+		}
+	})
+
+	t.Run("Invalid Amount", func(t *testing.T) {
+		// Test that non-numeric values and excess decimals are reported.
+		for _, value := range []string{"cheap", "9.999", "1/3"} {
+			if _, err := ReadAmount(url.Values{"price_min": {value}}, "price_min", "EUR"); err == nil {
+				t.Errorf("ReadAmount(%q) expected an error, got nil", value)
+			}
+		}
+	})
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Repeated And Comma Separated", func(t *testing.T) {
+		// Test that both list styles are merged in order.
+		qs := url.Values{"roast": {"light,medium", " dark ", ""}}
+
+		got := ReadCSV(qs, "roast")
+		want := []string{"light", "medium", "dark"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadCSV() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestLinkHeader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Ordered Relations", func(t *testing.T) {
+		// Test that links are written in the requested order and missing ones skipped.
+		links := map[string]string{
+			"next":  "/api/v1/coffees?offset=20",
+			"first": "/api/v1/coffees",
+		}
+
+		got := LinkHeader(links, "first", "prev", "next")
+		want := `</api/v1/coffees>; rel="first", </api/v1/coffees?offset=20>; rel="next"`
+		if got != want {
+			t.Errorf("LinkHeader() = %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReorderRepository stores the reorder thresholds of coffees and on which
+// alert channels they have already been alerted on. Threshold and
+// DeleteThreshold report a coffee without a threshold as sql.ErrNoRows.
+// Claim and Unclaim concern the shortage of a coffee on one channel, while
+// Clear forgets it on all of them.
+type ReorderRepository interface {
+	Threshold(ctx context.Context, coffeeID string) (int, error)
+	SetThreshold(ctx context.Context, coffeeID string, threshold int) error
+	DeleteThreshold(ctx context.Context, coffeeID string) error
+	Checks(ctx context.Context) ([]StockCheck, error)
+	Claim(ctx context.Context, coffeeID, channel string, at time.Time) (bool, error)
+	Unclaim(ctx context.Context, coffeeID, channel string) error
+	Clear(ctx context.Context, coffeeID string) error
+}
+
+// StockCheck compares the available stock of a coffee, summed over its
+// variants and locations, with its reorder threshold. AlertedVia lists the
+// channels an alert for the current shortage has been sent on, and Alerted
+// is set when there is any.
+type StockCheck struct {
+	CoffeeID   string   `json:"coffee_id"`
+	CoffeeName string   `json:"coffee_name"`
+	Threshold  int      `json:"threshold"`
+	Available  int      `json:"available"`
+	Alerted    bool     `json:"alerted"`
+	AlertedVia []string `json:"alerted_via"`
+}
+
+// Low reports whether the coffee is at or below its reorder threshold.
+func (c StockCheck) Low() bool {
+	return c.Available <= c.Threshold
+}
+
+// AlertChannel is a notifier whose deliveries are claimed under Name, which
+// must be unique among the channels of a StockChecker.
+type AlertChannel struct {
+	Name     string
+	Notifier Notifier
+}
+
+// StockChecker periodically compares stock with the reorder thresholds and
+// notifies every channel once per shortage: a coffee is alerted on when it
+// turns low and only again after its stock has recovered above the threshold.
+type StockChecker struct {
+	Reorder  ReorderRepository
+	Channels []AlertChannel
+	Interval time.Duration
+	Logger   *log.Logger
+}
+
+// Run checks stock every Interval until ctx is done.
+func (c *StockChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Check(ctx); err != nil && ctx.Err() == nil {
+			c.Logger.Printf("StockChecker: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check runs one pass and returns the number of alerts sent, counting each
+// channel. Claiming an alert on a channel before sending it keeps concurrent
+// checkers from alerting twice; an alert that fails to send is unclaimed on
+// that channel only, so the next pass retries it there alone.
+func (c *StockChecker) Check(ctx context.Context) (int, error) {
+	checks, err := c.Reorder.Checks(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+	for _, check := range checks {
+		switch {
+		case check.Low():
+			now := time.Now()
+			alert := LowStockAlert{
+				CoffeeID:   check.CoffeeID,
+				CoffeeName: check.CoffeeName,
+				Available:  check.Available,
+				Threshold:  check.Threshold,
+				DetectedAt: now,
+			}
+
+			for _, channel := range c.Channels {
+				if slices.Contains(check.AlertedVia, channel.Name) {
+					continue
+				}
+
+				claimed, err := c.Reorder.Claim(ctx, check.CoffeeID, channel.Name, now)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if !claimed {
+					continue
+				}
+
+				if err := channel.Notifier.Notify(ctx, alert); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", channel.Name, err), c.Reorder.Unclaim(ctx, check.CoffeeID, channel.Name))
+					continue
+				}
+				sent++
+			}
+
+		case check.Alerted:
+			errs = append(errs, c.Reorder.Clear(ctx, check.CoffeeID))
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// PostgresReorderRepository is a ReorderRepository backed by the
+// reorder_thresholds and low_stock_alerts tables.
+type PostgresReorderRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresReorderRepository creates a repository using the given connection pool.
+func NewPostgresReorderRepository(db *sql.DB) *PostgresReorderRepository {
+	return &PostgresReorderRepository{db: db}
+}
+
+// Threshold returns the reorder threshold of a coffee.
+func (r *PostgresReorderRepository) Threshold(ctx context.Context, coffeeID string) (int, error) {
+	if !isUUID(coffeeID) {
+		return 0, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var threshold int
+	err := r.db.QueryRowContext(ctx, `SELECT threshold FROM reorder_thresholds WHERE coffee_id = $1`, coffeeID).Scan(&threshold)
+
+	return threshold, err
+}
+
+// SetThreshold creates or replaces the reorder threshold of a coffee.
+func (r *PostgresReorderRepository) SetThreshold(ctx context.Context, coffeeID string, threshold int) error {
+	if !isUUID(coffeeID) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO reorder_thresholds(coffee_id, threshold, updated_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (coffee_id) DO UPDATE
+        SET threshold = EXCLUDED.threshold, updated_at = EXCLUDED.updated_at
+    `
+
+	_, err := r.db.ExecContext(ctx, query, coffeeID, threshold, time.Now())
+	if hasSQLState(err, pgForeignKeyViolation) {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
+
+// DeleteThreshold stops checking the stock of a coffee.
+func (r *PostgresReorderRepository) DeleteThreshold(ctx context.Context, coffeeID string) error {
+	if !isUUID(coffeeID) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM reorder_thresholds WHERE coffee_id = $1`, coffeeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Checks returns the stock check of every coffee with a reorder threshold
+// that is not deleted, ordered by coffee name.
+func (r *PostgresReorderRepository) Checks(ctx context.Context) ([]StockCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+	SELECT t.coffee_id, c.name, t.threshold,
+		COALESCE((SELECT SUM(s.on_hand - s.reserved) FROM stock_levels s WHERE s.coffee_id = t.coffee_id), 0),
+		COALESCE((SELECT string_agg(a.channel, ',' ORDER BY a.channel) FROM low_stock_alerts a WHERE a.coffee_id = t.coffee_id), '')
+	FROM reorder_thresholds t
+	JOIN coffees c ON c.id = t.coffee_id
+	WHERE c.deleted_at IS NULL
+	ORDER BY c.name, t.coffee_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []StockCheck{}
+	for rows.Next() {
+		var check StockCheck
+		var channels string
+		if err := rows.Scan(&check.CoffeeID, &check.CoffeeName, &check.Threshold, &check.Available, &channels); err != nil {
+			return nil, err
+		}
+		check.AlertedVia = []string{}
+		if channels != "" {
+			check.AlertedVia = strings.Split(channels, ",")
+		}
+		check.Alerted = len(check.AlertedVia) > 0
+		checks = append(checks, check)
+	}
+
+	return checks, rows.Err()
+}
+
+// Claim records that the current shortage of a coffee is being alerted on
+// the given channel. It returns false when another checker claimed it first.
+func (r *PostgresReorderRepository) Claim(ctx context.Context, coffeeID, channel string, at time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `INSERT INTO low_stock_alerts(coffee_id, channel, alerted_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, coffeeID, channel, at)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// Unclaim forgets the alert of a coffee on one channel, so the next pass
+// sends it there again.
+func (r *PostgresReorderRepository) Unclaim(ctx context.Context, coffeeID, channel string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM low_stock_alerts WHERE coffee_id = $1 AND channel = $2`, coffeeID, channel)
+
+	return err
+}
+
+// Clear forgets the alerts of a coffee on every channel, so its next
+// shortage is alerted on.
+func (r *PostgresReorderRepository) Clear(ctx context.Context, coffeeID string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM low_stock_alerts WHERE coffee_id = $1`, coffeeID)
+
+	return err
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// recordingNotifier remembers the alerts it was given and fails while err is set.
+type recordingNotifier struct {
+	alerts []LowStockAlert
+	err    error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert LowStockAlert) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func TestStockChecker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newChecker := func(t *testing.T, onHand, threshold int) (*StockChecker, *recordingNotifier, *MemoryInventoryRepository, StockKey) {
+		coffees := NewMemoryCoffeeRepository()
+		coffee, err := coffees.Create(ctx, Coffee{Name: "Geisha"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inventory := NewMemoryInventoryRepository(coffees)
+		key := StockKey{CoffeeID: coffee.ID, Location: DefaultLocation}
+		if _, err := inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: onHand, Reason: ReasonReceived}); err != nil {
+			t.Fatal(err)
+		}
+
+		reorder := NewMemoryReorderRepository(coffees, inventory)
+		if err := reorder.SetThreshold(ctx, coffee.ID, threshold); err != nil {
+			t.Fatal(err)
+		}
+
+		notifier := &recordingNotifier{}
+		checker := &StockChecker{Reorder: reorder, Channels: []AlertChannel{{Name: "log", Notifier: notifier}}, Interval: time.Minute, Logger: log.New(io.Discard, "", 0)}
+
+		return checker, notifier, inventory, key
+	}
+
+	check := func(t *testing.T, checker *StockChecker, want int) {
+		t.Helper()
+		if sent, err := checker.Check(ctx); err != nil || sent != want {
+			t.Fatalf("Check() = %d, %v, want %d alerts", sent, err, want)
+		}
+	}
+
+	t.Run("Alerts Once Per Shortage", func(t *testing.T) {
+		// Test that a shortage is alerted on once, however often it is checked.
+		checker, notifier, _, key := newChecker(t, 3, 5)
+
+		check(t, checker, 1)
+		check(t, checker, 0)
+
+		if len(notifier.alerts) != 1 {
+			t.Fatalf("alerts = %v, want one", notifier.alerts)
+		}
+		if alert := notifier.alerts[0]; alert.CoffeeID != key.CoffeeID || alert.Available != 3 || alert.Threshold != 5 {
+			t.Errorf("alert = %+v, want 3 available of %s at threshold 5", alert, key.CoffeeID)
+		}
+	})
+
+	t.Run("Realerts After Recovery", func(t *testing.T) {
+		// Test that stock recovering above the threshold rearms the alert.
+		checker, notifier, inventory, key := newChecker(t, 3, 5)
+
+		check(t, checker, 1)
+
+		if _, err := inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: 10, Reason: ReasonReceived}); err != nil {
+			t.Fatal(err)
+		}
+		check(t, checker, 0)
+
+		if _, err := inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: -12, Reason: ReasonSale}); err != nil {
+			t.Fatal(err)
+		}
+		check(t, checker, 1)
+
+		if len(notifier.alerts) != 2 {
+			t.Errorf("alerts = %v, want two", notifier.alerts)
+		}
+	})
+
+	t.Run("Above Threshold", func(t *testing.T) {
+		// Test that well stocked coffees are not alerted on.
+		checker, _, _, _ := newChecker(t, 6, 5)
+
+		check(t, checker, 0)
+	})
+
+	t.Run("Retries Failed Notification", func(t *testing.T) {
+		// Test that an alert that could not be sent is sent on the next pass.
+		checker, notifier, _, _ := newChecker(t, 0, 2)
+		notifier.err = errors.New("webhook down")
+
+		if _, err := checker.Check(ctx); err == nil {
+			t.Fatal("Check() error = nil, want the notifier error")
+		}
+
+		notifier.err = nil
+		check(t, checker, 1)
+	})
+
+	t.Run("Retries Failed Channel Only", func(t *testing.T) {
+		// Test that a channel that failed is retried alone, without alerting
+		// the channels that already delivered again.
+		checker, delivered, _, _ := newChecker(t, 0, 2)
+		webhook := &recordingNotifier{err: errors.New("webhook down")}
+		checker.Channels = append(checker.Channels, AlertChannel{Name: "webhook", Notifier: webhook})
+
+		for i := 0; i < 2; i++ {
+			if _, err := checker.Check(ctx); err == nil {
+				t.Fatal("Check() error = nil, want the webhook error")
+			}
+		}
+		if len(delivered.alerts) != 1 {
+			t.Errorf("delivered alerts = %d, want exactly one", len(delivered.alerts))
+		}
+
+		webhook.err = nil
+		check(t, checker, 1)
+		check(t, checker, 0)
+		if len(delivered.alerts) != 1 || len(webhook.alerts) != 1 {
+			t.Errorf("alerts = %d and %d, want one on each channel", len(delivered.alerts), len(webhook.alerts))
+		}
+	})
+}
+
+func TestFileNotifier(t *testing.T) {
+	t.Parallel()
+
+	// Test that every alert is appended to the outbox as one JSON line.
+	notifier := &FileNotifier{Path: filepath.Join(t.TempDir(), "outbox.jsonl")}
+
+	for _, name := range []string{"Geisha", "Bourbon"} {
+		if err := notifier.Notify(context.Background(), LowStockAlert{CoffeeName: name}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(notifier.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("outbox = %q, want two lines", data)
+	}
+
+	var alert LowStockAlert
+	if err := json.Unmarshal([]byte(lines[1]), &alert); err != nil || alert.CoffeeName != "Bourbon" {
+		t.Errorf("second line = %q, want the Bourbon alert", lines[1])
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+
+	alert := LowStockAlert{CoffeeID: testCoffeeID, CoffeeName: "Geisha", Available: 1, Threshold: 4}
+
+	t.Run("Posts Alert", func(t *testing.T) {
+		// Test that the alert is posted as JSON with a readable text field.
+		var body map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("request = %s with %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		if err := NewWebhookNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if body["text"] != alert.String() || body["coffee_id"] != testCoffeeID {
+			t.Errorf("body = %v, want the alert and its text", body)
+		}
+	})
+
+	t.Run("Error Status", func(t *testing.T) {
+		// Test that a webhook answering with an error status fails the notification.
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		if err := NewWebhookNotifier(server.URL).Notify(context.Background(), alert); err == nil {
+			t.Error("Notify() error = nil, want an error for 502")
+		}
+	})
+}
+
+func TestMultiNotifier(t *testing.T) {
+	t.Parallel()
+
+	// Test that a failing notifier neither stops the others nor goes unreported.
+	down := errors.New("webhook down")
+	first, second := &recordingNotifier{err: down}, &recordingNotifier{}
+
+	err := MultiNotifier{first, second}.Notify(context.Background(), LowStockAlert{CoffeeName: "Geisha"})
+	if !errors.Is(err, down) {
+		t.Errorf("Notify() error = %v, want %v", err, down)
+	}
+	if len(second.alerts) != 1 {
+		t.Errorf("second notifier got %d alerts, want 1", len(second.alerts))
+	}
+}
+
+func TestPostgresReorderChecks(t *testing.T) {
+	t.Parallel()
+
+	// Test that the channels a shortage was alerted on are read back as a list.
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("string_agg(a.channel, ',' ORDER BY a.channel)")).
+		WillReturnRows(sqlmock.NewRows([]string{"coffee_id", "name", "threshold", "available", "channels"}).
+			AddRow(testCoffeeID, "Geisha", 5, 2, "log,outbox").
+			AddRow(missingCoffeeID, "Kona", 5, 9, ""))
+
+	checks, err := NewPostgresReorderRepository(db).Checks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(checks) != 2 || !checks[0].Alerted || !reflect.DeepEqual(checks[0].AlertedVia, []string{"log", "outbox"}) || checks[1].Alerted {
+		t.Errorf("Checks() = %+v, want Geisha alerted on log and outbox only", checks)
+	}
+}
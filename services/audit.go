@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Audited entities and the actions recorded for them.
+const (
+	EntityCoffee = "coffee"
+
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// SystemActor is recorded for mutations made without an AuditInfo, such as
+// those of background jobs.
+const SystemActor = "system"
+
+const (
+	// DefaultAuditLimit is used when an audit query does not ask for a limit.
+	DefaultAuditLimit = 100
+	// MaxAuditLimit caps the number of entries returned by one audit query.
+	MaxAuditLimit = 500
+)
+
+// AuditInfo identifies who performs the mutations made with a context.
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns a copy of ctx whose mutations are recorded as made by
+// info.
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// auditInfoFrom returns the AuditInfo of ctx, attributing mutations without
+// an actor to SystemActor.
+func auditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	if info.Actor == "" {
+		info.Actor = SystemActor
+	}
+
+	return info
+}
+
+// AuditRepository reads the audit trail. Entries are written by the
+// repositories of the audited entities, in the transaction of the mutation
+// they record.
+type AuditRepository interface {
+	List(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// AuditEntry records one mutation of an entity. Before is null for creations
+// and After is null for purges.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects audit entries, newest first. Zero fields match
+// everything; Since is inclusive and Until exclusive.
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Offset   int
+}
+
+// limit returns the effective limit of the filter.
+func (f AuditFilter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultAuditLimit
+	}
+
+	return min(f.Limit, MaxAuditLimit)
+}
+
+// matches reports whether entry passes the filter, like the conditions of
+// the PostgreSQL query.
+func (f AuditFilter) matches(entry *AuditEntry) bool {
+	switch {
+	case f.Entity != "" && entry.Entity != f.Entity:
+		return false
+	case f.EntityID != "" && entry.EntityID != f.EntityID:
+		return false
+	case f.Actor != "" && entry.Actor != f.Actor:
+		return false
+	case !f.Since.IsZero() && entry.CreatedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.CreatedAt.Before(f.Until):
+		return false
+	}
+
+	return true
+}
+
+// newAuditEntry records action on a coffee by the actor of ctx, with
+// snapshots of the coffee before and after it. Either may be nil.
+func newAuditEntry(ctx context.Context, action string, id string, before, after *Coffee) (*AuditEntry, error) {
+	info := auditInfoFrom(ctx)
+	entry := &AuditEntry{
+		Entity:    EntityCoffee,
+		EntityID:  id,
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// snapshot encodes the stored fields of a coffee, or null for nil.
+func snapshot(coffee *Coffee) (json.RawMessage, error) {
+	if coffee == nil {
+		return json.RawMessage("null"), nil
+	}
+
+	stored := *coffee
+	stored.LocalPrice, stored.Origin, stored.Variants, stored.Stock, stored.Schedule, stored.EffectivePrice = nil, nil, nil, nil, nil, nil
+
+	return json.Marshal(stored)
+}
+
+// insertAudit stores entry within tx, filling in its ID and timestamp.
+func insertAudit(ctx context.Context, tx *sql.Tx, entry *AuditEntry) error {
+	query := `
+        INSERT INTO audit_log(entity, entity_id, action, actor, request_id, before, after)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at
+    `
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		entry.Entity,
+		entry.EntityID,
+		entry.Action,
+		entry.Actor,
+		entry.RequestID,
+		string(entry.Before),
+		string(entry.After),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// PostgresAuditRepository is an AuditRepository backed by the audit_log table.
+type PostgresAuditRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresAuditRepository creates a repository using the given connection pool.
+func NewPostgresAuditRepository(db *sql.DB) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+// List returns the audit entries matching filter, newest first.
+func (r *PostgresAuditRepository) List(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var args queryArgs
+	var conditions []string
+	if filter.Entity != "" {
+		conditions = append(conditions, "entity = "+args.add(filter.Entity))
+	}
+	if filter.EntityID != "" {
+		if !isUUID(filter.EntityID) {
+			return []*AuditEntry{}, nil
+		}
+		conditions = append(conditions, "entity_id = "+args.add(filter.EntityID)+"::uuid")
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = "+args.add(filter.Actor))
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= "+args.add(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < "+args.add(filter.Until))
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id, entity, entity_id, action, actor, request_id, before, after, created_at
+	FROM audit_log
+	%s
+	ORDER BY created_at DESC, id DESC
+	LIMIT %s OFFSET %s
+	`, where(conditions), args.add(filter.limit()), args.add(filter.Offset))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &entry.Actor, &entry.RequestID, &before, &after, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMemoryAudit(t *testing.T) {
+	t.Parallel()
+
+	models := NewMemory()
+	barista := WithAuditInfo(context.Background(), AuditInfo{Actor: "barista", RequestID: "req-1"})
+
+	created, err := models.Coffees.Create(barista, Coffee{Name: "Geisha", Roast: "light", Price: Money{Amount: 1800, Currency: "EUR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit := *created
+	edit.Price.Amount = 2000
+	if _, err := models.Coffees.Update(barista, edit, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Coffees.Delete(context.Background(), created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Coffees.Restore(barista, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Coffee History", func(t *testing.T) {
+		// Test that every mutation is recorded, newest first, with its actor.
+		entries, err := models.Audit.List(context.Background(), AuditFilter{Entity: EntityCoffee, EntityID: created.ID})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+
+		var actions, actors []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+			actors = append(actors, entry.Actor)
+		}
+
+		if want := []string{ActionRestore, ActionDelete, ActionUpdate, ActionCreate}; !reflect.DeepEqual(actions, want) {
+			t.Errorf("actions = %v, want %v", actions, want)
+		}
+		if want := []string{"barista", SystemActor, "barista", "barista"}; !reflect.DeepEqual(actors, want) {
+			t.Errorf("actors = %v, want %v", actors, want)
+		}
+		if entries[3].RequestID != "req-1" {
+			t.Errorf("RequestID = %q, want req-1", entries[3].RequestID)
+		}
+	})
+
+	t.Run("Snapshots", func(t *testing.T) {
+		// Test that an update records the coffee before and after the change.
+		entries, err := models.Audit.List(context.Background(), AuditFilter{EntityID: created.ID, Limit: 1, Offset: 2})
+		if err != nil || len(entries) != 1 || entries[0].Action != ActionUpdate {
+			t.Fatalf("List() = %v, %v, want the update", entries, err)
+		}
+
+		var before, after struct {
+			Price json.Number `json:"price"`
+		}
+		if err := json.Unmarshal(entries[0].Before, &before); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(entries[0].After, &after); err != nil {
+			t.Fatal(err)
+		}
+
+		if before.Price != "18.00" || after.Price != "20.00" {
+			t.Errorf("price went from %s to %s, want 18.00 to 20.00", before.Price, after.Price)
+		}
+	})
+
+	t.Run("Actor And Time Range", func(t *testing.T) {
+		// Test filtering the trail by actor and by a range that excludes everything.
+		entries, err := models.Audit.List(context.Background(), AuditFilter{Actor: SystemActor})
+		if err != nil || len(entries) != 1 || entries[0].Action != ActionDelete {
+			t.Errorf("List(system) = %v, %v, want the delete", entries, err)
+		}
+
+		entries, err = models.Audit.List(context.Background(), AuditFilter{Until: time.Now().Add(-time.Hour)})
+		if err != nil || len(entries) != 0 {
+			t.Errorf("List(until an hour ago) = %v, %v, want none", entries, err)
+		}
+	})
+}
+
+func TestPostgresAuditList(t *testing.T) {
+	t.Parallel()
+
+	// Test that filters become placeholders and JSON snapshots are read back.
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	since := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(24 * time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE entity = $1 AND entity_id = $2::uuid AND actor = $3 AND created_at >= $4 AND created_at < $5 ORDER BY created_at DESC, id DESC LIMIT $6 OFFSET $7")).
+		WithArgs(EntityCoffee, testCoffeeID, "barista", since, until, DefaultAuditLimit, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "entity", "entity_id", "action", "actor", "request_id", "before", "after", "created_at"}).
+			AddRow(7, EntityCoffee, testCoffeeID, ActionCreate, "barista", "req-1", []byte("null"), []byte(`{"name":"Geisha"}`), since))
+
+	entries, err := NewPostgresAuditRepository(db).List(context.Background(), AuditFilter{
+		Entity:   EntityCoffee,
+		EntityID: testCoffeeID,
+		Actor:    "barista",
+		Since:    since,
+		Until:    until,
+	})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].ID != 7 || string(entries[0].After) != `{"name":"Geisha"}` {
+		t.Errorf("List() = %+v, want the create entry", entries)
+	}
+}
@@ -2,38 +2,220 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// ErrEditConflict is returned when a conditional update loses a race with
+// another writer that modified the same coffee first.
+var ErrEditConflict = errors.New("edit conflict: the coffee was modified by another request")
+
+// CoffeeRepository is the storage contract for coffee products. Get, Update
+// and Delete report a missing coffee as sql.ErrNoRows.
+//
+// Delete only marks a coffee as deleted. Deleted coffees are treated as
+// missing everywhere except by listings with IncludeDeleted, until Restore
+// brings them back or Purge removes those deleted before a cut-off for good.
+type CoffeeRepository interface {
+	List(ctx context.Context, opts ListOptions) (*CoffeePage, error)
+	Search(ctx context.Context, query string, limit int) ([]*SearchResult, error)
+	Get(ctx context.Context, id string) (*Coffee, error)
+	Create(ctx context.Context, coffee Coffee) (*Coffee, error)
+	Update(ctx context.Context, coffee Coffee, version time.Time) (*Coffee, error)
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*Coffee, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
 type Coffee struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	Roast     string    `json:"roast"`
 	Image     string    `json:"image"`
 	Region    string    `json:"region"`
-	Price     float64   `json:"price"`
+	OriginID  string    `json:"origin_id"`
+	Price     Money     `json:"price"`
 	GrindUnit int16     `json:"grind_unit"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set while the coffee is deleted. It is only changed by
+	// Delete and Restore.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// LocalPrice is the price in the currency requested by the client. It is
+	// filled in by Pricing.Quote and never stored.
+	LocalPrice *PriceQuote `json:"local_price,omitempty"`
+	// Origin is the origin named by OriginID, embedded on ?expand=origin by
+	// ExpandOrigins. It is never stored.
+	Origin *Origin `json:"origin,omitempty"`
+	// Variants are the sellable variants of the coffee, attached by
+	// AttachVariants and stored separately by a VariantRepository.
+	Variants []*Variant `json:"variants,omitempty"`
+	// Stock is the stock of the coffee across locations and variants,
+	// attached by AttachStock. It is nil for coffees without stock levels.
+	Stock *StockStatus `json:"stock,omitempty"`
+	// Schedule is the price schedule in effect, set by ApplySchedules. It is
+	// never stored.
+	Schedule *ScheduledPrice `json:"price_schedule,omitempty"`
+	// EffectivePrice is the price of Schedule, which the coffee sells at
+	// instead of Price. It is set by ApplySchedules and never stored.
+	EffectivePrice *Money `json:"-"`
+}
+
+// SellingPrice returns the price the coffee sells at: its scheduled price
+// while a schedule is in effect, otherwise its base price.
+func (c *Coffee) SellingPrice() Money {
+	if c.EffectivePrice != nil {
+		return *c.EffectivePrice
+	}
+
+	return c.Price
+}
+
+// coffeeFields has the fields of Coffee without its JSON methods.
+type coffeeFields Coffee
+
+// MarshalJSON encodes the price as a plain JSON number in major units next to
+// its currency, so clients reading "price" as a number keep working. The price
+// is always the base price; the price the coffee sells at is encoded as the
+// read-only effective_price and effective_currency. A coffee
+// without an origin has a null origin_id. Variants are omitted unless they
+// were attached, in which case a coffee without variants has an empty list.
+func (c Coffee) MarshalJSON() ([]byte, error) {
+	var originID *string
+	if c.OriginID != "" {
+		originID = &c.OriginID
+	}
+
+	var variants *[]*Variant
+	if c.Variants != nil {
+		variants = &c.Variants
+	}
+
+	effective := c.SellingPrice()
+
+	return json.Marshal(struct {
+		coffeeFields
+		Price             json.Number `json:"price"`
+		Currency          string      `json:"currency"`
+		EffectivePrice    json.Number `json:"effective_price"`
+		EffectiveCurrency string      `json:"effective_currency"`
+		OriginID          *string     `json:"origin_id"`
+		Variants          *[]*Variant `json:"variants,omitempty"`
+	}{
+		coffeeFields:      coffeeFields(c),
+		Price:             json.Number(c.Price.String()),
+		Currency:          c.Price.Currency,
+		EffectivePrice:    json.Number(effective.String()),
+		EffectiveCurrency: effective.Currency,
+		OriginID:          originID,
+		Variants:          variants,
+	})
+}
+
+// UnmarshalJSON decodes a numeric price in major units. The currency member is
+// optional and defaults to the current currency, or DefaultCurrency. A price
+// that does not fit the currency is reported as a ValidationErrors. A
+// local_price, origin, variants, stock, price_schedule, effective_price,
+// effective_currency or deleted_at echoed back by the client are ignored, so a
+// coffee read while a schedule is in effect can be written back unchanged.
+// Legacy roast spellings are normalized to roast codes.
+func (c *Coffee) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*coffeeFields
+		Price             json.Number     `json:"price"`
+		Currency          string          `json:"currency"`
+		LocalPrice        json.RawMessage `json:"local_price"`
+		Origin            json.RawMessage `json:"origin"`
+		Variants          json.RawMessage `json:"variants"`
+		Stock             json.RawMessage `json:"stock"`
+		Schedule          json.RawMessage `json:"price_schedule"`
+		EffectivePrice    json.RawMessage `json:"effective_price"`
+		EffectiveCurrency json.RawMessage `json:"effective_currency"`
+		DeletedAt         json.RawMessage `json:"deleted_at"`
+	}{coffeeFields: (*coffeeFields)(c)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	c.Roast = NormalizeRoast(c.Roast)
+
+	currency := strings.ToUpper(strings.TrimSpace(aux.Currency))
+	if currency == "" {
+		currency = c.Price.Currency
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	c.Price = Money{Currency: currency}
+	if aux.Price == "" {
+		return nil
+	}
+
+	price, err := ParseMoney(aux.Price.String(), currency)
+	if err != nil {
+		return ValidationErrors{"price": fmt.Sprintf("must be a decimal amount with at most %d decimal places for %s", CurrencyExponent(currency), currency)}
+	}
+	c.Price = price
+
+	return nil
 }
 
-// GetAllCoffees retrieves all coffee products from the database.
-func (c *Coffee) GetAllCoffees() ([]*Coffee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// PostgresCoffeeRepository is a CoffeeRepository backed by the coffees table.
+type PostgresCoffeeRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresCoffeeRepository creates a repository using the given connection pool.
+func NewPostgresCoffeeRepository(db *sql.DB) *PostgresCoffeeRepository {
+	return &PostgresCoffeeRepository{db: db}
+}
+
+// List retrieves one page of the coffee products matching opts from the
+// database. Filters and sort fields are compiled into parameterized SQL.
+func (r *PostgresCoffeeRepository) List(ctx context.Context, opts ListOptions) (*CoffeePage, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	query := `
-	SELECT id, name, image, roast, region, price, grind_unit, created_at, updated_at
-	FROM coffees
-	`
+	after, err := opts.after()
+	if err != nil {
+		return nil, err
+	}
+
+	var args queryArgs
+	from := opts.from(&args, "coffees")
+	conditions := opts.conditions(&args)
+	countQuery := `SELECT COUNT(*) FROM ` + from + ` ` + where(conditions)
+	countArgs := append(queryArgs(nil), args...)
+
+	if after != nil {
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) > (%s, %s::uuid)", args.add(after.CreatedAt), args.add(after.ID)))
+	}
+
+	limit := opts.pageSize()
 
-	rows, err := db.QueryContext(ctx, query)
+	query := fmt.Sprintf(`
+	SELECT id, name, image, roast, region, COALESCE(origin_id::text, ''), price_minor, currency, grind_unit, created_at, updated_at, deleted_at
+	FROM %s
+	%s
+	ORDER BY %s
+	LIMIT %s OFFSET %s
+	`, from, where(conditions), opts.orderBy(), args.add(limit+1), args.add(opts.Offset))
+
+	// One extra row tells whether another page follows.
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var coffees []*Coffee
+	coffees := make([]*Coffee, 0, limit+1)
 	for rows.Next() {
 		var coffee Coffee
 		if err := rows.Scan(
@@ -42,10 +224,13 @@ func (c *Coffee) GetAllCoffees() ([]*Coffee, error) {
 			&coffee.Image,
 			&coffee.Roast,
 			&coffee.Region,
-			&coffee.Price,
+			&coffee.OriginID,
+			&coffee.Price.Amount,
+			&coffee.Price.Currency,
 			&coffee.GrindUnit,
 			&coffee.CreatedAt,
 			&coffee.UpdatedAt,
+			&coffee.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -57,64 +242,94 @@ func (c *Coffee) GetAllCoffees() ([]*Coffee, error) {
 		return nil, err
 	}
 
-	return coffees, nil
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	return opts.newPage(coffees, total), nil
 }
 
-// CreateCoffee inserts a new coffee product into the database.
-func (c *Coffee) CreateCoffee(coffee Coffee) (*Coffee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// Create inserts a new coffee product into the database and records its
+// creation in the audit trail and its price in the price history.
+func (r *PostgresCoffeeRepository) Create(ctx context.Context, coffee Coffee) (*Coffee, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query := `
-        INSERT INTO coffees(name, image, region, roast, price, grind_unit, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-        RETURNING id
+        INSERT INTO coffees(name, image, region, origin_id, roast, price_minor, currency, grind_unit, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, created_at, updated_at
     `
 
-	var id string
+	now := time.Now()
 
-	err := db.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		coffee.Name,
 		coffee.Image,
 		coffee.Region,
+		nullString(coffee.OriginID),
 		coffee.Roast,
-		coffee.Price,
+		coffee.Price.Amount,
+		coffee.Price.Currency,
 		coffee.GrindUnit,
-		time.Now(),
-		time.Now(),
-	).Scan(&id)
+		now,
+		now,
+	).Scan(&coffee.ID, &coffee.CreatedAt, &coffee.UpdatedAt)
 
 	if err != nil {
 		return nil, err
 	}
 
-	coffee.ID = id
+	if err := auditTx(ctx, tx, ActionCreate, coffee.ID, nil, &coffee); err != nil {
+		return nil, err
+	}
+
+	if err := insertPriceChange(ctx, tx, nil, &coffee); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return &coffee, nil
 }
 
-// GetCoffeeByID retrieves a coffee product by its ID from the database.
-func (c *Coffee) GetCoffeeByID(id string) (*Coffee, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// Get retrieves a coffee product by its ID from the database, unless it was deleted.
+func (r *PostgresCoffeeRepository) Get(ctx context.Context, id string) (*Coffee, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
 	query := `
-        SELECT id, name, image, roast, region, price, grind_unit, created_at, updated_at 
+        SELECT id, name, image, roast, region, COALESCE(origin_id::text, ''), price_minor, currency, grind_unit, created_at, updated_at 
         FROM coffees
-        WHERE id = $1
+        WHERE id = $1 AND deleted_at IS NULL
     `
 	var coffee Coffee
 
-	row := db.QueryRowContext(ctx, query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 	err := row.Scan(
 		&coffee.ID,
 		&coffee.Name,
 		&coffee.Image,
 		&coffee.Roast,
 		&coffee.Region,
-		&coffee.Price,
+		&coffee.OriginID,
+		&coffee.Price.Amount,
+		&coffee.Price.Currency,
 		&coffee.GrindUnit,
 		&coffee.CreatedAt,
 		&coffee.UpdatedAt,
@@ -127,16 +342,254 @@ func (c *Coffee) GetCoffeeByID(id string) (*Coffee, error) {
 	return &coffee, nil
 }
 
-// DeleteCoffee removes a coffee product by its ID from the database.
-func (c *Coffee) DeleteCoffee(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// Delete marks a coffee product as deleted, keeping its row for Restore and
+// for the records that refer to it, and bumps its updated_at.
+func (r *PostgresCoffeeRepository) Delete(ctx context.Context, id string) error {
+	if !isUUID(id) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	query := `DELETE FROM coffees WHERE id = $1`
-	_, err := db.ExecContext(ctx, query, id)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	before, err := lockCoffee(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+
+	after := *before
+	now := time.Now()
+	after.DeletedAt = &now
+	after.UpdatedAt = now
+
+	if _, err := tx.ExecContext(ctx, `UPDATE coffees SET deleted_at = $2, updated_at = $2 WHERE id = $1`, id, now); err != nil {
+		return err
+	}
+
+	if err := auditTx(ctx, tx, ActionDelete, id, before, &after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore undeletes a coffee product. A coffee that is not deleted is
+// reported as sql.ErrNoRows. Restoring counts as a change, so it bumps
+// updated_at.
+func (r *PostgresCoffeeRepository) Restore(ctx context.Context, id string) (*Coffee, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCoffee(ctx, tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	after := *before
+	after.DeletedAt = nil
+	if err := tx.QueryRowContext(ctx, `UPDATE coffees SET deleted_at = NULL, updated_at = $2 WHERE id = $1 RETURNING updated_at`, id, time.Now()).Scan(&after.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := auditTx(ctx, tx, ActionRestore, id, before, &after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &after, nil
+}
+
+// Purge permanently removes the coffee products deleted before deletedBefore,
+// together with the rows that cascade from them, and returns how many
+// coffees were removed. The audit trail keeps their last snapshot.
+func (r *PostgresCoffeeRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM coffees WHERE deleted_at < $1 RETURNING `+coffeeColumns, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged []*Coffee
+	for rows.Next() {
+		coffee, err := scanCoffee(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		purged = append(purged, coffee)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, coffee := range purged {
+		if err := auditTx(ctx, tx, ActionPurge, coffee.ID, coffee, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(purged)), nil
+}
+
+// Update overwrites the editable fields of an existing coffee product that
+// is not deleted and records the change in the audit trail, and in the price
+// history when the price changed.
+// When version is non-zero the write only succeeds if the stored updated_at
+// still equals it; otherwise ErrEditConflict is returned and nothing changes.
+func (r *PostgresCoffeeRepository) Update(ctx context.Context, coffee Coffee, version time.Time) (*Coffee, error) {
+	if !isUUID(coffee.ID) {
+		if !version.IsZero() {
+			return nil, ErrEditConflict
+		}
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCoffee(ctx, tx, coffee.ID, false)
+	if errors.Is(err, sql.ErrNoRows) && !version.IsZero() {
+		return nil, ErrEditConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        UPDATE coffees
+        SET name = $1, image = $2, region = $3, origin_id = $4, roast = $5, price_minor = $6, currency = $7, grind_unit = $8, updated_at = $9
+        WHERE id = $10 AND ($11::timestamptz IS NULL OR updated_at = $11)
+        RETURNING created_at, updated_at
+    `
+
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		coffee.Name,
+		coffee.Image,
+		coffee.Region,
+		nullString(coffee.OriginID),
+		coffee.Roast,
+		coffee.Price.Amount,
+		coffee.Price.Currency,
+		coffee.GrindUnit,
+		time.Now(),
+		coffee.ID,
+		sql.NullTime{Time: version, Valid: !version.IsZero()},
+	).Scan(&coffee.CreatedAt, &coffee.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) && !version.IsZero() {
+		return nil, ErrEditConflict
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	coffee.DeletedAt = nil
+	if err := auditTx(ctx, tx, ActionUpdate, coffee.ID, before, &coffee); err != nil {
+		return nil, err
+	}
+
+	if err := insertPriceChange(ctx, tx, before, &coffee); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &coffee, nil
+}
+
+// coffeeColumns are the stored columns of a coffee, in the order scanCoffee
+// reads them.
+const coffeeColumns = `id, name, image, roast, region, COALESCE(origin_id::text, ''), price_minor, currency, grind_unit, created_at, updated_at, deleted_at`
+
+// scanCoffee reads a coffee selected with coffeeColumns.
+func scanCoffee(row rowScanner) (*Coffee, error) {
+	var coffee Coffee
+	err := row.Scan(
+		&coffee.ID,
+		&coffee.Name,
+		&coffee.Image,
+		&coffee.Roast,
+		&coffee.Region,
+		&coffee.OriginID,
+		&coffee.Price.Amount,
+		&coffee.Price.Currency,
+		&coffee.GrindUnit,
+		&coffee.CreatedAt,
+		&coffee.UpdatedAt,
+		&coffee.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &coffee, nil
+}
+
+// lockCoffee reads a coffee for update within tx. It finds only deleted
+// coffees when deleted is set, and only live ones otherwise.
+func lockCoffee(ctx context.Context, tx *sql.Tx, id string, deleted bool) (*Coffee, error) {
+	condition := "deleted_at IS NULL"
+	if deleted {
+		condition = "deleted_at IS NOT NULL"
+	}
+
+	return scanCoffee(tx.QueryRowContext(ctx, `SELECT `+coffeeColumns+` FROM coffees WHERE id = $1 AND `+condition+` FOR UPDATE`, id))
+}
+
+// auditTx records action on the coffee with the given ID within tx.
+func auditTx(ctx context.Context, tx *sql.Tx, action, id string, before, after *Coffee) error {
+	entry, err := newAuditEntry(ctx, action, id, before, after)
+	if err != nil {
+		return err
+	}
+
+	return insertAudit(ctx, tx, entry)
+}
+
+// nullString maps an empty optional reference to SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
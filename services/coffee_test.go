@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"regexp"
 	"testing"
 	"time"
 
@@ -18,6 +20,12 @@ func setupTestDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
 	return db, mock
 }
 
+// Repositories only query for well-formed UUIDs, so lookups use real ones.
+const (
+	testCoffeeID    = "6f1c2a8e-3b4d-4e5f-8a9b-0c1d2e3f4a5b"
+	missingCoffeeID = "00000000-0000-4000-8000-000000000000"
+)
+
 func TestGetAllCoffees(t *testing.T) {
 	t.Parallel()
 
@@ -32,7 +40,7 @@ func TestGetAllCoffees(t *testing.T) {
 			Image:     "image1.jpg",
 			Roast:     "Medium",
 			Region:    "Brazil",
-			Price:     5.99,
+			Price:     Money{Amount: 599, Currency: "EUR"},
 			GrindUnit: 1,
 		}
 		expectedCoffee2 := &Coffee{
@@ -41,27 +49,33 @@ func TestGetAllCoffees(t *testing.T) {
 			Image:     "image2.jpg",
 			Roast:     "Dark",
 			Region:    "Colombia",
-			Price:     7.99,
+			Price:     Money{Amount: 799, Currency: "EUR"},
 			GrindUnit: 2,
 		}
 
 		// Define mock rows with expected data.
-		expectedRows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "price", "grind_unit", "created_at", "updated_at"}).
-			AddRow(expectedCoffee1.ID, expectedCoffee1.Name, expectedCoffee1.Image, expectedCoffee1.Roast, expectedCoffee1.Region, expectedCoffee1.Price, expectedCoffee1.GrindUnit, time.Now(), time.Now()).
-			AddRow(expectedCoffee2.ID, expectedCoffee2.Name, expectedCoffee2.Image, expectedCoffee2.Roast, expectedCoffee2.Region, expectedCoffee2.Price, expectedCoffee2.GrindUnit, time.Now(), time.Now())
+		expectedRows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "deleted_at"}).
+			AddRow(expectedCoffee1.ID, expectedCoffee1.Name, expectedCoffee1.Image, expectedCoffee1.Roast, expectedCoffee1.Region, expectedCoffee1.OriginID, expectedCoffee1.Price.Amount, expectedCoffee1.Price.Currency, expectedCoffee1.GrindUnit, time.Now(), time.Now(), nil).
+			AddRow(expectedCoffee2.ID, expectedCoffee2.Name, expectedCoffee2.Image, expectedCoffee2.Roast, expectedCoffee2.Region, expectedCoffee2.OriginID, expectedCoffee2.Price.Amount, expectedCoffee2.Price.Currency, expectedCoffee2.GrindUnit, time.Now(), time.Now(), nil)
 
 		mock.ExpectQuery("^SELECT").WillReturnRows(expectedRows)
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
 
 		models := New(db)
 
 		// Call the function and check the results.
-		coffees, err := models.Coffee.GetAllCoffees()
+		page, err := models.Coffees.List(context.Background(), ListOptions{})
 		if err != nil {
-			t.Fatalf("GetAllCoffees error: %v", err)
+			t.Fatalf("List error: %v", err)
+		}
+
+		coffees := page.Coffees
+		if len(coffees) != 2 || page.Total != 2 {
+			t.Errorf("Expected 2 coffees, got %d of %d", len(coffees), page.Total)
 		}
 
-		if len(coffees) != 2 {
-			t.Errorf("Expected 2 coffees, got %d", len(coffees))
+		if page.NextCursor != "" {
+			t.Errorf("Expected no next cursor on the last page, got %q", page.NextCursor)
 		}
 
 		// Check each coffee's attributes
@@ -79,16 +93,17 @@ func TestGetAllCoffees(t *testing.T) {
 		defer db.Close()
 
 		mock.ExpectQuery("^SELECT").WillReturnRows(sqlmock.NewRows([]string{}))
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 
 		models := New(db)
 
-		coffees, err := models.Coffee.GetAllCoffees()
+		page, err := models.Coffees.List(context.Background(), ListOptions{})
 		if err != nil {
-			t.Fatalf("GetAllCoffees error: %v", err)
+			t.Fatalf("List error: %v", err)
 		}
 
-		if len(coffees) != 0 {
-			t.Errorf("Expected 0 coffees, got %d", len(coffees))
+		if len(page.Coffees) != 0 || page.Total != 0 {
+			t.Errorf("Expected 0 coffees, got %d of %d", len(page.Coffees), page.Total)
 		}
 	})
 
@@ -101,7 +116,7 @@ func TestGetAllCoffees(t *testing.T) {
 
 		models := New(db)
 
-		if _, err := models.Coffee.GetAllCoffees(); err == nil {
+		if _, err := models.Coffees.List(context.Background(), ListOptions{}); err == nil {
 			t.Error("Expected an error, but got nil")
 		}
 	})
@@ -115,7 +130,7 @@ func TestGetAllCoffees(t *testing.T) {
 
 		models := New(db)
 
-		if _, err := models.Coffee.GetAllCoffees(); err == nil {
+		if _, err := models.Coffees.List(context.Background(), ListOptions{}); err == nil {
 			t.Error("Expected a timeout error, but got nil")
 		}
 	})
@@ -131,7 +146,7 @@ func TestGetAllCoffees(t *testing.T) {
 
 		models := New(db)
 
-		if _, err := models.Coffee.GetAllCoffees(); err == nil {
+		if _, err := models.Coffees.List(context.Background(), ListOptions{}); err == nil {
 			t.Error("Expected a scan error, but got nil")
 		}
 	})
@@ -147,41 +162,130 @@ func TestGetAllCoffees(t *testing.T) {
 
 		models := New(db)
 
-		if _, err := models.Coffee.GetAllCoffees(); err == nil {
+		if _, err := models.Coffees.List(context.Background(), ListOptions{}); err == nil {
 			t.Error("Expected a scan error, but got nil")
 		}
 	})
 
-	t.Run("Large Result Set", func(t *testing.T) {
-		// Test when retrieving a large result set of coffee products.
+	t.Run("Page Size Limit", func(t *testing.T) {
+		// Test that a large table is returned one bounded page at a time.
 		db, mock := setupTestDB(t)
 		defer db.Close()
 
-		// Create a large number of expected rows (more than the default row limit in sqlmock).
-		expectedRows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "price", "grind_unit", "created_at", "updated_at"})
-		for i := 1; i <= 1_000_000; i++ {
-			expectedRows.AddRow(i, "CoffeeName", "coffee.jpg", "Medium", "Origin", 5.99, 1, time.Now(), time.Now())
+		// The repository asks for one row more than the page size to detect a following page.
+		expectedRows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "deleted_at"})
+		createdAt := time.Now()
+		for i := 1; i <= 11; i++ {
+			expectedRows.AddRow(fmt.Sprint(i), "CoffeeName", "coffee.jpg", "Medium", "Origin", "", 599, "EUR", 1, createdAt, createdAt, nil)
 		}
 
-		mock.ExpectQuery("^SELECT").WillReturnRows(expectedRows)
+		mock.ExpectQuery("^SELECT").WithArgs(11, 0).WillReturnRows(expectedRows)
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1_000_000))
 
 		models := New(db)
 
-		coffees, err := models.Coffee.GetAllCoffees()
+		page, err := models.Coffees.List(context.Background(), ListOptions{Limit: 10})
 		if err != nil {
-			t.Fatalf("GetAllCoffees error: %v", err)
+			t.Fatalf("List error: %v", err)
+		}
+
+		if len(page.Coffees) != 10 || page.Total != 1_000_000 {
+			t.Errorf("Expected 10 of 1,000,000 coffees, got %d of %d", len(page.Coffees), page.Total)
+		}
+
+		if page.NextCursor == "" {
+			t.Error("Expected a next cursor, got none")
+		}
+	})
+
+	t.Run("Cursor Position", func(t *testing.T) {
+		// Test that a cursor resumes the listing after the coffee it points at.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		last := &Coffee{ID: "0a4f8b12-5b0e-4c55-9b71-3bd2f1e1c001", CreatedAt: time.Date(2023, 9, 29, 12, 24, 59, 0, time.UTC)}
+
+		mock.ExpectQuery("^SELECT").
+			WithArgs(last.CreatedAt, last.ID, DefaultPageSize+1, 0).
+			WillReturnRows(sqlmock.NewRows([]string{}))
+		mock.ExpectQuery("^SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		models := New(db)
+
+		if _, err := models.Coffees.List(context.Background(), ListOptions{Cursor: encodeCursor(last)}); err != nil {
+			t.Fatalf("List error: %v", err)
 		}
 
-		if len(coffees) != 1_000_000 {
-			t.Errorf("Expected 1,000,000 coffees, got %d", len(coffees))
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
 		}
 	})
+
+	t.Run("Filters And Sort", func(t *testing.T) {
+		// Test that filters become placeholders, prices the scheduled price and sort fields whitelisted columns.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		priceMin, priceMax := int64(500), int64(1250)
+		pricedAt := time.Now()
+		opts := ListOptions{
+			Roasts:   []string{"light", "medium"},
+			Regions:  []string{"Kenya"},
+			PriceMin: &priceMin,
+			PriceMax: &priceMax,
+			PricedAt: pricedAt,
+			Sort:     []SortField{{Field: "price"}, {Field: "created_at", Desc: true}},
+		}
+
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE deleted_at IS NULL AND lower(roast) IN (lower($2), lower($3)) AND lower(region) IN (lower($4)) AND effective_currency = $5 AND effective_price_minor >= $6 AND effective_price_minor <= $7 ORDER BY effective_price_minor, created_at DESC, id LIMIT $8 OFFSET $9")).
+			WithArgs(pricedAt, "light", "medium", "Kenya", DefaultCurrency, priceMin, priceMax, DefaultPageSize+1, 0).
+			WillReturnRows(sqlmock.NewRows([]string{}))
+		mock.ExpectQuery(regexp.QuoteMeta("COALESCE(s.price_minor, c.price_minor) AS effective_price_minor, COALESCE(s.currency, c.currency) AS effective_currency FROM coffees c LEFT JOIN LATERAL (SELECT price_minor, currency FROM price_schedules WHERE coffee_id = c.id AND effective_from <= $1 AND (effective_to IS NULL OR effective_to > $1) ORDER BY effective_from DESC LIMIT 1) s ON TRUE) AS coffees WHERE deleted_at IS NULL AND lower(roast) IN (lower($2), lower($3)) AND lower(region) IN (lower($4)) AND effective_currency = $5 AND effective_price_minor >= $6 AND effective_price_minor <= $7")).
+			WithArgs(pricedAt, "light", "medium", "Kenya", DefaultCurrency, priceMin, priceMax).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		models := New(db)
+
+		if _, err := models.Coffees.List(context.Background(), opts); err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		// Test that a tampered cursor is rejected before querying.
+		db, _ := setupTestDB(t)
+		defer db.Close()
+
+		models := New(db)
+
+		if _, err := models.Coffees.List(context.Background(), ListOptions{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
+
+// lockedCoffeeRows is the row read by lockCoffee for coffee.
+func lockedCoffeeRows(coffee Coffee) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "deleted_at"}).
+		AddRow(coffee.ID, coffee.Name, coffee.Image, coffee.Roast, coffee.Region, coffee.OriginID, coffee.Price.Amount, coffee.Price.Currency, coffee.GrindUnit, coffee.CreatedAt, coffee.UpdatedAt, coffee.DeletedAt)
+}
+
+// expectAudit expects the audit entry of action on the coffee with the given
+// ID, made without an AuditInfo.
+func expectAudit(mock sqlmock.Sqlmock, action, id string) {
+	mock.ExpectQuery("^INSERT INTO audit_log").
+		WithArgs(EntityCoffee, id, action, SystemActor, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
 }
 
 func TestCreateCoffee(t *testing.T) {
 	t.Parallel()
 	t.Run("Successful Creation", func(t *testing.T) {
-		// Test creating a new coffee product successfully.
+		// Test creating a new coffee product and auditing it in the same transaction.
 
 		db, mock := setupTestDB(t)
 		defer db.Close()
@@ -191,7 +295,7 @@ func TestCreateCoffee(t *testing.T) {
 			Image:     "test.jpg",
 			Region:    "Kenya",
 			Roast:     "Light",
-			Price:     9.99, // Update the expected price value to match the inputCoffee
+			Price:     Money{Amount: 999, Currency: "EUR"}, // Update the expected price value to match the inputCoffee
 			GrindUnit: 1,
 		}
 
@@ -206,19 +310,32 @@ func TestCreateCoffee(t *testing.T) {
 		}
 
 		// Update the expected query to use the correct Price value.
-		mock.ExpectQuery("^INSERT INTO coffees").WithArgs(inputCoffee.Name, inputCoffee.Image, inputCoffee.Region, inputCoffee.Roast, inputCoffee.Price, inputCoffee.GrindUnit, sqlmock.AnyArg(), sqlmock.AnyArg()).
-			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expectedCoffee.ID))
+		mock.ExpectBegin()
+		mock.ExpectQuery("^INSERT INTO coffees").WithArgs(inputCoffee.Name, inputCoffee.Image, inputCoffee.Region, sql.NullString{}, inputCoffee.Roast, inputCoffee.Price.Amount, inputCoffee.Price.Currency, inputCoffee.GrindUnit, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(expectedCoffee.ID, time.Now(), time.Now()))
+		mock.ExpectQuery("^INSERT INTO audit_log").
+			WithArgs(EntityCoffee, expectedCoffee.ID, ActionCreate, "barista", "req-1", "null", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+		mock.ExpectExec("^INSERT INTO price_history").
+			WithArgs(expectedCoffee.ID, inputCoffee.Price.Amount, inputCoffee.Price.Currency, "barista").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		models := New(db)
 
-		createdCoffee, err := models.Coffee.CreateCoffee(inputCoffee)
+		ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "barista", RequestID: "req-1"})
+		createdCoffee, err := models.Coffees.Create(ctx, inputCoffee)
 		if err != nil {
-			t.Fatalf("CreateCoffee error: %v", err)
+			t.Fatalf("Create error: %v", err)
 		}
 
 		if createdCoffee.ID != expectedCoffee.ID || createdCoffee.Name != expectedCoffee.Name {
 			t.Errorf("Mismatch in coffee data: expected %+v, got %+v", expectedCoffee, createdCoffee)
 		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
 	})
 
 	t.Run("Database Error", func(t *testing.T) {
@@ -226,10 +343,12 @@ func TestCreateCoffee(t *testing.T) {
 		db, mock := setupTestDB(t)
 		defer db.Close()
 
+		mock.ExpectBegin()
 		mock.ExpectQuery("^INSERT INTO coffees").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
 
 		models := New(db)
-		if _, err := models.Coffee.CreateCoffee(Coffee{}); err == nil {
+		if _, err := models.Coffees.Create(context.Background(), Coffee{}); err == nil {
 			t.Error("Expected an error, but got nil")
 		}
 	})
@@ -239,12 +358,14 @@ func TestCreateCoffee(t *testing.T) {
 		db, mock := setupTestDB(t)
 		defer db.Close()
 
+		mock.ExpectBegin()
 		mock.ExpectQuery("^INSERT INTO coffees").WillReturnError(context.DeadlineExceeded)
+		mock.ExpectRollback()
 
 		// Create a Models instance with the database connection.
 		models := New(db)
 
-		if _, err := models.Coffee.CreateCoffee(Coffee{}); err == nil {
+		if _, err := models.Coffees.Create(context.Background(), Coffee{}); err == nil {
 			t.Error("Expected a timeout error, but got nil")
 		}
 	})
@@ -254,14 +375,38 @@ func TestCreateCoffee(t *testing.T) {
 		db, mock := setupTestDB(t)
 		defer db.Close()
 
+		mock.ExpectBegin()
 		mock.ExpectQuery("^INSERT INTO coffees").WillReturnError(errors.New("insert error"))
+		mock.ExpectRollback()
 
 		// Create a Models instance with the database connection.
 		models := New(db)
 
-		if _, err := models.Coffee.CreateCoffee(Coffee{}); err == nil {
+		if _, err := models.Coffees.Create(context.Background(), Coffee{}); err == nil {
+			t.Error("Expected an error, but got nil")
+		}
+	})
+
+	t.Run("Audit Error", func(t *testing.T) {
+		// Test that a coffee whose creation cannot be audited is rolled back.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^INSERT INTO coffees").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(testCoffeeID, time.Now(), time.Now()))
+		mock.ExpectQuery("^INSERT INTO audit_log").WillReturnError(errors.New("audit error"))
+		mock.ExpectRollback()
+
+		models := New(db)
+
+		if _, err := models.Coffees.Create(context.Background(), Coffee{}); err == nil {
 			t.Error("Expected an error, but got nil")
 		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
 	})
 
 }
@@ -275,25 +420,25 @@ func TestGetCoffeByID(t *testing.T) {
 		defer db.Close()
 
 		expectedCoffee := &Coffee{
-			ID:        "1",
+			ID:        testCoffeeID,
 			Name:      "TestCoffee",
 			Image:     "test.jpg",
 			Roast:     "Light",
 			Region:    "Kenya",
-			Price:     9.99,
+			Price:     Money{Amount: 999, Currency: "EUR"},
 			GrindUnit: 1,
 		}
 
-		expectedRows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "price", "grind_unit", "created_at", "updated_at"}).
-			AddRow(expectedCoffee.ID, expectedCoffee.Name, expectedCoffee.Image, expectedCoffee.Roast, expectedCoffee.Region, expectedCoffee.Price, expectedCoffee.GrindUnit, time.Now(), time.Now())
+		expectedRows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at"}).
+			AddRow(expectedCoffee.ID, expectedCoffee.Name, expectedCoffee.Image, expectedCoffee.Roast, expectedCoffee.Region, expectedCoffee.OriginID, expectedCoffee.Price.Amount, expectedCoffee.Price.Currency, expectedCoffee.GrindUnit, time.Now(), time.Now())
 
 		mock.ExpectQuery("^SELECT").WithArgs(expectedCoffee.ID).WillReturnRows(expectedRows)
 
 		models := New(db)
 
-		coffee, err := models.Coffee.GetCoffeeByID(expectedCoffee.ID)
+		coffee, err := models.Coffees.Get(context.Background(), expectedCoffee.ID)
 		if err != nil {
-			t.Fatalf("GetCoffeeByID error: %v", err)
+			t.Fatalf("Get error: %v", err)
 		}
 
 		if coffee.ID != expectedCoffee.ID || coffee.Name != expectedCoffee.Name || coffee.Price != expectedCoffee.Price {
@@ -306,13 +451,13 @@ func TestGetCoffeByID(t *testing.T) {
 		db, mock := setupTestDB(t)
 		defer db.Close()
 
-		notToBeFoundID := "10000"
+		notToBeFoundID := missingCoffeeID
 
 		mock.ExpectQuery("^SELECT").WithArgs(notToBeFoundID).WillReturnRows(sqlmock.NewRows([]string{}))
 
 		models := New(db)
 
-		coffee, err := models.Coffee.GetCoffeeByID(notToBeFoundID)
+		coffee, err := models.Coffees.Get(context.Background(), notToBeFoundID)
 		if err == nil {
 			t.Error("Expected an error, but got nil")
 		}
@@ -323,18 +468,34 @@ func TestGetCoffeByID(t *testing.T) {
 
 	})
 
+	t.Run("Malformed ID", func(t *testing.T) {
+		// Test that an ID which cannot be a UUID is not found without querying.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		models := New(db)
+
+		if _, err := models.Coffees.Get(context.Background(), "not-a-uuid"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
 	t.Run("Database Error", func(t *testing.T) {
 		// Test when an error occurs while retrieving a coffee product.
 		db, mock := setupTestDB(t)
 		defer db.Close()
 
-		expectedID := "1"
+		expectedID := testCoffeeID
 
 		mock.ExpectQuery("^SELECT").WithArgs(expectedID).WillReturnError(sql.ErrNoRows)
 
 		models := New(db)
 
-		coffee, err := models.Coffee.GetCoffeeByID(expectedID)
+		coffee, err := models.Coffees.Get(context.Background(), expectedID)
 		if err == nil {
 			t.Error("Expected an error, but got nil")
 		}
@@ -344,3 +505,210 @@ func TestGetCoffeByID(t *testing.T) {
 		}
 	})
 }
+
+func TestUpdateCoffee(t *testing.T) {
+	t.Parallel()
+
+	stored := Coffee{ID: testCoffeeID, Name: "TestCoffee", Roast: "light", Price: Money{Amount: 999, Currency: "EUR"}, CreatedAt: time.Now().Add(-time.Hour), UpdatedAt: time.Now().Add(-time.Minute)}
+
+	t.Run("Successful Update", func(t *testing.T) {
+		// Test updating an existing coffee product and auditing both versions.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		inputCoffee := Coffee{
+			ID:        testCoffeeID,
+			Name:      "UpdatedCoffee",
+			Image:     "updated.jpg",
+			Region:    "Ethiopia",
+			Roast:     "Light",
+			Price:     Money{Amount: 1149, Currency: "EUR"},
+			GrindUnit: 3,
+		}
+
+		createdAt := time.Now().Add(-time.Hour)
+		updatedAt := time.Now()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM coffees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE")).WithArgs(testCoffeeID).WillReturnRows(lockedCoffeeRows(stored))
+		mock.ExpectQuery("^UPDATE coffees").WithArgs(inputCoffee.Name, inputCoffee.Image, inputCoffee.Region, sql.NullString{}, inputCoffee.Roast, inputCoffee.Price.Amount, inputCoffee.Price.Currency, inputCoffee.GrindUnit, sqlmock.AnyArg(), inputCoffee.ID, sql.NullTime{}).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(createdAt, updatedAt))
+		expectAudit(mock, ActionUpdate, testCoffeeID)
+		mock.ExpectExec("^INSERT INTO price_history").
+			WithArgs(testCoffeeID, inputCoffee.Price.Amount, inputCoffee.Price.Currency, SystemActor).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		models := New(db)
+
+		updatedCoffee, err := models.Coffees.Update(context.Background(), inputCoffee, time.Time{})
+		if err != nil {
+			t.Fatalf("Update error: %v", err)
+		}
+
+		if updatedCoffee.Name != inputCoffee.Name || !updatedCoffee.UpdatedAt.Equal(updatedAt) {
+			t.Errorf("Mismatch in coffee data: expected %+v, got %+v", inputCoffee, updatedCoffee)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Coffee Not Found", func(t *testing.T) {
+		// Test when the coffee product to update does not exist or is deleted.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT").WithArgs(missingCoffeeID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		models := New(db)
+
+		if _, err := models.Coffees.Update(context.Background(), Coffee{ID: missingCoffeeID}, time.Time{}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("Stale Version", func(t *testing.T) {
+		// Test when the coffee product was modified after the client read it.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		version := time.Now().Add(-time.Minute)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT").WithArgs(testCoffeeID).WillReturnRows(lockedCoffeeRows(stored))
+		mock.ExpectQuery("^UPDATE coffees").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), testCoffeeID, sql.NullTime{Time: version, Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}))
+		mock.ExpectRollback()
+
+		models := New(db)
+
+		if _, err := models.Coffees.Update(context.Background(), Coffee{ID: testCoffeeID}, version); !errors.Is(err, ErrEditConflict) {
+			t.Errorf("Expected ErrEditConflict, got %v", err)
+		}
+	})
+}
+
+func TestDeleteCoffee(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Successful Deletion", func(t *testing.T) {
+		// Test that deleting an existing coffee product only marks its row.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE id = $1 AND deleted_at IS NULL FOR UPDATE")).WithArgs(testCoffeeID).WillReturnRows(lockedCoffeeRows(Coffee{ID: testCoffeeID}))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE coffees SET deleted_at = $2, updated_at = $2 WHERE id = $1")).WithArgs(testCoffeeID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, ActionDelete, testCoffeeID)
+		mock.ExpectCommit()
+
+		models := New(db)
+
+		if err := models.Coffees.Delete(context.Background(), testCoffeeID); err != nil {
+			t.Errorf("Delete error: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Coffee Not Found", func(t *testing.T) {
+		// Test when the coffee product to delete does not exist or is already deleted.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT").WithArgs(missingCoffeeID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		models := New(db)
+
+		if err := models.Coffees.Delete(context.Background(), missingCoffeeID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestRestoreCoffee(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Successful Restore", func(t *testing.T) {
+		// Test that restoring clears deleted_at and returns the coffee.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		deletedAt := time.Now().Add(-time.Hour)
+		updatedAt := time.Now()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE")).WithArgs(testCoffeeID).
+			WillReturnRows(lockedCoffeeRows(Coffee{ID: testCoffeeID, Name: "TestCoffee", DeletedAt: &deletedAt}))
+		mock.ExpectQuery(regexp.QuoteMeta("SET deleted_at = NULL, updated_at = $2 WHERE id = $1")).WithArgs(testCoffeeID, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(updatedAt))
+		expectAudit(mock, ActionRestore, testCoffeeID)
+		mock.ExpectCommit()
+
+		models := New(db)
+
+		coffee, err := models.Coffees.Restore(context.Background(), testCoffeeID)
+		if err != nil {
+			t.Fatalf("Restore error: %v", err)
+		}
+
+		if coffee.ID != testCoffeeID || coffee.DeletedAt != nil || !coffee.UpdatedAt.Equal(updatedAt) {
+			t.Errorf("Mismatch in coffee data: got %+v", coffee)
+		}
+	})
+
+	t.Run("Coffee Not Deleted", func(t *testing.T) {
+		// Test that a coffee which is missing or not deleted cannot be restored.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("^SELECT").WithArgs(missingCoffeeID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		models := New(db)
+
+		if _, err := models.Coffees.Restore(context.Background(), missingCoffeeID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestPurgeCoffees(t *testing.T) {
+	t.Parallel()
+
+	// Test that coffees deleted before the cut-off are removed and audited with their last state.
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	deletedAt := cutoff.Add(-time.Hour)
+
+	rows := lockedCoffeeRows(Coffee{ID: testCoffeeID, DeletedAt: &deletedAt})
+	rows.AddRow(missingCoffeeID, "", "", "", "", "", 0, "EUR", 0, time.Now(), time.Now(), deletedAt)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM coffees WHERE deleted_at < $1 RETURNING")).WithArgs(cutoff).WillReturnRows(rows)
+	expectAudit(mock, ActionPurge, testCoffeeID)
+	expectAudit(mock, ActionPurge, missingCoffeeID)
+	mock.ExpectCommit()
+
+	models := New(db)
+
+	purged, err := models.Coffees.Purge(context.Background(), cutoff)
+	if err != nil || purged != 2 {
+		t.Errorf("Purge() = %d, %v, want 2 coffees", purged, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
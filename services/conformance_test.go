@@ -0,0 +1,42 @@
+package services_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/davidandw190/coffeeshop-api-go/db"
+	"github.com/davidandw190/coffeeshop-api-go/services"
+	"github.com/davidandw190/coffeeshop-api-go/services/storetest"
+	"github.com/joho/godotenv"
+)
+
+func TestMemoryCoffeeRepositoryConformance(t *testing.T) {
+	t.Parallel()
+
+	storetest.TestCoffeeRepository(t, func(t *testing.T) services.CoffeeRepository {
+		return services.NewMemoryCoffeeRepository()
+	})
+}
+
+func TestPostgresCoffeeRepositoryConformance(t *testing.T) {
+	// The suite truncates tables, so it must not share TEST_DSN with other tests.
+	_ = godotenv.Load("../.env")
+
+	testDSN := os.Getenv("TEST_DSN")
+	if testDSN == "" {
+		t.Skip("TEST_DSN is not set, skipping the PostgreSQL conformance suite")
+	}
+
+	conn, err := db.ConnectPostgres(testDSN)
+	if err != nil {
+		t.Fatalf("ConnectPostgres() error = %v, want nil", err)
+	}
+	defer conn.DB.Close()
+
+	storetest.TestCoffeeRepository(t, func(t *testing.T) services.CoffeeRepository {
+		if _, err := conn.DB.Exec("TRUNCATE coffees CASCADE"); err != nil {
+			t.Fatalf("Failed to reset the coffees table: %v", err)
+		}
+		return services.NewPostgresCoffeeRepository(conn.DB)
+	})
+}
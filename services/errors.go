@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+)
+
+// Kind classifies the errors returned by the services layer so that callers
+// can react to them without knowing which backend produced them.
+type Kind int
+
+const (
+	// KindInternal is any failure the client cannot fix by changing the request.
+	KindInternal Kind = iota
+	// KindInvalid is a malformed request parameter, such as a bad cursor.
+	KindInvalid
+	// KindValidation is a payload rejected field by field, see ValidationErrors.
+	KindValidation
+	// KindNotFound is a missing record, reported by repositories as sql.ErrNoRows.
+	KindNotFound
+	// KindConflict is a write that clashes with existing data, such as a
+	// unique or foreign key violation.
+	KindConflict
+	// KindPrecondition is a conditional write that lost a race, see ErrEditConflict.
+	KindPrecondition
+	// KindUnavailable is a store that did not answer within its deadline.
+	KindUnavailable
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindInvalid:
+		return "invalid"
+	case KindValidation:
+		return "validation"
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindPrecondition:
+		return "precondition"
+	case KindUnavailable:
+		return "unavailable"
+	}
+
+	return "internal"
+}
+
+// Error attaches a Kind to an underlying error.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// NewError wraps err with the given kind.
+func NewError(kind Kind, err error) *Error {
+	return &Error{Kind: kind, Err: err}
+}
+
+// Error returns the message of the underlying error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// PostgreSQL error codes classified by KindOf.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgQueryCanceled       = "57014"
+)
+
+// KindOf classifies err. Errors wrapped in *Error keep their kind, sentinel
+// errors of this package, sql.ErrNoRows, context deadlines and PostgreSQL
+// error codes are recognised, and everything else is KindInternal.
+func KindOf(err error) Kind {
+	var kindErr *Error
+	var validationErr ValidationErrors
+	var pgErr interface{ SQLState() string }
+
+	switch {
+	case errors.As(err, &kindErr):
+		return kindErr.Kind
+	case errors.As(err, &validationErr):
+		return KindValidation
+	case errors.Is(err, sql.ErrNoRows):
+		return KindNotFound
+	case errors.Is(err, ErrEditConflict):
+		return KindPrecondition
+	case errors.Is(err, ErrInvalidCursor), errors.Is(err, ErrInvalidSort), errors.Is(err, ErrEmptySearch),
+		errors.Is(err, ErrNoExchangeRate), errors.Is(err, ErrInvalidAmount):
+		return KindInvalid
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return KindUnavailable
+	case errors.As(err, &pgErr):
+		switch pgErr.SQLState() {
+		case pgUniqueViolation, pgForeignKeyViolation:
+			return KindConflict
+		case pgQueryCanceled:
+			return KindUnavailable
+		}
+	}
+
+	return KindInternal
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// isUUID reports whether id can name a row; anything else cannot exist, so
+// repositories report it as sql.ErrNoRows instead of a database error.
+func isUUID(id string) bool {
+	return uuidPattern.MatchString(id)
+}
+
+// hasSQLState reports whether err carries the given PostgreSQL error code.
+func hasSQLState(err error, code string) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == code
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// sqlStateError mimics the error types of the PostgreSQL drivers.
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pg error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestKindOf(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Known Errors", func(t *testing.T) {
+		// Test the classification of every error the repositories return.
+		cases := []struct {
+			err  error
+			want Kind
+		}{
+			{errors.New("boom"), KindInternal},
+			{sql.ErrNoRows, KindNotFound},
+			{fmt.Errorf("get coffee: %w", sql.ErrNoRows), KindNotFound},
+			{ErrEditConflict, KindPrecondition},
+			{ErrInvalidCursor, KindInvalid},
+			{fmt.Errorf("%w \"x\"", ErrInvalidSort), KindInvalid},
+			{ErrEmptySearch, KindInvalid},
+			{ValidationErrors{"name": "must be provided"}, KindValidation},
+			{context.DeadlineExceeded, KindUnavailable},
+			{sqlStateError(pgUniqueViolation), KindConflict},
+			{sqlStateError(pgForeignKeyViolation), KindConflict},
+			{sqlStateError(pgQueryCanceled), KindUnavailable},
+			{sqlStateError("42P01"), KindInternal},
+			{NewError(KindConflict, errors.New("sku already exists")), KindConflict},
+		}
+
+		for _, tc := range cases {
+			if got := KindOf(tc.err); got != tc.want {
+				t.Errorf("KindOf(%v) = %s, want %s", tc.err, got, tc.want)
+			}
+		}
+	})
+}
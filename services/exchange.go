@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// rateDecimals is the precision exchange rates are stored with.
+const rateDecimals = 12
+
+// ErrNoExchangeRate is returned when a price is requested in a currency that
+// neither has a list price nor an exchange rate from the base currency.
+var ErrNoExchangeRate = errors.New("no exchange rate is configured for the requested currency")
+
+// ExchangeRate states that one unit of Base is worth Rate units of Quote.
+// Rates are maintained by staff; there is no live rate service.
+type ExchangeRate struct {
+	Base      string
+	Quote     string
+	Rate      *big.Rat
+	UpdatedAt time.Time
+}
+
+// ParseRate parses a positive decimal exchange rate such as "0.8612".
+func ParseRate(s string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || strings.Contains(s, "/") || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("%q is not a positive decimal rate", s)
+	}
+
+	return rate, nil
+}
+
+// formatRate renders rate as a decimal without trailing zeros.
+func formatRate(rate *big.Rat) string {
+	s := rate.FloatString(rateDecimals)
+	s = strings.TrimRight(s, "0")
+
+	return strings.TrimSuffix(s, ".")
+}
+
+// Validate checks the currencies and rate supplied by staff.
+func (e *ExchangeRate) Validate() ValidationErrors {
+	v := ValidationErrors{}
+
+	v.check(IsCurrencyCode(e.Base), "base", "must be a three-letter ISO 4217 code")
+	v.check(IsCurrencyCode(e.Quote), "quote", "must be a three-letter ISO 4217 code")
+	v.check(e.Base != e.Quote, "quote", "must differ from base")
+	v.check(e.Rate != nil && e.Rate.Sign() > 0, "rate", "must be greater than zero")
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// Inverse returns the rate converting Quote back into Base.
+func (e ExchangeRate) Inverse() ExchangeRate {
+	return ExchangeRate{Base: e.Quote, Quote: e.Base, Rate: new(big.Rat).Inv(e.Rate), UpdatedAt: e.UpdatedAt}
+}
+
+// Convert expresses m, which must be in the base currency, in the quote
+// currency, rounding half away from zero to the quote's minor unit.
+func (e ExchangeRate) Convert(m Money) (Money, error) {
+	if m.Currency != e.Base {
+		return Money{}, fmt.Errorf("cannot convert %s with a %s/%s rate", m.Currency, e.Base, e.Quote)
+	}
+
+	// Scale from base minor units to quote minor units, then apply the rate.
+	shift := CurrencyExponent(e.Quote) - CurrencyExponent(e.Base)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt(shift))), nil))
+	if shift < 0 {
+		scale.Inv(scale)
+	}
+
+	amount := new(big.Rat).SetInt64(m.Amount)
+	amount.Mul(amount, e.Rate).Mul(amount, scale)
+
+	rounded, ok := roundHalfAway(amount)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: converted amount is out of range", ErrInvalidAmount)
+	}
+
+	return Money{Amount: rounded, Currency: e.Quote}, nil
+}
+
+// roundHalfAway rounds r to the nearest integer, halves away from zero.
+func roundHalfAway(r *big.Rat) (int64, bool) {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+
+	// floor((2*|num| + den) / (2*den)) is |r| rounded half up.
+	q := new(big.Int).Mul(num, big.NewInt(2))
+	q.Add(q, den)
+	q.Quo(q, new(big.Int).Mul(den, big.NewInt(2)))
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	return q.Int64(), q.IsInt64()
+}
+
+// absInt returns |n|.
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// MarshalJSON encodes the rate as a JSON number.
+func (e ExchangeRate) MarshalJSON() ([]byte, error) {
+	var rate json.Number
+	if e.Rate != nil {
+		rate = json.Number(formatRate(e.Rate))
+	}
+
+	return json.Marshal(struct {
+		Base      string      `json:"base"`
+		Quote     string      `json:"quote"`
+		Rate      json.Number `json:"rate"`
+		UpdatedAt time.Time   `json:"updated_at"`
+	}{e.Base, e.Quote, rate, e.UpdatedAt})
+}
+
+// UnmarshalJSON decodes a rate sent as a JSON number or decimal string.
+// Currency codes are upper-cased.
+func (e *ExchangeRate) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Base  string      `json:"base"`
+		Quote string      `json:"quote"`
+		Rate  json.Number `json:"rate"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	e.Base = strings.ToUpper(strings.TrimSpace(aux.Base))
+	e.Quote = strings.ToUpper(strings.TrimSpace(aux.Quote))
+	e.Rate = nil
+	if aux.Rate != "" {
+		rate, err := ParseRate(aux.Rate.String())
+		if err != nil {
+			return ValidationErrors{"rate": "must be a positive decimal number"}
+		}
+		e.Rate = rate
+	}
+
+	return nil
+}
+
+// ReadExchangeRates parses CSV lines of "base,quote,rate", as exported by
+// staff from their treasury sheet. A header line and blank lines are skipped.
+func ReadExchangeRates(r io.Reader) ([]ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var rates []ExchangeRate
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first && strings.EqualFold(record[0], "base") {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		rate, err := ParseRate(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		exchangeRate := ExchangeRate{
+			Base:  strings.ToUpper(strings.TrimSpace(record[0])),
+			Quote: strings.ToUpper(strings.TrimSpace(record[1])),
+			Rate:  rate,
+		}
+		if errs := exchangeRate.Validate(); errs != nil {
+			return nil, fmt.Errorf("line %d: %w", line, errs)
+		}
+
+		rates = append(rates, exchangeRate)
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func mustRate(t *testing.T, base, quote, rate string) ExchangeRate {
+	t.Helper()
+
+	r, err := ParseRate(rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ExchangeRate{Base: base, Quote: quote, Rate: r}
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		rate  ExchangeRate
+		price Money
+		want  Money
+	}{
+		{"Two Decimals", mustRate(t, "EUR", "GBP", "0.8612"), Money{999, "EUR"}, Money{860, "GBP"}},
+		{"Half Rounds Away", mustRate(t, "EUR", "RON", "4.5"), Money{1, "EUR"}, Money{5, "RON"}},
+		{"Negative Half", mustRate(t, "EUR", "RON", "4.5"), Money{-1, "EUR"}, Money{-5, "RON"}},
+		{"Zero Decimal Quote", mustRate(t, "EUR", "JPY", "157.31"), Money{1250, "EUR"}, Money{1966, "JPY"}},
+		{"Three Decimal Quote", mustRate(t, "EUR", "KWD", "0.333"), Money{1000, "EUR"}, Money{3330, "KWD"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Test conversion into the minor unit of the quote currency.
+			got, err := tc.rate.Convert(tc.price)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("Convert(%+v) = %+v, want %+v", tc.price, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Currency Mismatch", func(t *testing.T) {
+		// Test that a price in another currency is refused.
+		if _, err := mustRate(t, "EUR", "GBP", "0.86").Convert(Money{100, "USD"}); err == nil {
+			t.Error("Convert() expected an error, got nil")
+		}
+	})
+
+	t.Run("Inverse", func(t *testing.T) {
+		// Test that the inverse rate is exact.
+		inverse := mustRate(t, "EUR", "RON", "5").Inverse()
+		if inverse.Base != "RON" || inverse.Quote != "EUR" || inverse.Rate.Cmp(big.NewRat(1, 5)) != 0 {
+			t.Errorf("Inverse() = %s/%s %s, want RON/EUR 0.2", inverse.Base, inverse.Quote, inverse.Rate)
+		}
+	})
+}
+
+func TestReadExchangeRates(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Valid File", func(t *testing.T) {
+		// Test header, comment and case handling.
+		input := "base,quote,rate\n# Treasury sheet 2023-10-30\neur,gbp,0.8612\nEUR, RON, 4.9673\n"
+
+		rates, err := ReadExchangeRates(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ReadExchangeRates() error = %v", err)
+		}
+
+		if len(rates) != 2 || rates[0].Base != "EUR" || rates[0].Quote != "GBP" || formatRate(rates[1].Rate) != "4.9673" {
+			t.Errorf("ReadExchangeRates() = %+v, want EUR/GBP 0.8612 and EUR/RON 4.9673", rates)
+		}
+	})
+
+	t.Run("Invalid Line", func(t *testing.T) {
+		// Test that the offending line is reported.
+		_, err := ReadExchangeRates(strings.NewReader("EUR,GBP,0.86\nEUR,RON,-1\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("ReadExchangeRates() error = %v, want a line 2 error", err)
+		}
+	})
+
+	t.Run("Same Currency", func(t *testing.T) {
+		// Test that a rate between a currency and itself is rejected.
+		_, err := ReadExchangeRates(strings.NewReader("EUR,EUR,1\n"))
+
+		var fieldErrors ValidationErrors
+		if !errors.As(err, &fieldErrors) || fieldErrors["quote"] == "" {
+			t.Errorf("ReadExchangeRates() error = %v, want a quote field error", err)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidSort is returned by ParseSort for fields outside the whitelist.
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// sortColumns whitelists the fields a listing may be sorted by, mapping the
+// API name to its column so that user input never reaches the SQL text. The
+// price is the selling price added by ListOptions.from.
+var sortColumns = map[string]string{
+	"name":       "name",
+	"roast":      "roast",
+	"region":     "region",
+	"price":      "effective_price_minor",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// SortField orders a listing by one whitelisted field.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a comma separated sort expression such as
+// "price,-created_at", where a leading minus sorts in descending order.
+func ParseSort(expr string) ([]SortField, error) {
+	var fields []SortField
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, ok := sortColumns[field.Field]; !ok {
+			return nil, fmt.Errorf("%w %q, expected one of %s", ErrInvalidSort, field.Field, strings.Join(sortFieldNames(), ", "))
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// sortFieldNames lists the sortable fields in a stable order.
+func sortFieldNames() []string {
+	names := make([]string, 0, len(sortColumns))
+	for name := range sortColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// queryArgs collects positional arguments while a query is being built.
+type queryArgs []interface{}
+
+// add appends value and returns its $n placeholder.
+func (a *queryArgs) add(value interface{}) string {
+	*a = append(*a, value)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// in renders a case-insensitive IN list comparison against column.
+func (a *queryArgs) in(column string, values []string) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = "lower(" + a.add(value) + ")"
+	}
+
+	return fmt.Sprintf("lower(%s) IN (%s)", column, strings.Join(placeholders, ", "))
+}
+
+// from renders the FROM item of a listing over table, which is returned as is
+// unless the listing filters or sorts on the price. Then each row gains the
+// selling price at pricedAt as effective_price_minor and effective_currency,
+// from the price schedule in effect or else the base price, and keeps the
+// name of table so that conditions can refer to it.
+func (o ListOptions) from(args *queryArgs, table string) string {
+	if !o.byPrice() {
+		return table
+	}
+
+	at := args.add(o.pricedAt())
+
+	return fmt.Sprintf(`(SELECT c.*, COALESCE(s.price_minor, c.price_minor) AS effective_price_minor, COALESCE(s.currency, c.currency) AS effective_currency FROM %[1]s c LEFT JOIN LATERAL (SELECT price_minor, currency FROM price_schedules WHERE coffee_id = c.id AND effective_from <= %[2]s AND (effective_to IS NULL OR effective_to > %[2]s) ORDER BY effective_from DESC LIMIT 1) s ON TRUE) AS %[1]s`, table, at)
+}
+
+// conditions compiles the filters of the listing into SQL predicates.
+func (o ListOptions) conditions(args *queryArgs) []string {
+	var conditions []string
+	if !o.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if len(o.Roasts) > 0 {
+		conditions = append(conditions, args.in("roast", o.Roasts))
+	}
+	if len(o.Regions) > 0 {
+		conditions = append(conditions, args.in("region", o.Regions))
+	}
+	if o.PriceMin != nil || o.PriceMax != nil {
+		conditions = append(conditions, "effective_currency = "+args.add(o.priceCurrency()))
+	}
+	if o.PriceMin != nil {
+		conditions = append(conditions, "effective_price_minor >= "+args.add(*o.PriceMin))
+	}
+	if o.PriceMax != nil {
+		conditions = append(conditions, "effective_price_minor <= "+args.add(*o.PriceMax))
+	}
+	if o.OriginID != "" {
+		if isUUID(o.OriginID) {
+			conditions = append(conditions, "origin_id = "+args.add(o.OriginID)+"::uuid")
+		} else {
+			conditions = append(conditions, "FALSE")
+		}
+	}
+	if o.InStock {
+		conditions = append(conditions, "(NOT EXISTS (SELECT 1 FROM stock_levels s WHERE s.coffee_id = coffees.id) OR EXISTS (SELECT 1 FROM stock_levels s WHERE s.coffee_id = coffees.id AND s.on_hand > s.reserved))")
+	}
+
+	return conditions
+}
+
+// orderBy renders the ORDER BY list of the listing, always ending with id so
+// that rows with equal sort keys keep a stable order between pages.
+func (o ListOptions) orderBy() string {
+	if len(o.Sort) == 0 {
+		return "created_at, id"
+	}
+
+	terms := make([]string, 0, len(o.Sort)+1)
+	for _, field := range o.Sort {
+		term := sortColumns[field.Field]
+		if field.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(append(terms, "id"), ", ")
+}
+
+// where joins predicates into a WHERE clause, or returns "" when there are none.
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// matches reports whether coffee passes the filters of the listing, with the
+// same semantics as conditions. The price schedules must have been applied at
+// pricedAt. InStock needs the inventory and is checked by the memory
+// repository itself.
+func (o ListOptions) matches(coffee *Coffee) bool {
+	if !o.IncludeDeleted && coffee.DeletedAt != nil {
+		return false
+	}
+	if len(o.Roasts) > 0 && !containsFold(o.Roasts, coffee.Roast) {
+		return false
+	}
+	if len(o.Regions) > 0 && !containsFold(o.Regions, coffee.Region) {
+		return false
+	}
+	price := coffee.SellingPrice()
+	if (o.PriceMin != nil || o.PriceMax != nil) && price.Currency != o.priceCurrency() {
+		return false
+	}
+	if o.PriceMin != nil && price.Amount < *o.PriceMin {
+		return false
+	}
+	if o.PriceMax != nil && price.Amount > *o.PriceMax {
+		return false
+	}
+	if o.OriginID != "" && coffee.OriginID != o.OriginID {
+		return false
+	}
+
+	return true
+}
+
+// less orders two coffees like orderBy does.
+func (o ListOptions) less(a, b *Coffee) bool {
+	if len(o.Sort) == 0 {
+		return keysetLess(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	}
+
+	for _, field := range o.Sort {
+		cmp := compareField(field.Field, a, b)
+		if cmp == 0 {
+			continue
+		}
+		if field.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+
+	return a.ID < b.ID
+}
+
+// compareField compares one sortable field of two coffees.
+func compareField(field string, a, b *Coffee) int {
+	switch field {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "roast":
+		return strings.Compare(a.Roast, b.Roast)
+	case "region":
+		return strings.Compare(a.Region, b.Region)
+	case "price":
+		switch priceA, priceB := a.SellingPrice(), b.SellingPrice(); {
+		case priceA.Amount < priceB.Amount:
+			return -1
+		case priceA.Amount > priceB.Amount:
+			return 1
+		}
+		return 0
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+
+	return 0
+}
+
+// containsFold reports whether values contains s, ignoring case.
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Mixed Directions", func(t *testing.T) {
+		// Test parsing ascending and descending fields in order.
+		fields, err := ParseSort("price, -created_at")
+		if err != nil {
+			t.Fatalf("ParseSort error: %v", err)
+		}
+
+		want := []SortField{{Field: "price"}, {Field: "created_at", Desc: true}}
+		if !reflect.DeepEqual(fields, want) {
+			t.Errorf("ParseSort() = %+v, want %+v", fields, want)
+		}
+	})
+
+	t.Run("Empty Expression", func(t *testing.T) {
+		// Test that an empty expression keeps the default order.
+		fields, err := ParseSort("")
+		if err != nil || len(fields) != 0 {
+			t.Errorf("ParseSort() = %+v, %v, want no fields", fields, err)
+		}
+	})
+
+	t.Run("Unknown Field", func(t *testing.T) {
+		// Test that fields outside the whitelist are rejected.
+		if _, err := ParseSort("price;DROP TABLE coffees"); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("Expected ErrInvalidSort, got %v", err)
+		}
+	})
+}
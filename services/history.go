@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// HistoryRepository reconstructs the catalog as it was at past moments. Base
+// prices come from the price history, which has a row for every price a
+// coffee was created or updated with. Names and the other fields come from
+// the snapshots of the audit trail, falling back to the stored coffee for
+// coffees left unchanged since. Scheduled prices are not part of it; they
+// are resolved from the price schedules, which are kept once over.
+type HistoryRepository interface {
+	Prices(ctx context.Context, coffeeID string) ([]*PriceChange, error)
+	List(ctx context.Context, at time.Time, opts ListOptions) (*CoffeePage, error)
+	Get(ctx context.Context, id string, at time.Time) (*Coffee, error)
+}
+
+// PriceChange records the base price a coffee was given at ChangedAt.
+type PriceChange struct {
+	ID        int64
+	CoffeeID  string
+	Price     Money
+	Actor     string
+	ChangedAt time.Time
+}
+
+// MarshalJSON encodes the price as a JSON number in major units next to its
+// currency.
+func (c PriceChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID        int64       `json:"id"`
+		CoffeeID  string      `json:"coffee_id"`
+		Price     json.Number `json:"price"`
+		Currency  string      `json:"currency"`
+		Actor     string      `json:"actor"`
+		ChangedAt time.Time   `json:"changed_at"`
+	}{c.ID, c.CoffeeID, json.Number(c.Price.String()), c.Price.Currency, c.Actor, c.ChangedAt})
+}
+
+// priceChanged reports whether a mutation from before to after gives the
+// coffee a new base price. Creations always do.
+func priceChanged(before, after *Coffee) bool {
+	return after != nil && (before == nil || before.Price != after.Price)
+}
+
+// pastCoffee rebuilds the state of a coffee at a moment from the last audit
+// entry at or before it, the first entry after it and the stored coffee, any
+// of which may be nil. It returns nil when the coffee was not on the menu at
+// that moment: not created yet, deleted, or purged.
+func pastCoffee(at time.Time, last, next *AuditEntry, current *Coffee) (*Coffee, error) {
+	var snapshot json.RawMessage
+	switch {
+	case last != nil:
+		if last.Action == ActionDelete || last.Action == ActionPurge {
+			return nil, nil
+		}
+		snapshot = last.After
+	case next != nil:
+		// Only updates and deletes start from a coffee on the menu.
+		if next.Action != ActionUpdate && next.Action != ActionDelete {
+			return nil, nil
+		}
+		snapshot = next.Before
+	case current != nil:
+		if current.DeletedAt != nil && !current.DeletedAt.After(at) {
+			return nil, nil
+		}
+		coffee := *current
+		coffee.DeletedAt = nil
+		return notBefore(&coffee, at), nil
+	default:
+		return nil, nil
+	}
+
+	var coffee Coffee
+	if err := json.Unmarshal(snapshot, &coffee); err != nil {
+		return nil, err
+	}
+
+	return notBefore(&coffee, at), nil
+}
+
+// notBefore returns coffee, or nil when it was created after at.
+func notBefore(coffee *Coffee, at time.Time) *Coffee {
+	if coffee.CreatedAt.After(at) {
+		return nil
+	}
+
+	return coffee
+}
+
+// pastCatalog rebuilds every coffee on the menu at a moment from the audit
+// entries around it and the stored coffees, all keyed by coffee ID, and
+// gives each the base price it had then.
+func pastCatalog(at time.Time, last, next map[string]*AuditEntry, current map[string]*Coffee, prices map[string]Money) ([]*Coffee, error) {
+	ids := make(map[string]bool, len(current))
+	for _, entries := range []map[string]*AuditEntry{last, next} {
+		for id := range entries {
+			ids[id] = true
+		}
+	}
+	for id := range current {
+		ids[id] = true
+	}
+
+	coffees := make([]*Coffee, 0, len(ids))
+	for id := range ids {
+		coffee, err := pastCoffee(at, last[id], next[id], current[id])
+		if err != nil {
+			return nil, err
+		}
+		if coffee == nil {
+			continue
+		}
+
+		if price, ok := prices[id]; ok {
+			coffee.Price = price
+		}
+		coffees = append(coffees, coffee)
+	}
+
+	return coffees, nil
+}
+
+// PostgresHistoryRepository is a HistoryRepository backed by the
+// price_history and audit_log tables.
+type PostgresHistoryRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresHistoryRepository creates a repository using the given connection pool.
+func NewPostgresHistoryRepository(db *sql.DB) *PostgresHistoryRepository {
+	return &PostgresHistoryRepository{db: db}
+}
+
+// Prices returns the price changes of a coffee, oldest first. Deleted and
+// purged coffees keep their price history.
+func (r *PostgresHistoryRepository) Prices(ctx context.Context, coffeeID string) ([]*PriceChange, error) {
+	if !isUUID(coffeeID) {
+		return []*PriceChange{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+	SELECT id, coffee_id, price_minor, currency, actor, changed_at
+	FROM price_history
+	WHERE coffee_id = $1
+	ORDER BY changed_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*PriceChange{}
+	for rows.Next() {
+		var change PriceChange
+		if err := rows.Scan(&change.ID, &change.CoffeeID, &change.Price.Amount, &change.Price.Currency, &change.Actor, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &change)
+	}
+
+	return changes, rows.Err()
+}
+
+// List returns one page of the coffees on the menu at the given time that
+// match opts. Stock is not historical, so InStock is ignored. The page and
+// its total are read from one snapshot of the database.
+func (r *PostgresHistoryRepository) List(ctx context.Context, at time.Time, opts ListOptions) (*CoffeePage, error) {
+	after, err := opts.after()
+	if err != nil {
+		return nil, err
+	}
+	opts.InStock = false
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var args queryArgs
+	catalog := pastCatalogQuery(&args, at, "")
+	from := opts.from(&args, "catalog")
+	conditions := opts.conditions(&args)
+	countQuery := catalog + ` SELECT COUNT(*) FROM ` + from + ` ` + where(conditions)
+	countArgs := append(queryArgs(nil), args...)
+
+	if after != nil {
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) > (%s, %s::uuid)", args.add(after.CreatedAt), args.add(after.ID)))
+	}
+
+	limit := opts.pageSize()
+
+	query := fmt.Sprintf(`%s
+	SELECT %s
+	FROM %s
+	%s
+	ORDER BY %s
+	LIMIT %s OFFSET %s
+	`, catalog, coffeeColumns, from, where(conditions), opts.orderBy(), args.add(limit+1), args.add(opts.Offset))
+
+	// One extra row tells whether another page follows.
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coffees := make([]*Coffee, 0, limit+1)
+	for rows.Next() {
+		coffee, err := scanCoffee(rows)
+		if err != nil {
+			return nil, err
+		}
+		coffees = append(coffees, coffee)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := tx.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	return opts.newPage(coffees, total), nil
+}
+
+// Get returns a coffee as it was at the given time, or sql.ErrNoRows when it
+// was not on the menu then.
+func (r *PostgresHistoryRepository) Get(ctx context.Context, id string, at time.Time) (*Coffee, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var args queryArgs
+	query := pastCatalogQuery(&args, at, id) + `SELECT ` + coffeeColumns + ` FROM catalog`
+
+	return scanCoffee(r.db.QueryRowContext(ctx, query, args...))
+}
+
+// pastCatalogQuery returns a WITH clause defining catalog, the coffees on the
+// menu at a moment, or only the one with the given ID when it is not empty.
+// It rebuilds them like pastCatalog: from the snapshot of the last audit entry
+// at or before the moment, else from the snapshot before the first entry
+// after it, else from the stored coffee. Every coffee has a price history row
+// from its creation on, so prices always come from the price history.
+func pastCatalogQuery(args *queryArgs, at time.Time, id string) string {
+	atArg, entity := args.add(at), args.add(EntityCoffee)
+	deleted, purged, updated := args.add(ActionDelete), args.add(ActionPurge), args.add(ActionUpdate)
+
+	var auditScope, coffeeScope, priceScope string
+	if id != "" {
+		scope := args.add(id) + "::uuid"
+		auditScope = " AND entity_id = " + scope
+		coffeeScope = " AND c.id = " + scope
+		priceScope = " AND coffee_id = " + scope
+	}
+
+	return `
+	WITH last AS (
+		SELECT DISTINCT ON (entity_id) entity_id, action, after
+		FROM audit_log
+		WHERE created_at <= ` + atArg + ` AND entity = ` + entity + auditScope + `
+		ORDER BY entity_id, created_at DESC, id DESC
+	), next AS (
+		SELECT DISTINCT ON (entity_id) entity_id, action, before
+		FROM audit_log
+		WHERE created_at > ` + atArg + ` AND entity = ` + entity + auditScope + `
+		ORDER BY entity_id, created_at, id
+	), snapshots AS (
+		SELECT COALESCE(last.entity_id, next.entity_id) AS id,
+			CASE
+				WHEN last.entity_id IS NOT NULL THEN CASE WHEN last.action NOT IN (` + deleted + `, ` + purged + `) THEN last.after END
+				WHEN next.action IN (` + updated + `, ` + deleted + `) THEN next.before
+			END AS snapshot
+		FROM last FULL JOIN next ON next.entity_id = last.entity_id
+	), prices AS (
+		SELECT DISTINCT ON (coffee_id) coffee_id, price_minor, currency
+		FROM price_history
+		WHERE changed_at <= ` + atArg + priceScope + `
+		ORDER BY coffee_id, changed_at DESC, id DESC
+	), past AS (
+		SELECT id, snapshot->>'name' AS name, snapshot->>'image' AS image, snapshot->>'roast' AS roast,
+			snapshot->>'region' AS region, (snapshot->>'origin_id')::uuid AS origin_id, (snapshot->>'grind_unit')::int AS grind_unit,
+			(snapshot->>'created_at')::timestamptz AS created_at, (snapshot->>'updated_at')::timestamptz AS updated_at
+		FROM snapshots
+		WHERE snapshot IS NOT NULL
+		UNION ALL
+		SELECT c.id, c.name, c.image, c.roast, c.region, c.origin_id, c.grind_unit, c.created_at, c.updated_at
+		FROM coffees c
+		WHERE NOT EXISTS (SELECT 1 FROM snapshots s WHERE s.id = c.id)
+			AND (c.deleted_at IS NULL OR c.deleted_at > ` + atArg + `)` + coffeeScope + `
+	), catalog AS (
+		SELECT past.*, prices.price_minor, prices.currency, NULL::timestamptz AS deleted_at
+		FROM past JOIN prices ON prices.coffee_id = past.id
+		WHERE past.created_at <= ` + atArg + `
+	)`
+}
+
+// insertPriceChange records within tx the base price after a mutation from
+// before to after, when it changed. The change is timed like the audit entry
+// of the mutation, at the start of the transaction.
+func insertPriceChange(ctx context.Context, tx *sql.Tx, before, after *Coffee) error {
+	if !priceChanged(before, after) {
+		return nil
+	}
+
+	query := `
+        INSERT INTO price_history(coffee_id, price_minor, currency, actor)
+        VALUES ($1, $2, $3, $4)
+    `
+
+	_, err := tx.ExecContext(ctx, query, after.ID, after.Price.Amount, after.Price.Currency, auditInfoFrom(ctx).Actor)
+	return err
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPastCatalog(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC)
+	at := created.Add(24 * time.Hour)
+
+	coffee := func(name string, cents int64) *Coffee {
+		return &Coffee{ID: name, Name: name, Price: Money{cents, "EUR"}, CreatedAt: created}
+	}
+	entry := func(action, id string, before, after *Coffee) *AuditEntry {
+		e, err := newAuditEntry(context.Background(), action, id, before, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	deleted := coffee("deleted", 900)
+	deletedAt := at.Add(-time.Hour)
+	deleted.DeletedAt = &deletedAt
+
+	last := map[string]*AuditEntry{
+		"renamed": entry(ActionUpdate, "renamed", coffee("original", 1000), coffee("renamed", 1200)),
+		"removed": entry(ActionDelete, "removed", coffee("removed", 800), deleted),
+	}
+	next := map[string]*AuditEntry{
+		"edited":   entry(ActionUpdate, "edited", coffee("edited", 1500), coffee("edited-later", 1600)),
+		"new":      entry(ActionCreate, "new", nil, coffee("new", 700)),
+		"restored": entry(ActionRestore, "restored", deleted, coffee("restored", 900)),
+	}
+	current := map[string]*Coffee{
+		"renamed":   coffee("renamed-again", 1300),
+		"untouched": coffee("untouched", 500),
+		"deleted":   deleted,
+	}
+	prices := map[string]Money{"renamed": {1250, "EUR"}}
+
+	coffees, err := pastCatalog(at, last, next, current, prices)
+	if err != nil {
+		t.Fatalf("pastCatalog() error = %v", err)
+	}
+
+	got := make(map[string]Money)
+	for _, c := range coffees {
+		got[c.Name] = c.Price
+	}
+
+	// Test that every coffee is taken from the closest source and that the
+	// price history wins over the snapshot.
+	want := map[string]Money{
+		"renamed":   {1250, "EUR"},
+		"edited":    {1500, "EUR"},
+		"untouched": {500, "EUR"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("pastCatalog() = %v, want %v", got, want)
+	}
+	for name, price := range want {
+		if got[name] != price {
+			t.Errorf("price of %s = %v, want %v", name, got[name], price)
+		}
+	}
+}
+
+func TestMemoryHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	models := NewMemory()
+	before := time.Now().Add(-time.Minute)
+
+	created, err := models.Coffees.Create(ctx, Coffee{Name: "Geisha", Price: Money{Amount: 1800, Currency: "EUR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := *created
+	renamed.Name = "Panama Geisha"
+	if _, err := models.Coffees.Update(ctx, renamed, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	repriced := renamed
+	repriced.Price.Amount = 2000
+	if _, err := models.Coffees.Update(ctx, repriced, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Price Changes", func(t *testing.T) {
+		// Test that only creations and price changes are recorded, oldest first.
+		changes, err := models.History.Prices(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Prices() error = %v", err)
+		}
+
+		if len(changes) != 2 || changes[0].Price.Amount != 1800 || changes[1].Price.Amount != 2000 || changes[1].Actor != SystemActor {
+			t.Errorf("Prices() = %+v, want 18.00 then 20.00", changes)
+		}
+	})
+
+	t.Run("Before Creation", func(t *testing.T) {
+		// Test that a coffee is absent from the menu before it was created.
+		if _, err := models.History.Get(ctx, created.ID, before); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get() error = %v, want sql.ErrNoRows", err)
+		}
+
+		page, err := models.History.List(ctx, before, ListOptions{})
+		if err != nil || page.Total != 0 {
+			t.Errorf("List() = %+v, %v, want an empty menu", page, err)
+		}
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		// Test that a coffee leaves the menu when deleted and its past remains.
+		if err := models.Coffees.Delete(ctx, created.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := models.History.Get(ctx, created.ID, time.Now()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get() after the delete error = %v, want sql.ErrNoRows", err)
+		}
+
+		entries, err := models.Audit.List(ctx, AuditFilter{EntityID: created.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lastUpdate := entries[1].CreatedAt
+
+		coffee, err := models.History.Get(ctx, created.ID, lastUpdate)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if coffee.Name != "Panama Geisha" || coffee.Price.Amount != 2000 {
+			t.Errorf("Get() = %s at %d, want Panama Geisha at 2000", coffee.Name, coffee.Price.Amount)
+		}
+	})
+}
+
+func TestPostgresHistoryGet(t *testing.T) {
+	t.Parallel()
+
+	// Test that the coffee is rebuilt by a single query scoped to its ID.
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	at := time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE created_at <= $1 AND entity = $2 AND entity_id = $6::uuid")).
+		WithArgs(at, EntityCoffee, ActionDelete, ActionPurge, ActionUpdate, testCoffeeID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "deleted_at"}).
+			AddRow(testCoffeeID, "Geisha", "https://example.com/geisha.png", "light", "Boquete", "", 1700, "EUR", 2, at.Add(-time.Hour), at.Add(-time.Hour), nil))
+
+	coffee, err := NewPostgresHistoryRepository(db).Get(context.Background(), testCoffeeID, at)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if coffee.Name != "Geisha" || coffee.Price != (Money{1700, "EUR"}) {
+		t.Errorf("Get() = %s at %v, want Geisha at 17.00 EUR", coffee.Name, coffee.Price)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresHistoryList(t *testing.T) {
+	t.Parallel()
+
+	// Test that filters, keyset ordering and the limit are applied in the
+	// query, and the total is counted within the same snapshot.
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	at := time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC)
+	opts := ListOptions{Roasts: []string{"light"}, Limit: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM catalog WHERE deleted_at IS NULL AND lower(roast) IN (lower($6)) ORDER BY created_at, id LIMIT $7 OFFSET $8")).
+		WithArgs(at, EntityCoffee, ActionDelete, ActionPurge, ActionUpdate, "light", 2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "deleted_at"}).
+			AddRow(testCoffeeID, "Geisha", "https://example.com/geisha.png", "light", "Boquete", "", 1700, "EUR", 2, at.Add(-2*time.Hour), at, nil).
+			AddRow(missingCoffeeID, "Gesha", "https://example.com/gesha.png", "light", "Bench Maji", "", 1900, "EUR", 2, at.Add(-time.Hour), at, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM catalog WHERE deleted_at IS NULL AND lower(roast) IN (lower($6))")).
+		WithArgs(at, EntityCoffee, ActionDelete, ActionPurge, ActionUpdate, "light").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	page, err := NewPostgresHistoryRepository(db).List(context.Background(), at, opts)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(page.Coffees) != 1 || page.Total != 2 || page.NextCursor == "" {
+		t.Errorf("List() = %d coffees of %d, cursor %q, want 1 of 2 with a cursor", len(page.Coffees), page.Total, page.NextCursor)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,618 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInsufficientStock is returned when a reservation or adjustment would
+	// take more stock than is available at a location.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrReservationClosed is returned when releasing or committing a
+	// reservation that was already released or committed.
+	ErrReservationClosed = errors.New("the reservation is no longer held")
+	// ErrReservationOrdered is returned when releasing or committing a
+	// reservation that holds stock for an order, which only the lifecycle of
+	// the order may close.
+	ErrReservationOrdered = errors.New("the reservation belongs to an order")
+)
+
+// DefaultLocation is the stock location used when a request names none.
+const DefaultLocation = "main"
+
+// Adjustment reasons. ReasonSale is also recorded when a reservation is
+// committed.
+const (
+	ReasonReceived = "received"
+	ReasonSale     = "sale"
+	ReasonReturned = "returned"
+	ReasonDamaged  = "damaged"
+	ReasonExpired  = "expired"
+	ReasonCount    = "count"
+	ReasonOther    = "other"
+)
+
+// AdjustmentReasons lists the accepted values of Adjustment.Reason.
+var AdjustmentReasons = []string{ReasonReceived, ReasonSale, ReasonReturned, ReasonDamaged, ReasonExpired, ReasonCount, ReasonOther}
+
+// Reservation states. A reservation is created held and ends either released,
+// returning its quantity, or committed, taking it off the shelf.
+const (
+	ReservationHeld      = "held"
+	ReservationReleased  = "released"
+	ReservationCommitted = "committed"
+)
+
+const (
+	maxLocationLength = 32
+	maxNoteLength     = 500
+)
+
+var locationPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// InventoryRepository tracks stock per coffee, or per variant of a coffee,
+// and location. Every write runs in one transaction that locks the stock
+// level it changes, so concurrent reservations can never oversell.
+// GetReservation, Release and Commit report a missing reservation as
+// sql.ErrNoRows.
+type InventoryRepository interface {
+	Levels(ctx context.Context, coffeeID string) ([]StockLevel, error)
+	Find(ctx context.Context, coffeeIDs []string) (map[string][]StockLevel, error)
+	Adjust(ctx context.Context, adjustment Adjustment) (*StockLevel, error)
+	Adjustments(ctx context.Context, coffeeID string, limit int) ([]Adjustment, error)
+	Reserve(ctx context.Context, reservation Reservation) (*Reservation, error)
+	GetReservation(ctx context.Context, id string) (*Reservation, error)
+	Release(ctx context.Context, id string) (*Reservation, error)
+	Commit(ctx context.Context, id string) (*Reservation, error)
+}
+
+// StockKey names one stock level. An empty VariantID is stock of the coffee
+// itself, for coffees sold without variants.
+type StockKey struct {
+	CoffeeID  string `json:"coffee_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Location  string `json:"location"`
+}
+
+// validate checks the location and variant of the key. The coffee comes from
+// the URL and is checked by the caller.
+func (k *StockKey) validate(v ValidationErrors) {
+	if k.Location == "" {
+		k.Location = DefaultLocation
+	}
+
+	v.check(k.VariantID == "" || isUUID(k.VariantID), "variant_id", "must be the id of a variant of the coffee")
+	v.check(len(k.Location) <= maxLocationLength, "location", fmt.Sprintf("must not be more than %d characters long", maxLocationLength))
+	v.check(locationPattern.MatchString(k.Location), "location", "must contain only lower-case letters, digits, dashes and underscores")
+}
+
+// StockLevel is the stock held at one location. Reserved units are on hand
+// but promised to open reservations.
+type StockLevel struct {
+	StockKey
+	OnHand    int       `json:"on_hand"`
+	Reserved  int       `json:"reserved"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Available returns the units that can still be reserved.
+func (l StockLevel) Available() int {
+	return l.OnHand - l.Reserved
+}
+
+// Adjustment is a change of the on-hand quantity with the reason it happened.
+// ReservationID links sales to the reservation they committed.
+type Adjustment struct {
+	ID string `json:"id"`
+	StockKey
+	Delta         int       `json:"delta"`
+	Reason        string    `json:"reason"`
+	Note          string    `json:"note"`
+	ReservationID string    `json:"reservation_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Validate checks the client supplied fields of an adjustment, defaulting the
+// location, and returns the problems found or nil.
+func (a *Adjustment) Validate() ValidationErrors {
+	v := ValidationErrors{}
+
+	a.StockKey.validate(v)
+	v.check(a.Delta != 0, "delta", "must not be zero")
+	v.check(slices.Contains(AdjustmentReasons, a.Reason), "reason", "must be one of "+strings.Join(AdjustmentReasons, ", "))
+	v.check(utf8.RuneCountInString(a.Note) <= maxNoteLength, "note", fmt.Sprintf("must not be more than %d characters long", maxNoteLength))
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// Reservation holds stock for a customer until it is committed as a sale or
+// released back to the shelf.
+type Reservation struct {
+	ID string `json:"id"`
+	StockKey
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the client supplied fields of a reservation, defaulting the
+// location, and returns the problems found or nil.
+func (r *Reservation) Validate() ValidationErrors {
+	v := ValidationErrors{}
+
+	r.StockKey.validate(v)
+	v.check(r.Quantity > 0, "quantity", "must be greater than zero")
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// StockStatus summarises the stock of a coffee or variant across locations.
+type StockStatus struct {
+	Available int  `json:"available"`
+	InStock   bool `json:"in_stock"`
+}
+
+// AttachStock sets Stock on every coffee and variant that has stock levels,
+// loading the stock of the whole page with a single lookup. A coffee counts
+// the stock of all its variants. Items without stock levels are untracked
+// and keep a nil Stock. Variants must be attached first.
+func AttachStock(ctx context.Context, inventory InventoryRepository, coffees []*Coffee) error {
+	if len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(coffees))
+	for i, coffee := range coffees {
+		ids[i] = coffee.ID
+	}
+
+	found, err := inventory.Find(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, coffee := range coffees {
+		levels := found[coffee.ID]
+		if len(levels) == 0 {
+			continue
+		}
+
+		coffee.Stock = stockStatus(levels, func(StockLevel) bool { return true })
+		for _, variant := range coffee.Variants {
+			variantID := variant.ID
+			if slices.ContainsFunc(levels, func(l StockLevel) bool { return l.VariantID == variantID }) {
+				variant.Stock = stockStatus(levels, func(l StockLevel) bool { return l.VariantID == variantID })
+			}
+		}
+	}
+
+	return nil
+}
+
+// stockStatus sums the available stock of the levels selected by include.
+func stockStatus(levels []StockLevel, include func(StockLevel) bool) *StockStatus {
+	var status StockStatus
+	for _, level := range levels {
+		if include(level) {
+			status.Available += level.Available()
+		}
+	}
+	status.InStock = status.Available > 0
+
+	return &status
+}
+
+// PostgresInventoryRepository is an InventoryRepository backed by the
+// stock_levels, stock_adjustments and stock_reservations tables.
+type PostgresInventoryRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresInventoryRepository creates a repository using the given connection pool.
+func NewPostgresInventoryRepository(db *sql.DB) *PostgresInventoryRepository {
+	return &PostgresInventoryRepository{db: db}
+}
+
+const stockColumns = `coffee_id, COALESCE(variant_id::text, ''), location, on_hand, reserved, updated_at`
+
+// stockKeyCondition matches the stock level of a key given as $1, $2 and $3.
+const stockKeyCondition = `coffee_id = $1 AND variant_id IS NOT DISTINCT FROM $2::uuid AND location = $3`
+
+// scanStockLevel reads a row selected with stockColumns.
+func scanStockLevel(row rowScanner) (*StockLevel, error) {
+	var level StockLevel
+	if err := row.Scan(&level.CoffeeID, &level.VariantID, &level.Location, &level.OnHand, &level.Reserved, &level.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &level, nil
+}
+
+// Levels returns the stock levels of a coffee ordered by variant and location.
+func (r *PostgresInventoryRepository) Levels(ctx context.Context, coffeeID string) ([]StockLevel, error) {
+	found, err := r.Find(ctx, []string{coffeeID})
+	if err != nil {
+		return nil, err
+	}
+
+	if found[coffeeID] == nil {
+		return []StockLevel{}, nil
+	}
+
+	return found[coffeeID], nil
+}
+
+// Find returns the stock levels of the given coffees keyed by coffee ID.
+// Untracked coffees are absent from the map.
+func (r *PostgresInventoryRepository) Find(ctx context.Context, coffeeIDs []string) (map[string][]StockLevel, error) {
+	levels := make(map[string][]StockLevel)
+
+	var args queryArgs
+	placeholders := make([]string, 0, len(coffeeIDs))
+	for _, id := range coffeeIDs {
+		if isUUID(id) {
+			placeholders = append(placeholders, args.add(id)+"::uuid")
+		}
+	}
+	if len(placeholders) == 0 {
+		return levels, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + stockColumns + ` FROM stock_levels WHERE coffee_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY variant_id NULLS FIRST, location`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		level, err := scanStockLevel(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels[level.CoffeeID] = append(levels[level.CoffeeID], *level)
+	}
+
+	return levels, rows.Err()
+}
+
+// Adjust changes the on-hand quantity of a stock level, creating the level on
+// its first adjustment, and records why. Taking stock that is reserved fails
+// with ErrInsufficientStock.
+func (r *PostgresInventoryRepository) Adjust(ctx context.Context, adjustment Adjustment) (*StockLevel, error) {
+	if !isUUID(adjustment.CoffeeID) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	level, err := lockStock(ctx, tx, adjustment.StockKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if level.OnHand+adjustment.Delta < level.Reserved {
+		return nil, NewError(KindConflict, fmt.Errorf("%w: %d on hand, %d reserved", ErrInsufficientStock, level.OnHand, level.Reserved))
+	}
+
+	level.OnHand += adjustment.Delta
+	if err := updateStock(ctx, tx, level); err != nil {
+		return nil, err
+	}
+
+	if err := insertAdjustment(ctx, tx, &adjustment); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return level, nil
+}
+
+// Adjustments returns the latest adjustments of a coffee, newest first.
+func (r *PostgresInventoryRepository) Adjustments(ctx context.Context, coffeeID string, limit int) ([]Adjustment, error) {
+	adjustments := []Adjustment{}
+	if !isUUID(coffeeID) {
+		return adjustments, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+	SELECT id, coffee_id, COALESCE(variant_id::text, ''), location, delta, reason, note, COALESCE(reservation_id::text, ''), created_at
+	FROM stock_adjustments
+	WHERE coffee_id = $1
+	ORDER BY created_at DESC, id
+	LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, coffeeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Adjustment
+		if err := rows.Scan(&a.ID, &a.CoffeeID, &a.VariantID, &a.Location, &a.Delta, &a.Reason, &a.Note, &a.ReservationID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, a)
+	}
+
+	return adjustments, rows.Err()
+}
+
+// Reserve holds quantity units of a stock level, failing with
+// ErrInsufficientStock when fewer are available.
+func (r *PostgresInventoryRepository) Reserve(ctx context.Context, reservation Reservation) (*Reservation, error) {
+	if !isUUID(reservation.CoffeeID) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := reserveTx(ctx, tx, &reservation); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// reserveTx holds stock for reservation within tx and stores it, filling in
+// its ID, status and timestamps.
+func reserveTx(ctx context.Context, tx *sql.Tx, reservation *Reservation) error {
+	level, err := lockStock(ctx, tx, reservation.StockKey, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NewError(KindConflict, fmt.Errorf("%w: %s is not stocked at %s", ErrInsufficientStock, stockItem(reservation.StockKey), reservation.Location))
+	}
+	if err != nil {
+		return err
+	}
+
+	if level.Available() < reservation.Quantity {
+		return NewError(KindConflict, fmt.Errorf("%w: %d available, %d requested", ErrInsufficientStock, level.Available(), reservation.Quantity))
+	}
+
+	level.Reserved += reservation.Quantity
+	if err := updateStock(ctx, tx, level); err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO stock_reservations(coffee_id, variant_id, location, quantity, status, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $6)
+        RETURNING id, created_at, updated_at
+    `
+
+	reservation.Status = ReservationHeld
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		reservation.CoffeeID,
+		nullString(reservation.VariantID),
+		reservation.Location,
+		reservation.Quantity,
+		reservation.Status,
+		time.Now(),
+	).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+}
+
+const reservationColumns = `id, coffee_id, COALESCE(variant_id::text, ''), location, quantity, status, created_at, updated_at`
+
+// scanReservation reads a row selected with reservationColumns.
+func scanReservation(row rowScanner) (*Reservation, error) {
+	var res Reservation
+	if err := row.Scan(&res.ID, &res.CoffeeID, &res.VariantID, &res.Location, &res.Quantity, &res.Status, &res.CreatedAt, &res.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// GetReservation retrieves a reservation by its ID.
+func (r *PostgresInventoryRepository) GetReservation(ctx context.Context, id string) (*Reservation, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	return scanReservation(r.db.QueryRowContext(ctx, `SELECT `+reservationColumns+` FROM stock_reservations WHERE id = $1`, id))
+}
+
+// Release returns the units of a held reservation to the shelf.
+func (r *PostgresInventoryRepository) Release(ctx context.Context, id string) (*Reservation, error) {
+	return r.close(ctx, id, ReservationReleased)
+}
+
+// Commit turns a held reservation into a sale, taking its units off hand and
+// recording a sale adjustment.
+func (r *PostgresInventoryRepository) Commit(ctx context.Context, id string) (*Reservation, error) {
+	return r.close(ctx, id, ReservationCommitted)
+}
+
+// close moves a held reservation to status in one transaction, unless it
+// holds stock for an order.
+func (r *PostgresInventoryRepository) close(ctx context.Context, id, status string) (*Reservation, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ordered bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM order_items WHERE reservation_id = $1)`, id).Scan(&ordered); err != nil {
+		return nil, err
+	}
+	if ordered {
+		return nil, NewError(KindConflict, ErrReservationOrdered)
+	}
+
+	reservation, err := closeTx(ctx, tx, id, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// closeTx moves the held reservation id to status within tx, adjusting its
+// stock level accordingly.
+func closeTx(ctx context.Context, tx *sql.Tx, id, status string) (*Reservation, error) {
+	reservation, err := scanReservation(tx.QueryRowContext(ctx, `SELECT `+reservationColumns+` FROM stock_reservations WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if reservation.Status != ReservationHeld {
+		return nil, NewError(KindConflict, fmt.Errorf("%w: it is %s", ErrReservationClosed, reservation.Status))
+	}
+
+	level, err := lockStock(ctx, tx, reservation.StockKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	level.Reserved -= reservation.Quantity
+	if status == ReservationCommitted {
+		level.OnHand -= reservation.Quantity
+	}
+	if err := updateStock(ctx, tx, level); err != nil {
+		return nil, err
+	}
+
+	if status == ReservationCommitted {
+		sale := Adjustment{StockKey: reservation.StockKey, Delta: -reservation.Quantity, Reason: ReasonSale, ReservationID: reservation.ID}
+		if err := insertAdjustment(ctx, tx, &sale); err != nil {
+			return nil, err
+		}
+	}
+
+	reservation.Status = status
+	err = tx.QueryRowContext(ctx, `UPDATE stock_reservations SET status = $1, updated_at = $2 WHERE id = $3 RETURNING updated_at`, status, time.Now(), id).
+		Scan(&reservation.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// lockStock selects the stock level of key for update within tx. With create
+// set, a missing level is first inserted empty; otherwise it is reported as
+// sql.ErrNoRows. A coffee or variant that does not exist is sql.ErrNoRows too.
+func lockStock(ctx context.Context, tx *sql.Tx, key StockKey, create bool) (*StockLevel, error) {
+	if create {
+		query := `
+        INSERT INTO stock_levels(coffee_id, variant_id, location, on_hand, reserved, updated_at)
+        VALUES ($1, $2, $3, 0, 0, $4)
+        ON CONFLICT DO NOTHING
+    `
+		_, err := tx.ExecContext(ctx, query, key.CoffeeID, nullString(key.VariantID), key.Location, time.Now())
+		if hasSQLState(err, pgForeignKeyViolation) {
+			return nil, sql.ErrNoRows
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := `SELECT ` + stockColumns + ` FROM stock_levels WHERE ` + stockKeyCondition + ` FOR UPDATE`
+
+	return scanStockLevel(tx.QueryRowContext(ctx, query, key.CoffeeID, nullString(key.VariantID), key.Location))
+}
+
+// updateStock writes the quantities of a level locked by lockStock.
+func updateStock(ctx context.Context, tx *sql.Tx, level *StockLevel) error {
+	level.UpdatedAt = time.Now()
+
+	query := `UPDATE stock_levels SET on_hand = $4, reserved = $5, updated_at = $6 WHERE ` + stockKeyCondition
+	_, err := tx.ExecContext(ctx, query, level.CoffeeID, nullString(level.VariantID), level.Location, level.OnHand, level.Reserved, level.UpdatedAt)
+
+	return err
+}
+
+// insertAdjustment records adjustment within tx, filling in its ID and time.
+func insertAdjustment(ctx context.Context, tx *sql.Tx, adjustment *Adjustment) error {
+	query := `
+        INSERT INTO stock_adjustments(coffee_id, variant_id, location, delta, reason, note, reservation_id, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at
+    `
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		adjustment.CoffeeID,
+		nullString(adjustment.VariantID),
+		adjustment.Location,
+		adjustment.Delta,
+		adjustment.Reason,
+		adjustment.Note,
+		nullString(adjustment.ReservationID),
+		time.Now(),
+	).Scan(&adjustment.ID, &adjustment.CreatedAt)
+}
+
+// stockItem describes the coffee or variant of key for error messages.
+func stockItem(key StockKey) string {
+	if key.VariantID != "" {
+		return "variant " + key.VariantID
+	}
+
+	return "coffee " + key.CoffeeID
+}
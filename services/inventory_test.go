@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAdjustmentValidate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default Location", func(t *testing.T) {
+		// Test that an adjustment without a location goes to the default one.
+		adjustment := Adjustment{Delta: 12, Reason: ReasonReceived}
+
+		if errs := adjustment.Validate(); errs != nil {
+			t.Fatalf("Validate() = %v, want nil", errs)
+		}
+		if adjustment.Location != DefaultLocation {
+			t.Errorf("Location = %q, want %q", adjustment.Location, DefaultLocation)
+		}
+	})
+
+	t.Run("Invalid Fields", func(t *testing.T) {
+		// Test that each broken field is reported under its own key.
+		adjustment := Adjustment{StockKey: StockKey{VariantID: "x", Location: "Back Room"}, Reason: "stolen"}
+
+		errs := adjustment.Validate()
+		for _, field := range []string{"variant_id", "location", "delta", "reason"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("Validate() = %v, want an error for %s", errs, field)
+			}
+		}
+	})
+}
+
+func TestMemoryInventoryRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newStore := func(t *testing.T, onHand int) (*MemoryInventoryRepository, StockKey) {
+		coffees := NewMemoryCoffeeRepository()
+		coffee, err := coffees.Create(ctx, Coffee{Name: "Geisha"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inventory := NewMemoryInventoryRepository(coffees)
+		key := StockKey{CoffeeID: coffee.ID, Location: DefaultLocation}
+		if _, err := inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: onHand, Reason: ReasonReceived}); err != nil {
+			t.Fatal(err)
+		}
+
+		return inventory, key
+	}
+
+	level := func(t *testing.T, inventory *MemoryInventoryRepository, key StockKey) StockLevel {
+		levels, err := inventory.Levels(ctx, key.CoffeeID)
+		if err != nil || len(levels) != 1 {
+			t.Fatalf("Levels() = %v, %v, want one level", levels, err)
+		}
+		return levels[0]
+	}
+
+	t.Run("Reserve And Commit", func(t *testing.T) {
+		// Test that a committed reservation leaves the shelf and is recorded as a sale.
+		inventory, key := newStore(t, 5)
+
+		reservation, err := inventory.Reserve(ctx, Reservation{StockKey: key, Quantity: 2})
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		if got := level(t, inventory, key); got.OnHand != 5 || got.Reserved != 2 {
+			t.Errorf("after Reserve() level = %+v, want 5 on hand, 2 reserved", got)
+		}
+
+		if reservation, err = inventory.Commit(ctx, reservation.ID); err != nil || reservation.Status != ReservationCommitted {
+			t.Fatalf("Commit() = %+v, %v", reservation, err)
+		}
+		if got := level(t, inventory, key); got.OnHand != 3 || got.Reserved != 0 {
+			t.Errorf("after Commit() level = %+v, want 3 on hand, 0 reserved", got)
+		}
+
+		adjustments, _ := inventory.Adjustments(ctx, key.CoffeeID, 10)
+		if len(adjustments) != 2 || adjustments[0].Reason != ReasonSale || adjustments[0].Delta != -2 || adjustments[0].ReservationID != reservation.ID {
+			t.Errorf("Adjustments() = %+v, want the sale first", adjustments)
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		// Test that a released reservation returns its units and cannot be committed.
+		inventory, key := newStore(t, 5)
+
+		reservation, _ := inventory.Reserve(ctx, Reservation{StockKey: key, Quantity: 5})
+		if _, err := inventory.Release(ctx, reservation.ID); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+		if got := level(t, inventory, key); got.Available() != 5 {
+			t.Errorf("after Release() level = %+v, want 5 available", got)
+		}
+
+		_, err := inventory.Commit(ctx, reservation.ID)
+		if !errors.Is(err, ErrReservationClosed) || KindOf(err) != KindConflict {
+			t.Errorf("Commit() error = %v, want a conflict wrapping ErrReservationClosed", err)
+		}
+	})
+
+	t.Run("Insufficient Stock", func(t *testing.T) {
+		// Test that neither reservations nor adjustments can take reserved units.
+		inventory, key := newStore(t, 3)
+
+		if _, err := inventory.Reserve(ctx, Reservation{StockKey: key, Quantity: 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := inventory.Reserve(ctx, Reservation{StockKey: key, Quantity: 2}); !errors.Is(err, ErrInsufficientStock) || KindOf(err) != KindConflict {
+			t.Errorf("Reserve() error = %v, want a conflict wrapping ErrInsufficientStock", err)
+		}
+		if _, err := inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: -2, Reason: ReasonDamaged}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("Adjust() error = %v, want ErrInsufficientStock", err)
+		}
+	})
+
+	t.Run("Concurrent Reservations", func(t *testing.T) {
+		// Test that racing customers never reserve more than is on hand.
+		inventory, key := newStore(t, 10)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		held := 0
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := inventory.Reserve(ctx, Reservation{StockKey: key, Quantity: 1}); err == nil {
+					mu.Lock()
+					held++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if held != 10 {
+			t.Errorf("%d reservations held, want 10", held)
+		}
+	})
+}
+
+func TestAttachStock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	coffees := NewMemoryCoffeeRepository()
+	inventory := NewMemoryInventoryRepository(coffees)
+
+	tracked, _ := coffees.Create(ctx, Coffee{Name: "A"})
+	untracked, _ := coffees.Create(ctx, Coffee{Name: "B"})
+	bag := &Variant{ID: "bag"}
+	cup := &Variant{ID: "cup"}
+	tracked.Variants = []*Variant{bag, cup}
+
+	for _, adjustment := range []Adjustment{
+		{StockKey: StockKey{CoffeeID: tracked.ID, VariantID: "bag", Location: "main"}, Delta: 4},
+		{StockKey: StockKey{CoffeeID: tracked.ID, VariantID: "bag", Location: "warehouse"}, Delta: 6},
+	} {
+		if _, err := inventory.Adjust(ctx, adjustment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := AttachStock(ctx, inventory, []*Coffee{tracked, untracked}); err != nil {
+		t.Fatalf("AttachStock() error = %v", err)
+	}
+
+	if tracked.Stock == nil || *tracked.Stock != (StockStatus{Available: 10, InStock: true}) {
+		t.Errorf("coffee Stock = %+v, want 10 available", tracked.Stock)
+	}
+	if bag.Stock == nil || bag.Stock.Available != 10 {
+		t.Errorf("bag Stock = %+v, want 10 available", bag.Stock)
+	}
+	if cup.Stock != nil || untracked.Stock != nil {
+		t.Errorf("untracked Stock = %+v, %+v, want nil", cup.Stock, untracked.Stock)
+	}
+}
+
+func TestListInStock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	models := NewMemory()
+
+	create := func(name string, onHand int) *Coffee {
+		coffee, err := models.Coffees.Create(ctx, Coffee{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if onHand > 0 {
+			key := StockKey{CoffeeID: coffee.ID, Location: DefaultLocation}
+			if _, err := models.Inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: onHand, Reason: ReasonReceived}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := models.Inventory.Reserve(ctx, Reservation{StockKey: key, Quantity: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return coffee
+	}
+
+	create("untracked", 0)
+	create("sold out", 1)
+	create("in stock", 2)
+
+	page, err := models.Coffees.List(ctx, ListOptions{InStock: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, coffee := range page.Coffees {
+		names = append(names, coffee.Name)
+	}
+	if len(names) != 2 || names[0] != "untracked" || names[1] != "in stock" {
+		t.Errorf("List(InStock) = %v, want [untracked in stock]", names)
+	}
+}
+
+func TestPostgresInventoryReserve(t *testing.T) {
+	t.Parallel()
+
+	key := StockKey{CoffeeID: testCoffeeID, Location: DefaultLocation}
+	lock := regexp.QuoteMeta("FROM stock_levels WHERE coffee_id = $1 AND variant_id IS NOT DISTINCT FROM $2::uuid AND location = $3 FOR UPDATE")
+	levelColumns := []string{"coffee_id", "variant_id", "location", "on_hand", "reserved", "updated_at"}
+
+	t.Run("Held", func(t *testing.T) {
+		// Test that the level is locked, reserved and the reservation stored in one transaction.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WillReturnRows(sqlmock.NewRows(levelColumns).AddRow(testCoffeeID, "", DefaultLocation, 5, 1, time.Now()))
+		mock.ExpectExec("^UPDATE stock_levels").WithArgs(testCoffeeID, sqlmock.AnyArg(), DefaultLocation, 5, 3, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO stock_reservations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(missingCoffeeID, time.Now(), time.Now()))
+		mock.ExpectCommit()
+
+		reservation, err := NewPostgresInventoryRepository(db).Reserve(context.Background(), Reservation{StockKey: key, Quantity: 2})
+		if err != nil || reservation.Status != ReservationHeld {
+			t.Fatalf("Reserve() = %+v, %v", reservation, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Oversold", func(t *testing.T) {
+		// Test that a reservation beyond the available units is rolled back.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WillReturnRows(sqlmock.NewRows(levelColumns).AddRow(testCoffeeID, "", DefaultLocation, 5, 4, time.Now()))
+		mock.ExpectRollback()
+
+		_, err := NewPostgresInventoryRepository(db).Reserve(context.Background(), Reservation{StockKey: key, Quantity: 2})
+		if !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("Reserve() error = %v, want ErrInsufficientStock", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestPostgresInventoryRelease(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Ordered", func(t *testing.T) {
+		// Test that a reservation held for an order is left to the order lifecycle.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM order_items WHERE reservation_id = $1)")).
+			WithArgs(testCoffeeID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		_, err := NewPostgresInventoryRepository(db).Release(context.Background(), testCoffeeID)
+		if !errors.Is(err, ErrReservationOrdered) || KindOf(err) != KindConflict {
+			t.Errorf("Release() error = %v, want a conflict wrapping ErrReservationOrdered", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
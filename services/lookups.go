@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RoastLevel is an entry of the roast_levels lookup table. Code is the value
+// stored in Coffee.Roast.
+type RoastLevel struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Position    int    `json:"position"`
+	Description string `json:"description"`
+}
+
+// GrindSetting is an entry of the grind_settings lookup table. Unit is the
+// value stored in Coffee.GrindUnit; higher units are finer.
+type GrindSetting struct {
+	Unit        int16  `json:"unit"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// The lookup tables are the single source of valid roasts and grind units.
+// memoryRoastLevels and memoryGrindSettings only stand in for their seed rows
+// in the in-memory store; validation always asks a LookupRepository.
+
+// memoryRoastLevels are the roast levels seeded by the lookup migration,
+// lightest first.
+var memoryRoastLevels = []RoastLevel{
+	{"light", "Light", 1, "Light brown, no surface oil; bright acidity and origin character."},
+	{"medium", "Medium", 2, "Medium brown, dry surface; balanced acidity, aroma and body."},
+	{"medium-dark", "Medium-Dark", 3, "Rich brown with some oil; heavier body, bittersweet finish."},
+	{"dark", "Dark", 4, "Dark brown to black and oily; low acidity, smoky and bitter notes."},
+}
+
+// memoryGrindSettings are the grind settings seeded by the lookup migration,
+// from whole bean to the finest grind.
+var memoryGrindSettings = []GrindSetting{
+	{0, "whole-bean", "Whole Bean", "Unground beans, for grinding at home."},
+	{1, "extra-coarse", "Extra Coarse", "Cold brew and cowboy coffee."},
+	{2, "coarse", "Coarse", "French press and percolators."},
+	{3, "medium-coarse", "Medium-Coarse", "Chemex and Clever dripper."},
+	{4, "medium", "Medium", "Drip machines and siphon brewers."},
+	{5, "medium-fine", "Medium-Fine", "Pour-over cones and AeroPress."},
+	{6, "fine", "Fine", "Espresso and moka pots."},
+	{7, "extra-fine", "Extra Fine", "Turkish coffee."},
+}
+
+// roastAliases maps legacy spellings found in old rows to roast codes. The
+// lookup migration applies the same mapping to stored coffees.
+var roastAliases = map[string]string{
+	"med":        "medium",
+	"med-dark":   "medium-dark",
+	"mediumdark": "medium-dark",
+}
+
+// NormalizeRoast converts a client supplied roast such as "Medium Dark" or
+// "med" to its roast code. Unknown values are returned lower-cased so that
+// validation can reject them.
+func NormalizeRoast(roast string) string {
+	code := strings.ToLower(strings.TrimSpace(roast))
+	code = strings.NewReplacer(" ", "-", "_", "-").Replace(code)
+	code = strings.TrimSuffix(code, "-roast")
+
+	if alias, ok := roastAliases[code]; ok {
+		return alias
+	}
+
+	return code
+}
+
+// CheckCoffeeLookups adds to errs, which may be nil, the roast and grind unit
+// of a coffee that are not in the lookup tables, and returns the errors found
+// or nil when there are none.
+func CheckCoffeeLookups(ctx context.Context, lookups LookupRepository, coffee *Coffee, errs ValidationErrors) (ValidationErrors, error) {
+	roasts, units, err := lookupValues(ctx, lookups)
+	if err != nil {
+		return nil, err
+	}
+
+	v := ValidationErrors{}
+	for field, message := range errs {
+		v[field] = message
+	}
+
+	v.check(slices.Contains(roasts, coffee.Roast), "roast", "must be one of "+strings.Join(roasts, ", "))
+	v.check(slices.Contains(units, coffee.GrindUnit), "grind_unit", "must be one of "+joinUnits(units))
+
+	if len(v) == 0 {
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+// CheckVariantLookups adds to errs, which may be nil, the grind units of a
+// variant that are not in the grind_settings table, and returns the errors
+// found or nil when there are none.
+func CheckVariantLookups(ctx context.Context, lookups LookupRepository, variant *Variant, errs ValidationErrors) (ValidationErrors, error) {
+	_, units, err := lookupValues(ctx, lookups)
+	if err != nil {
+		return nil, err
+	}
+
+	v := ValidationErrors{}
+	for field, message := range errs {
+		v[field] = message
+	}
+
+	for _, unit := range variant.GrindUnits {
+		v.check(slices.Contains(units, unit), "grind_units", "must only contain "+joinUnits(units))
+	}
+
+	if len(v) == 0 {
+		return nil, nil
+	}
+
+	return v, nil
+}
+
+// lookupValues returns the roast codes and grind units of the lookup tables.
+func lookupValues(ctx context.Context, lookups LookupRepository) ([]string, []int16, error) {
+	roasts, err := lookups.Roasts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grinds, err := lookups.Grinds(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codes := make([]string, len(roasts))
+	for i, roast := range roasts {
+		codes[i] = roast.Code
+	}
+
+	units := make([]int16, len(grinds))
+	for i, grind := range grinds {
+		units[i] = grind.Unit
+	}
+
+	return codes, units, nil
+}
+
+// joinUnits lists grind units for an error message.
+func joinUnits(units []int16) string {
+	parts := make([]string, len(units))
+	for i, unit := range units {
+		parts[i] = strconv.Itoa(int(unit))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// LookupRepository reads the roast level and grind setting lookup tables.
+type LookupRepository interface {
+	Roasts(ctx context.Context) ([]RoastLevel, error)
+	Grinds(ctx context.Context) ([]GrindSetting, error)
+}
+
+// PostgresLookupRepository is a LookupRepository backed by the roast_levels
+// and grind_settings tables.
+type PostgresLookupRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresLookupRepository creates a repository using the given connection pool.
+func NewPostgresLookupRepository(db *sql.DB) *PostgresLookupRepository {
+	return &PostgresLookupRepository{db: db}
+}
+
+// Roasts returns the roast levels, lightest first.
+func (r *PostgresLookupRepository) Roasts(ctx context.Context) ([]RoastLevel, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT code, name, position, description FROM roast_levels ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roasts := []RoastLevel{}
+	for rows.Next() {
+		var roast RoastLevel
+		if err := rows.Scan(&roast.Code, &roast.Name, &roast.Position, &roast.Description); err != nil {
+			return nil, err
+		}
+		roasts = append(roasts, roast)
+	}
+
+	return roasts, rows.Err()
+}
+
+// Grinds returns the grind settings from whole bean to finest.
+func (r *PostgresLookupRepository) Grinds(ctx context.Context) ([]GrindSetting, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT unit, code, name, description FROM grind_settings ORDER BY unit`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grinds := []GrindSetting{}
+	for rows.Next() {
+		var grind GrindSetting
+		if err := rows.Scan(&grind.Unit, &grind.Code, &grind.Name, &grind.Description); err != nil {
+			return nil, err
+		}
+		grinds = append(grinds, grind)
+	}
+
+	return grinds, rows.Err()
+}
+
+// MemoryLookupRepository serves the seed rows of the lookup tables.
+type MemoryLookupRepository struct{}
+
+// NewMemoryLookupRepository creates a lookup repository over the seed data.
+func NewMemoryLookupRepository() *MemoryLookupRepository {
+	return &MemoryLookupRepository{}
+}
+
+// Roasts returns a copy of memoryRoastLevels.
+func (MemoryLookupRepository) Roasts(ctx context.Context) ([]RoastLevel, error) {
+	return append([]RoastLevel(nil), memoryRoastLevels...), nil
+}
+
+// Grinds returns a copy of memoryGrindSettings.
+func (MemoryLookupRepository) Grinds(ctx context.Context) ([]GrindSetting, error) {
+	return append([]GrindSetting(nil), memoryGrindSettings...), nil
+}
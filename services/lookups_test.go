@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNormalizeRoast(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"medium":      "medium",
+		" Medium ":    "medium",
+		"med":         "medium",
+		"Medium Dark": "medium-dark",
+		"medium_dark": "medium-dark",
+		"Med-Dark":    "medium-dark",
+		"Dark Roast":  "dark",
+		"burnt":       "burnt",
+		"":            "",
+	}
+
+	for input, want := range cases {
+		if got := NormalizeRoast(input); got != want {
+			t.Errorf("NormalizeRoast(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	t.Run("Decoded Coffees", func(t *testing.T) {
+		// Test that roasts sent by clients are stored as codes.
+		var coffee Coffee
+		if err := json.Unmarshal([]byte(`{"roast": "Med"}`), &coffee); err != nil {
+			t.Fatal(err)
+		}
+
+		if coffee.Roast != "medium" {
+			t.Errorf("json.Unmarshal() roast = %q, want medium", coffee.Roast)
+		}
+	})
+}
+
+func TestCheckCoffeeLookups(t *testing.T) {
+	t.Parallel()
+
+	lookups := NewMemoryLookupRepository()
+
+	t.Run("Known Values", func(t *testing.T) {
+		// Test that a roast and grind unit from the lookup tables pass.
+		coffee := &Coffee{Roast: "medium-dark", GrindUnit: 7}
+
+		errs, err := CheckCoffeeLookups(context.Background(), lookups, coffee, nil)
+		if err != nil || errs != nil {
+			t.Errorf("CheckCoffeeLookups() = %v, %v, want nil", errs, err)
+		}
+	})
+
+	t.Run("Unknown Values", func(t *testing.T) {
+		// Test that values missing from the lookup tables are added to earlier errors.
+		coffee := &Coffee{Roast: "burnt", GrindUnit: 8}
+
+		errs, err := CheckCoffeeLookups(context.Background(), lookups, coffee, ValidationErrors{"price": "must be greater than zero"})
+		if err != nil {
+			t.Fatalf("CheckCoffeeLookups() error = %v", err)
+		}
+
+		for _, field := range []string{"price", "roast", "grind_unit"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("CheckCoffeeLookups() = %v, want an error for %s", errs, field)
+			}
+		}
+	})
+}
+
+func TestCheckVariantLookups(t *testing.T) {
+	t.Parallel()
+
+	// Test that every offered grind unit must be in the lookup table.
+	variant := &Variant{GrindUnits: []int16{0, 6, -1}}
+
+	errs, err := CheckVariantLookups(context.Background(), NewMemoryLookupRepository(), variant, nil)
+	if err != nil {
+		t.Fatalf("CheckVariantLookups() error = %v", err)
+	}
+
+	if _, ok := errs["grind_units"]; !ok {
+		t.Errorf("CheckVariantLookups() = %v, want an error for grind_units", errs)
+	}
+}
+
+func TestLookupRoasts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Ordered By Position", func(t *testing.T) {
+		// Test that roast levels are read in display order.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectQuery("^SELECT code, name, position, description FROM roast_levels ORDER BY position").
+			WillReturnRows(sqlmock.NewRows([]string{"code", "name", "position", "description"}).
+				AddRow("light", "Light", 1, "Bright").
+				AddRow("dark", "Dark", 4, "Smoky"))
+
+		roasts, err := NewPostgresLookupRepository(db).Roasts(context.Background())
+		if err != nil {
+			t.Fatalf("Roasts() error = %v", err)
+		}
+
+		if len(roasts) != 2 || roasts[0].Code != "light" || roasts[1].Position != 4 {
+			t.Errorf("Roasts() = %+v, want light then dark", roasts)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %s", err)
+		}
+	})
+}
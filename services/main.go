@@ -5,19 +5,75 @@ import (
 	"time"
 )
 
-var db *sql.DB
-
 const dbTimeout = time.Second * 3
 
 // Models contains instances of data models and services.
 type Models struct {
 	DB           *sql.DB
-	Coffee       Coffee
+	Coffees      CoffeeRepository
+	Prices       PriceRepository
+	Rates        RateRepository
+	Lookups      LookupRepository
+	Origins      OriginRepository
+	Variants     VariantRepository
+	Inventory    InventoryRepository
+	Reorder      ReorderRepository
+	Audit        AuditRepository
+	Schedules    ScheduleRepository
+	History      HistoryRepository
+	Orders       OrderRepository
 	JsonResponse JsonResponse
 }
 
-// New creates a new Models instance with the given database connection pool.
+// New creates a new Models instance with repositories bound to the given
+// database connection pool.
 func New(dbPool *sql.DB) Models {
-	db = dbPool
-	return Models{}
+	return Models{
+		DB:        dbPool,
+		Coffees:   NewPostgresCoffeeRepository(dbPool),
+		Prices:    NewPostgresPriceRepository(dbPool),
+		Rates:     NewPostgresRateRepository(dbPool),
+		Lookups:   NewPostgresLookupRepository(dbPool),
+		Origins:   NewPostgresOriginRepository(dbPool),
+		Variants:  NewPostgresVariantRepository(dbPool),
+		Inventory: NewPostgresInventoryRepository(dbPool),
+		Reorder:   NewPostgresReorderRepository(dbPool),
+		Audit:     NewPostgresAuditRepository(dbPool),
+		Schedules: NewPostgresScheduleRepository(dbPool),
+		History:   NewPostgresHistoryRepository(dbPool),
+		Orders:    NewPostgresOrderRepository(dbPool),
+	}
+}
+
+// NewMemory creates a Models instance whose repositories live in process
+// memory, for tests and running the API without a database.
+func NewMemory() Models {
+	coffees := NewMemoryCoffeeRepository()
+	coffees.inventory = NewMemoryInventoryRepository(coffees)
+	coffees.audit = NewMemoryAuditRepository()
+	coffees.history = NewMemoryHistoryRepository(coffees)
+	variants := NewMemoryVariantRepository(coffees)
+	schedules := NewMemoryScheduleRepository(coffees)
+	coffees.schedules = schedules
+
+	return Models{
+		Coffees:   coffees,
+		Prices:    NewMemoryPriceRepository(),
+		Rates:     NewMemoryRateRepository(),
+		Lookups:   NewMemoryLookupRepository(),
+		Origins:   NewMemoryOriginRepository(coffees),
+		Variants:  variants,
+		Inventory: coffees.inventory,
+		Reorder:   NewMemoryReorderRepository(coffees, coffees.inventory),
+		Audit:     coffees.audit,
+		Schedules: schedules,
+		History:   coffees.history,
+		Orders:    NewMemoryOrderRepository(coffees, variants, schedules, coffees.inventory),
+	}
+}
+
+// Pricing returns the currency conversion service over the price lists and
+// exchange rates of m.
+func (m Models) Pricing() Pricing {
+	return Pricing{Prices: m.Prices, Rates: m.Rates}
 }
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Independent Pools", func(t *testing.T) {
+		// Test that two Models instances never query each other's database.
+		firstDB, firstMock := setupTestDB(t)
+		defer firstDB.Close()
+		secondDB, secondMock := setupTestDB(t)
+		defer secondDB.Close()
+
+		for _, expect := range []struct {
+			mock sqlmock.Sqlmock
+			id   string
+		}{{firstMock, testCoffeeID}, {secondMock, missingCoffeeID}} {
+			expect.mock.ExpectBegin()
+			expect.mock.ExpectQuery("^SELECT").WithArgs(expect.id).WillReturnRows(lockedCoffeeRows(Coffee{ID: expect.id}))
+			expect.mock.ExpectExec("^UPDATE coffees SET deleted_at").WithArgs(expect.id, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(expect.mock, ActionDelete, expect.id)
+			expect.mock.ExpectCommit()
+		}
+
+		first := New(firstDB)
+		second := New(secondDB)
+
+		if err := second.Coffees.Delete(context.Background(), missingCoffeeID); err != nil {
+			t.Fatalf("Delete error: %v", err)
+		}
+		if err := first.Coffees.Delete(context.Background(), testCoffeeID); err != nil {
+			t.Fatalf("Delete error: %v", err)
+		}
+
+		if err := firstMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("first pool: %v", err)
+		}
+		if err := secondMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("second pool: %v", err)
+		}
+	})
+}
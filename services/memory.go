@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryCoffeeRepository is a thread-safe CoffeeRepository that keeps coffees
+// in process memory. It backs tests and the --store=memory demo mode and
+// mirrors the PostgreSQL repository, including sql.ErrNoRows for unknown IDs.
+type MemoryCoffeeRepository struct {
+	mu      sync.RWMutex
+	coffees map[string]Coffee
+	clock   time.Time
+
+	// inventory answers ListOptions.InStock; without one every coffee is
+	// treated as untracked.
+	inventory *MemoryInventoryRepository
+	// audit receives the audit trail of the mutations, when set.
+	audit *MemoryAuditRepository
+	// history receives the price changes, when set together with audit.
+	history *MemoryHistoryRepository
+	// schedules set the selling price that listings filter and sort on;
+	// without them every coffee sells at its base price.
+	schedules *MemoryScheduleRepository
+}
+
+// NewMemoryCoffeeRepository creates an empty in-memory repository.
+func NewMemoryCoffeeRepository() *MemoryCoffeeRepository {
+	return &MemoryCoffeeRepository{coffees: make(map[string]Coffee)}
+}
+
+// List returns one page of the stored coffees matching opts.
+func (m *MemoryCoffeeRepository) List(ctx context.Context, opts ListOptions) (*CoffeePage, error) {
+	m.mu.RLock()
+	coffees := make([]*Coffee, 0, len(m.coffees))
+	for _, coffee := range m.coffees {
+		coffee := coffee
+		if !opts.InStock || m.inventory == nil || m.inventory.sellable(coffee.ID) {
+			coffees = append(coffees, &coffee)
+		}
+	}
+	m.mu.RUnlock()
+
+	// The schedules look coffees up under their own lock, so they are
+	// consulted once the lock of the repository is released.
+	if err := m.price(ctx, coffees, opts); err != nil {
+		return nil, err
+	}
+
+	return opts.page(coffees)
+}
+
+// price applies the price schedules in effect at the pricing time of opts to
+// coffees when the listing filters or sorts on the price.
+func (m *MemoryCoffeeRepository) price(ctx context.Context, coffees []*Coffee, opts ListOptions) error {
+	if m.schedules == nil || !opts.byPrice() {
+		return nil
+	}
+
+	return ApplySchedules(ctx, m.schedules, coffees, opts.pricedAt())
+}
+
+// keysetLess orders coffees by (created_at, id), like the PostgreSQL listing.
+func keysetLess(createdA time.Time, idA string, createdB time.Time, idB string) bool {
+	if !createdA.Equal(createdB) {
+		return createdA.Before(createdB)
+	}
+
+	return idA < idB
+}
+
+// Get returns the coffee with the given ID, unless it was deleted.
+func (m *MemoryCoffeeRepository) Get(ctx context.Context, id string) (*Coffee, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	coffee, ok := m.coffees[id]
+	if !ok || coffee.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return &coffee, nil
+}
+
+// Create stores a new coffee under a freshly generated UUID.
+func (m *MemoryCoffeeRepository) Create(ctx context.Context, coffee Coffee) (*Coffee, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.tick()
+	coffee.LocalPrice, coffee.Origin, coffee.Variants, coffee.Stock, coffee.Schedule, coffee.EffectivePrice = nil, nil, nil, nil, nil, nil
+	coffee.DeletedAt = nil
+	coffee.ID = id
+	coffee.CreatedAt = now
+	coffee.UpdatedAt = now
+
+	if err := m.record(ctx, ActionCreate, id, nil, &coffee); err != nil {
+		return nil, err
+	}
+	m.coffees[id] = coffee
+
+	return &coffee, nil
+}
+
+// Update overwrites a stored coffee, enforcing version like the PostgreSQL
+// repository does.
+func (m *MemoryCoffeeRepository) Update(ctx context.Context, coffee Coffee, version time.Time) (*Coffee, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.coffees[coffee.ID]
+	ok = ok && stored.DeletedAt == nil
+	if !version.IsZero() && (!ok || !stored.UpdatedAt.Equal(version)) {
+		return nil, ErrEditConflict
+	}
+
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	now := m.tick()
+	coffee.LocalPrice, coffee.Origin, coffee.Variants, coffee.Stock, coffee.Schedule, coffee.EffectivePrice = nil, nil, nil, nil, nil, nil
+	coffee.DeletedAt = nil
+	coffee.CreatedAt = stored.CreatedAt
+	coffee.UpdatedAt = now
+
+	if err := m.record(ctx, ActionUpdate, coffee.ID, &stored, &coffee); err != nil {
+		return nil, err
+	}
+	m.coffees[coffee.ID] = coffee
+
+	return &coffee, nil
+}
+
+// Delete marks the coffee with the given ID as deleted, bumping its updated_at.
+func (m *MemoryCoffeeRepository) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coffee, ok := m.coffees[id]
+	if !ok || coffee.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	deleted := coffee
+	now := m.tick()
+	deleted.DeletedAt = &now
+	deleted.UpdatedAt = now
+
+	if err := m.record(ctx, ActionDelete, id, &coffee, &deleted); err != nil {
+		return err
+	}
+	m.coffees[id] = deleted
+
+	return nil
+}
+
+// Restore undeletes the coffee with the given ID, bumping its updated_at.
+func (m *MemoryCoffeeRepository) Restore(ctx context.Context, id string) (*Coffee, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coffee, ok := m.coffees[id]
+	if !ok || coffee.DeletedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	restored := coffee
+	restored.DeletedAt = nil
+	restored.UpdatedAt = m.tick()
+
+	if err := m.record(ctx, ActionRestore, id, &coffee, &restored); err != nil {
+		return nil, err
+	}
+	m.coffees[id] = restored
+
+	return &restored, nil
+}
+
+// Purge drops the coffees deleted before deletedBefore. Unlike the coffees
+// table it does not cascade to the companion repositories, whose records of
+// purged coffees simply become unreachable.
+func (m *MemoryCoffeeRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, coffee := range m.coffees {
+		if coffee.DeletedAt != nil && coffee.DeletedAt.Before(deletedBefore) {
+			coffee := coffee
+			if err := m.record(ctx, ActionPurge, id, &coffee, nil); err != nil {
+				return purged, err
+			}
+			delete(m.coffees, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// record adds a mutation to the audit trail, if the repository has one, and
+// a new price to the price history, timed like the audit entry. The caller
+// must hold the write lock.
+func (m *MemoryCoffeeRepository) record(ctx context.Context, action, id string, before, after *Coffee) error {
+	if m.audit == nil {
+		return nil
+	}
+
+	entry, err := newAuditEntry(ctx, action, id, before, after)
+	if err != nil {
+		return err
+	}
+	m.audit.record(entry)
+
+	if m.history != nil && priceChanged(before, after) {
+		m.history.record(&PriceChange{CoffeeID: id, Price: after.Price, Actor: entry.Actor, ChangedAt: entry.CreatedAt})
+	}
+
+	return nil
+}
+
+// references reports whether any stored coffee, deleted or not, belongs to
+// the origin.
+func (m *MemoryCoffeeRepository) references(originID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, coffee := range m.coffees {
+		if coffee.OriginID == originID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tick returns the current time at the microsecond precision PostgreSQL
+// stores timestamps with. Writes landing within the same microsecond are
+// pushed apart so that creation order and updated_at, which doubles as the
+// ETag, stay distinguishable. The caller must hold the write lock.
+func (m *MemoryCoffeeRepository) tick() time.Time {
+	now := time.Now().Truncate(time.Microsecond)
+	if !now.After(m.clock) {
+		now = m.clock.Add(time.Microsecond)
+	}
+	m.clock = now
+
+	return now
+}
+
+// newUUID generates a random RFC 4122 version 4 UUID, matching the
+// uuid_generate_v4() default of the coffees table.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryReorderRepository is a thread-safe ReorderRepository kept in process
+// memory, reading stock from the companion inventory. Like the
+// reorder_thresholds table it only accepts thresholds of stored coffees and
+// drops the thresholds of deleted ones from its checks.
+type MemoryReorderRepository struct {
+	mu         sync.Mutex
+	thresholds map[string]int
+	alerted    map[string]map[string]time.Time
+	coffees    *MemoryCoffeeRepository
+	inventory  *MemoryInventoryRepository
+}
+
+// NewMemoryReorderRepository creates an empty in-memory reorder repository
+// over coffees and inventory.
+func NewMemoryReorderRepository(coffees *MemoryCoffeeRepository, inventory *MemoryInventoryRepository) *MemoryReorderRepository {
+	return &MemoryReorderRepository{
+		thresholds: make(map[string]int),
+		alerted:    make(map[string]map[string]time.Time),
+		coffees:    coffees,
+		inventory:  inventory,
+	}
+}
+
+// Threshold returns the reorder threshold of a coffee.
+func (m *MemoryReorderRepository) Threshold(ctx context.Context, coffeeID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	threshold, ok := m.thresholds[coffeeID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+
+	return threshold, nil
+}
+
+// SetThreshold creates or replaces the reorder threshold of a coffee.
+func (m *MemoryReorderRepository) SetThreshold(ctx context.Context, coffeeID string, threshold int) error {
+	if _, err := m.coffees.Get(ctx, coffeeID); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.thresholds[coffeeID] = threshold
+
+	return nil
+}
+
+// DeleteThreshold stops checking the stock of a coffee.
+func (m *MemoryReorderRepository) DeleteThreshold(ctx context.Context, coffeeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.thresholds[coffeeID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.thresholds, coffeeID)
+	delete(m.alerted, coffeeID)
+
+	return nil
+}
+
+// Checks returns the stock check of every coffee with a reorder threshold,
+// ordered by coffee name.
+func (m *MemoryReorderRepository) Checks(ctx context.Context) ([]StockCheck, error) {
+	m.mu.Lock()
+	thresholds := make(map[string]int, len(m.thresholds))
+	ids := make([]string, 0, len(m.thresholds))
+	for id, threshold := range m.thresholds {
+		thresholds[id] = threshold
+		ids = append(ids, id)
+	}
+	alerted := make(map[string][]string, len(m.alerted))
+	for id, channels := range m.alerted {
+		for channel := range channels {
+			alerted[id] = append(alerted[id], channel)
+		}
+		sort.Strings(alerted[id])
+	}
+	m.mu.Unlock()
+
+	levels, err := m.inventory.Find(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []StockCheck{}
+	for _, id := range ids {
+		coffee, err := m.coffees.Get(ctx, id)
+		if err != nil {
+			continue
+		}
+
+		check := StockCheck{CoffeeID: id, CoffeeName: coffee.Name, Threshold: thresholds[id], Alerted: len(alerted[id]) > 0, AlertedVia: append([]string{}, alerted[id]...)}
+		for _, level := range levels[id] {
+			check.Available += level.Available()
+		}
+		checks = append(checks, check)
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].CoffeeName != checks[j].CoffeeName {
+			return checks[i].CoffeeName < checks[j].CoffeeName
+		}
+		return checks[i].CoffeeID < checks[j].CoffeeID
+	})
+
+	return checks, nil
+}
+
+// Claim records that the current shortage of a coffee is being alerted on
+// the given channel.
+func (m *MemoryReorderRepository) Claim(ctx context.Context, coffeeID, channel string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.alerted[coffeeID][channel]; ok {
+		return false, nil
+	}
+	if m.alerted[coffeeID] == nil {
+		m.alerted[coffeeID] = make(map[string]time.Time)
+	}
+	m.alerted[coffeeID][channel] = at
+
+	return true, nil
+}
+
+// Unclaim forgets the alert of a coffee on one channel.
+func (m *MemoryReorderRepository) Unclaim(ctx context.Context, coffeeID, channel string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.alerted[coffeeID], channel)
+	if len(m.alerted[coffeeID]) == 0 {
+		delete(m.alerted, coffeeID)
+	}
+
+	return nil
+}
+
+// Clear forgets the alerts of a coffee on every channel.
+func (m *MemoryReorderRepository) Clear(ctx context.Context, coffeeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.alerted, coffeeID)
+
+	return nil
+}
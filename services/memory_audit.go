@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryAuditRepository is a thread-safe AuditRepository kept in process
+// memory. A MemoryCoffeeRepository records into it while holding its own
+// lock, so every entry is written together with its mutation.
+type MemoryAuditRepository struct {
+	mu      sync.RWMutex
+	entries []*AuditEntry
+}
+
+// NewMemoryAuditRepository creates an empty in-memory audit trail.
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+// List returns the audit entries matching filter, newest first.
+func (m *MemoryAuditRepository) List(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []*AuditEntry{}
+	skipped := 0
+	for i := len(m.entries) - 1; i >= 0 && len(entries) < filter.limit(); i-- {
+		if !filter.matches(m.entries[i]) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+
+		entry := *m.entries[i]
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// record appends entry, filling in its ID and timestamp. Timestamps strictly
+// increase, so that a point in time always falls between two entries.
+func (m *MemoryAuditRepository) record(entry *AuditEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().Truncate(time.Microsecond)
+	if n := len(m.entries); n > 0 && !now.After(m.entries[n-1].CreatedAt) {
+		now = m.entries[n-1].CreatedAt.Add(time.Microsecond)
+	}
+
+	entry.ID = int64(len(m.entries)) + 1
+	entry.CreatedAt = now
+	m.entries = append(m.entries, entry)
+}
+
+// around returns the entries of an entity type keyed by entity ID: the last
+// one at or before at, and the first one after it.
+func (m *MemoryAuditRepository) around(entity string, at time.Time) (last, next map[string]*AuditEntry) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	last = make(map[string]*AuditEntry)
+	next = make(map[string]*AuditEntry)
+	for _, entry := range m.entries {
+		switch {
+		case entry.Entity != entity:
+		case !entry.CreatedAt.After(at):
+			last[entry.EntityID] = entry
+		case next[entry.EntityID] == nil:
+			next[entry.EntityID] = entry
+		}
+	}
+
+	return last, next
+}
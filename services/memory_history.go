@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// MemoryHistoryRepository is a thread-safe HistoryRepository kept in process
+// memory. A MemoryCoffeeRepository records price changes into it while
+// holding its own lock, and it reads the audit trail of that repository.
+type MemoryHistoryRepository struct {
+	mu      sync.RWMutex
+	changes []*PriceChange
+	coffees *MemoryCoffeeRepository
+}
+
+// NewMemoryHistoryRepository creates an empty price history over coffees.
+func NewMemoryHistoryRepository(coffees *MemoryCoffeeRepository) *MemoryHistoryRepository {
+	return &MemoryHistoryRepository{coffees: coffees}
+}
+
+// Prices returns the price changes of a coffee, oldest first.
+func (m *MemoryHistoryRepository) Prices(ctx context.Context, coffeeID string) ([]*PriceChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	changes := []*PriceChange{}
+	for _, change := range m.changes {
+		if change.CoffeeID == coffeeID {
+			change := *change
+			changes = append(changes, &change)
+		}
+	}
+
+	return changes, nil
+}
+
+// List returns one page of the coffees on the menu at the given time that
+// match opts. Stock is not historical, so InStock is ignored.
+func (m *MemoryHistoryRepository) List(ctx context.Context, at time.Time, opts ListOptions) (*CoffeePage, error) {
+	coffees, err := m.catalog(at)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.coffees.price(ctx, coffees, opts); err != nil {
+		return nil, err
+	}
+
+	return opts.page(coffees)
+}
+
+// Get returns a coffee as it was at the given time, or sql.ErrNoRows when it
+// was not on the menu then.
+func (m *MemoryHistoryRepository) Get(ctx context.Context, id string, at time.Time) (*Coffee, error) {
+	coffees, err := m.catalog(at)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, coffee := range coffees {
+		if coffee.ID == id {
+			return coffee, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// catalog rebuilds the coffees on the menu at a moment. The coffee lock is
+// held throughout so that no mutation lands between the lookups.
+func (m *MemoryHistoryRepository) catalog(at time.Time) ([]*Coffee, error) {
+	m.coffees.mu.RLock()
+	defer m.coffees.mu.RUnlock()
+
+	current := make(map[string]*Coffee, len(m.coffees.coffees))
+	for id, coffee := range m.coffees.coffees {
+		coffee := coffee
+		current[id] = &coffee
+	}
+
+	var last, next map[string]*AuditEntry
+	if m.coffees.audit != nil {
+		last, next = m.coffees.audit.around(EntityCoffee, at)
+	}
+
+	m.mu.RLock()
+	prices := make(map[string]Money)
+	for _, change := range m.changes {
+		if !change.ChangedAt.After(at) {
+			prices[change.CoffeeID] = change.Price
+		}
+	}
+	m.mu.RUnlock()
+
+	return pastCatalog(at, last, next, current, prices)
+}
+
+// record appends change, filling in its ID.
+func (m *MemoryHistoryRepository) record(change *PriceChange) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	change.ID = int64(len(m.changes)) + 1
+	m.changes = append(m.changes, change)
+}
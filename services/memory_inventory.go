@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryInventoryRepository is a thread-safe InventoryRepository kept in
+// process memory. A single lock makes every operation atomic, standing in
+// for the row locks of the PostgreSQL repository.
+type MemoryInventoryRepository struct {
+	mu           sync.RWMutex
+	levels       map[StockKey]StockLevel
+	adjustments  []Adjustment
+	reservations map[string]Reservation
+	// ordered holds the IDs of the reservations made by reserveTracked,
+	// which only closeAll may close.
+	ordered map[string]bool
+	coffees *MemoryCoffeeRepository
+}
+
+// NewMemoryInventoryRepository creates an empty in-memory inventory checking
+// coffees against coffees, which may be nil.
+func NewMemoryInventoryRepository(coffees *MemoryCoffeeRepository) *MemoryInventoryRepository {
+	return &MemoryInventoryRepository{
+		levels:       make(map[StockKey]StockLevel),
+		reservations: make(map[string]Reservation),
+		ordered:      make(map[string]bool),
+		coffees:      coffees,
+	}
+}
+
+// Levels returns the stock levels of a coffee ordered by variant and location.
+func (m *MemoryInventoryRepository) Levels(ctx context.Context, coffeeID string) ([]StockLevel, error) {
+	found, err := m.Find(ctx, []string{coffeeID})
+	if err != nil {
+		return nil, err
+	}
+
+	if found[coffeeID] == nil {
+		return []StockLevel{}, nil
+	}
+
+	return found[coffeeID], nil
+}
+
+// Find returns the stock levels of the given coffees keyed by coffee ID.
+func (m *MemoryInventoryRepository) Find(ctx context.Context, coffeeIDs []string) (map[string][]StockLevel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(coffeeIDs))
+	for _, id := range coffeeIDs {
+		wanted[id] = true
+	}
+
+	levels := make(map[string][]StockLevel)
+	for key, level := range m.levels {
+		if wanted[key.CoffeeID] {
+			levels[key.CoffeeID] = append(levels[key.CoffeeID], level)
+		}
+	}
+
+	for _, list := range levels {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].VariantID != list[j].VariantID {
+				return list[i].VariantID < list[j].VariantID
+			}
+			return list[i].Location < list[j].Location
+		})
+	}
+
+	return levels, nil
+}
+
+// Adjust changes the on-hand quantity of a stock level and records why.
+func (m *MemoryInventoryRepository) Adjust(ctx context.Context, adjustment Adjustment) (*StockLevel, error) {
+	if m.coffees != nil {
+		if _, err := m.coffees.Get(ctx, adjustment.CoffeeID); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	level, ok := m.levels[adjustment.StockKey]
+	if !ok {
+		level = StockLevel{StockKey: adjustment.StockKey}
+	}
+
+	if level.OnHand+adjustment.Delta < level.Reserved {
+		return nil, NewError(KindConflict, fmt.Errorf("%w: %d on hand, %d reserved", ErrInsufficientStock, level.OnHand, level.Reserved))
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	level.OnHand += adjustment.Delta
+	level.UpdatedAt = now
+	m.levels[level.StockKey] = level
+
+	adjustment.ID = id
+	adjustment.CreatedAt = now
+	m.adjustments = append(m.adjustments, adjustment)
+
+	return &level, nil
+}
+
+// Adjustments returns the latest adjustments of a coffee, newest first.
+func (m *MemoryInventoryRepository) Adjustments(ctx context.Context, coffeeID string, limit int) ([]Adjustment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	adjustments := []Adjustment{}
+	for i := len(m.adjustments) - 1; i >= 0 && len(adjustments) < limit; i-- {
+		if m.adjustments[i].CoffeeID == coffeeID {
+			adjustments = append(adjustments, m.adjustments[i])
+		}
+	}
+
+	return adjustments, nil
+}
+
+// Reserve holds quantity units of a stock level.
+func (m *MemoryInventoryRepository) Reserve(ctx context.Context, reservation Reservation) (*Reservation, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	level, ok := m.levels[reservation.StockKey]
+	if !ok {
+		return nil, NewError(KindConflict, fmt.Errorf("%w: %s is not stocked at %s", ErrInsufficientStock, stockItem(reservation.StockKey), reservation.Location))
+	}
+
+	if level.Available() < reservation.Quantity {
+		return nil, NewError(KindConflict, fmt.Errorf("%w: %d available, %d requested", ErrInsufficientStock, level.Available(), reservation.Quantity))
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	level.Reserved += reservation.Quantity
+	level.UpdatedAt = now
+	m.levels[level.StockKey] = level
+
+	reservation.ID = id
+	reservation.Status = ReservationHeld
+	reservation.CreatedAt = now
+	reservation.UpdatedAt = now
+	m.reservations[id] = reservation
+
+	return &reservation, nil
+}
+
+// GetReservation returns the reservation with the given ID.
+func (m *MemoryInventoryRepository) GetReservation(ctx context.Context, id string) (*Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reservation, ok := m.reservations[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &reservation, nil
+}
+
+// Release returns the units of a held reservation to the shelf.
+func (m *MemoryInventoryRepository) Release(ctx context.Context, id string) (*Reservation, error) {
+	return m.close(id, ReservationReleased)
+}
+
+// Commit turns a held reservation into a sale.
+func (m *MemoryInventoryRepository) Commit(ctx context.Context, id string) (*Reservation, error) {
+	return m.close(id, ReservationCommitted)
+}
+
+// close moves a held reservation to status, unless it holds stock for an
+// order.
+func (m *MemoryInventoryRepository) close(id, status string) (*Reservation, error) {
+	m.mu.RLock()
+	ordered := m.ordered[id]
+	m.mu.RUnlock()
+
+	if ordered {
+		return nil, NewError(KindConflict, ErrReservationOrdered)
+	}
+
+	closed, err := m.closeAll([]string{id}, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &closed[0], nil
+}
+
+// closeAll moves the held reservations with the given IDs to status, or none
+// of them when one is missing or no longer held.
+func (m *MemoryInventoryRepository) closeAll(ids []string, status string) ([]Reservation, error) {
+	adjustmentIDs := make([]string, len(ids))
+	for i := range ids {
+		id, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+		adjustmentIDs[i] = id
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		reservation, ok := m.reservations[id]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		if reservation.Status != ReservationHeld {
+			return nil, NewError(KindConflict, fmt.Errorf("%w: it is %s", ErrReservationClosed, reservation.Status))
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	closed := make([]Reservation, len(ids))
+	for i, id := range ids {
+		reservation := m.reservations[id]
+		level := m.levels[reservation.StockKey]
+		level.Reserved -= reservation.Quantity
+		if status == ReservationCommitted {
+			level.OnHand -= reservation.Quantity
+			m.adjustments = append(m.adjustments, Adjustment{
+				ID:            adjustmentIDs[i],
+				StockKey:      reservation.StockKey,
+				Delta:         -reservation.Quantity,
+				Reason:        ReasonSale,
+				ReservationID: reservation.ID,
+				CreatedAt:     now,
+			})
+		}
+		level.UpdatedAt = now
+		m.levels[level.StockKey] = level
+
+		reservation.Status = status
+		reservation.UpdatedAt = now
+		m.reservations[id] = reservation
+		closed[i] = reservation
+	}
+
+	return closed, nil
+}
+
+// sellable reports whether a coffee is untracked or has stock available at
+// some location, the semantics of ListOptions.InStock.
+func (m *MemoryInventoryRepository) sellable(coffeeID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tracked := false
+	for key, level := range m.levels {
+		if key.CoffeeID != coffeeID {
+			continue
+		}
+		if level.Available() > 0 {
+			return true
+		}
+		tracked = true
+	}
+
+	return !tracked
+}
+
+// reserveTracked holds stock at DefaultLocation for every order item of a
+// coffee with stock levels, setting their ReservationID, or for none of
+// them when one cannot be met.
+func (m *MemoryInventoryRepository) reserveTracked(items []*OrderItem) error {
+	ids := make([]string, len(items))
+	for i := range items {
+		id, err := newUUID()
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracked := make(map[string]bool)
+	for key := range m.levels {
+		tracked[key.CoffeeID] = true
+	}
+
+	// Work on copies so that a failing item leaves every level untouched.
+	levels := make(map[StockKey]StockLevel)
+	reservations := make(map[int]Reservation)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	for i, item := range items {
+		if !tracked[item.CoffeeID] {
+			continue
+		}
+
+		key := StockKey{CoffeeID: item.CoffeeID, VariantID: item.VariantID, Location: DefaultLocation}
+		level, ok := levels[key]
+		if !ok {
+			if level, ok = m.levels[key]; !ok {
+				return NewError(KindConflict, fmt.Errorf("%w: %s is not stocked at %s", ErrInsufficientStock, stockItem(key), key.Location))
+			}
+		}
+
+		if level.Available() < item.Quantity {
+			return NewError(KindConflict, fmt.Errorf("%w: %d available, %d requested", ErrInsufficientStock, level.Available(), item.Quantity))
+		}
+
+		level.Reserved += item.Quantity
+		level.UpdatedAt = now
+		levels[key] = level
+		reservations[i] = Reservation{ID: ids[i], StockKey: key, Quantity: item.Quantity, Status: ReservationHeld, CreatedAt: now, UpdatedAt: now}
+	}
+
+	for key, level := range levels {
+		m.levels[key] = level
+	}
+	for i, reservation := range reservations {
+		m.reservations[reservation.ID] = reservation
+		m.ordered[reservation.ID] = true
+		items[i].ReservationID = reservation.ID
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryOrderRepository is a thread-safe OrderRepository kept in process
+// memory. It prices orders from the companion coffee, variant and schedule
+// repositories and reserves stock in the companion inventory.
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders map[string]Order
+
+	coffees   *MemoryCoffeeRepository
+	variants  *MemoryVariantRepository
+	schedules *MemoryScheduleRepository
+	inventory *MemoryInventoryRepository
+}
+
+// NewMemoryOrderRepository creates an empty in-memory order repository over
+// the given catalog and inventory.
+func NewMemoryOrderRepository(coffees *MemoryCoffeeRepository, variants *MemoryVariantRepository, schedules *MemoryScheduleRepository, inventory *MemoryInventoryRepository) *MemoryOrderRepository {
+	return &MemoryOrderRepository{
+		orders:    make(map[string]Order),
+		coffees:   coffees,
+		variants:  variants,
+		schedules: schedules,
+		inventory: inventory,
+	}
+}
+
+// Create places an order: it snapshots the current name and price of every
+// item, computes the totals, reserves the stock of tracked coffees and
+// stores the order.
+func (m *MemoryOrderRepository) Create(ctx context.Context, order Order) (*Order, error) {
+	now := time.Now()
+	order.Items = copyOrderItems(order.Items)
+
+	for i, item := range order.Items {
+		if err := m.priceItem(ctx, item, i+1, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := order.total(); err != nil {
+		return nil, err
+	}
+
+	if err := m.inventory.reserveTracked(order.Items); err != nil {
+		return nil, err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range order.Items {
+		if item.ID, err = newUUID(); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order.ID = id
+	order.Status = OrderPending
+	order.CreatedAt = now.UTC().Truncate(time.Microsecond)
+	order.UpdatedAt = order.CreatedAt
+	order.Timeline = map[string]time.Time{OrderPending: order.CreatedAt}
+	m.orders[id] = copyOrder(order)
+
+	return &order, nil
+}
+
+// priceItem looks up the coffee, schedule and variant of the nth line of an
+// order and prices the line with priceItem.
+func (m *MemoryOrderRepository) priceItem(ctx context.Context, item *OrderItem, n int, at time.Time) error {
+	coffee, err := m.coffees.Get(ctx, item.CoffeeID)
+	if err == sql.ErrNoRows {
+		return priceItem(item, n, nil, nil, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	active, err := m.schedules.Active(ctx, []string{item.CoffeeID}, at)
+	if err != nil {
+		return err
+	}
+
+	var variant *Variant
+	if item.VariantID != "" {
+		variant, err = m.variants.Get(ctx, item.CoffeeID, item.VariantID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	return priceItem(item, n, coffee, active[item.CoffeeID], variant)
+}
+
+// Get returns the order with the given ID and its items.
+func (m *MemoryOrderRepository) Get(ctx context.Context, id string) (*Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	order = copyOrder(order)
+	return &order, nil
+}
+
+// Transition moves an order to status, closing its stock reservations in the
+// companion inventory as the PostgreSQL repository does.
+func (m *MemoryOrderRepository) Transition(ctx context.Context, id, status string) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.orders[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	order := copyOrder(stored)
+	if err := order.Transition(status, time.Now().UTC().Truncate(time.Microsecond)); err != nil {
+		return nil, err
+	}
+
+	if closed := reservationStatus(status); closed != "" {
+		if _, err := m.inventory.closeAll(order.reservations(), closed); err != nil {
+			return nil, err
+		}
+	}
+
+	m.orders[id] = copyOrder(order)
+
+	return &order, nil
+}
+
+// Stale returns the IDs of the pending orders placed before placedBefore,
+// oldest first.
+func (m *MemoryOrderRepository) Stale(ctx context.Context, placedBefore time.Time) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stale := []Order{}
+	for _, order := range m.orders {
+		if order.Status == OrderPending && order.CreatedAt.Before(placedBefore) {
+			stale = append(stale, order)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return keysetLess(stale[i].CreatedAt, stale[i].ID, stale[j].CreatedAt, stale[j].ID)
+	})
+
+	ids := make([]string, len(stale))
+	for i, order := range stale {
+		ids[i] = order.ID
+	}
+
+	return ids, nil
+}
+
+// copyOrder returns order with its own copies of the items, timeline and tax
+// rate.
+func copyOrder(order Order) Order {
+	order.Items = copyOrderItems(order.Items)
+	if order.Timeline != nil {
+		timeline := make(map[string]time.Time, len(order.Timeline))
+		for status, t := range order.Timeline {
+			timeline[status] = t
+		}
+		order.Timeline = timeline
+	}
+	if order.TaxRate != nil {
+		order.TaxRate = new(big.Rat).Set(order.TaxRate)
+	}
+
+	return order
+}
+
+// copyOrderItems returns copies of items.
+func copyOrderItems(items []*OrderItem) []*OrderItem {
+	copies := make([]*OrderItem, len(items))
+	for i, item := range items {
+		item := *item
+		copies[i] = &item
+	}
+
+	return copies
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryOriginRepository is a thread-safe OriginRepository kept in process
+// memory. Like the origins table it refuses to delete origins that coffees
+// in the companion coffee repository still reference.
+type MemoryOriginRepository struct {
+	mu      sync.RWMutex
+	origins map[string]Origin
+	coffees *MemoryCoffeeRepository
+}
+
+// NewMemoryOriginRepository creates an empty in-memory origin repository
+// checking references against coffees, which may be nil.
+func NewMemoryOriginRepository(coffees *MemoryCoffeeRepository) *MemoryOriginRepository {
+	return &MemoryOriginRepository{origins: make(map[string]Origin), coffees: coffees}
+}
+
+// List returns every origin ordered by country, region and farm.
+func (m *MemoryOriginRepository) List(ctx context.Context) ([]*Origin, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	origins := make([]*Origin, 0, len(m.origins))
+	for _, origin := range m.origins {
+		origin := copyOrigin(origin)
+		origins = append(origins, &origin)
+	}
+
+	sort.Slice(origins, func(i, j int) bool {
+		a, b := origins[i], origins[j]
+		if a.Country != b.Country {
+			return a.Country < b.Country
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.Farm != b.Farm {
+			return a.Farm < b.Farm
+		}
+		return a.ID < b.ID
+	})
+
+	return origins, nil
+}
+
+// Get returns the origin with the given ID.
+func (m *MemoryOriginRepository) Get(ctx context.Context, id string) (*Origin, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	origin, ok := m.origins[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	origin = copyOrigin(origin)
+	return &origin, nil
+}
+
+// Find returns the origins with the given IDs, keyed by ID.
+func (m *MemoryOriginRepository) Find(ctx context.Context, ids []string) (map[string]*Origin, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	origins := make(map[string]*Origin)
+	for _, id := range ids {
+		if origin, ok := m.origins[id]; ok {
+			origin := copyOrigin(origin)
+			origins[id] = &origin
+		}
+	}
+
+	return origins, nil
+}
+
+// Create stores a new origin under a freshly generated UUID.
+func (m *MemoryOriginRepository) Create(ctx context.Context, origin Origin) (*Origin, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	origin = copyOrigin(origin)
+	origin.ID = id
+	origin.CreatedAt = now
+	origin.UpdatedAt = now
+	m.origins[id] = origin
+
+	origin = copyOrigin(origin)
+	return &origin, nil
+}
+
+// Update overwrites a stored origin.
+func (m *MemoryOriginRepository) Update(ctx context.Context, origin Origin) (*Origin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.origins[origin.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	origin = copyOrigin(origin)
+	origin.CreatedAt = stored.CreatedAt
+	origin.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.origins[origin.ID] = origin
+
+	origin = copyOrigin(origin)
+	return &origin, nil
+}
+
+// Delete removes the origin with the given ID unless a coffee references it.
+func (m *MemoryOriginRepository) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.origins[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	if m.coffees != nil && m.coffees.references(id) {
+		return NewError(KindConflict, ErrOriginInUse)
+	}
+	delete(m.origins, id)
+
+	return nil
+}
+
+// copyOrigin returns origin with its own copies of the altitude pointers.
+func copyOrigin(origin Origin) Origin {
+	if origin.AltitudeMin != nil {
+		altitude := *origin.AltitudeMin
+		origin.AltitudeMin = &altitude
+	}
+	if origin.AltitudeMax != nil {
+		altitude := *origin.AltitudeMax
+		origin.AltitudeMax = &altitude
+	}
+
+	return origin
+}
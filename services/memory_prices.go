@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryPriceRepository is a thread-safe PriceRepository kept in process
+// memory. Unlike the coffee_prices table it does not cascade coffee deletes;
+// prices of deleted coffees are simply never looked up again.
+type MemoryPriceRepository struct {
+	mu     sync.RWMutex
+	prices map[string]map[string]int64
+}
+
+// NewMemoryPriceRepository creates an empty in-memory price repository.
+func NewMemoryPriceRepository() *MemoryPriceRepository {
+	return &MemoryPriceRepository{prices: make(map[string]map[string]int64)}
+}
+
+// List returns the price list of a coffee ordered by currency.
+func (m *MemoryPriceRepository) List(ctx context.Context, coffeeID string) ([]Money, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prices := []Money{}
+	for currency, amount := range m.prices[coffeeID] {
+		prices = append(prices, Money{Amount: amount, Currency: currency})
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Currency < prices[j].Currency
+	})
+
+	return prices, nil
+}
+
+// Find returns the list prices in currency of the given coffees.
+func (m *MemoryPriceRepository) Find(ctx context.Context, coffeeIDs []string, currency string) (map[string]Money, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prices := make(map[string]Money)
+	for _, id := range coffeeIDs {
+		if amount, ok := m.prices[id][currency]; ok {
+			prices[id] = Money{Amount: amount, Currency: currency}
+		}
+	}
+
+	return prices, nil
+}
+
+// Set creates or replaces the price of a coffee in the currency of price.
+func (m *MemoryPriceRepository) Set(ctx context.Context, coffeeID string, price Money) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.prices[coffeeID] == nil {
+		m.prices[coffeeID] = make(map[string]int64)
+	}
+	m.prices[coffeeID][price.Currency] = price.Amount
+
+	return nil
+}
+
+// Delete removes the price of a coffee in currency.
+func (m *MemoryPriceRepository) Delete(ctx context.Context, coffeeID, currency string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.prices[coffeeID][currency]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.prices[coffeeID], currency)
+
+	return nil
+}
+
+// MemoryRateRepository is a thread-safe RateRepository kept in process memory.
+type MemoryRateRepository struct {
+	mu    sync.RWMutex
+	rates map[[2]string]ExchangeRate
+}
+
+// NewMemoryRateRepository creates an empty in-memory rate repository.
+func NewMemoryRateRepository() *MemoryRateRepository {
+	return &MemoryRateRepository{rates: make(map[[2]string]ExchangeRate)}
+}
+
+// List returns every configured rate ordered by currency pair.
+func (m *MemoryRateRepository) List(ctx context.Context) ([]ExchangeRate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rates := make([]ExchangeRate, 0, len(m.rates))
+	for _, rate := range m.rates {
+		rates = append(rates, copyRate(rate))
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Base != rates[j].Base {
+			return rates[i].Base < rates[j].Base
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+
+	return rates, nil
+}
+
+// Get returns the rate converting base into quote.
+func (m *MemoryRateRepository) Get(ctx context.Context, base, quote string) (*ExchangeRate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rate, ok := m.rates[[2]string{base, quote}]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	rate = copyRate(rate)
+	return &rate, nil
+}
+
+// Save creates or replaces all rates at once.
+func (m *MemoryRateRepository) Save(ctx context.Context, rates []ExchangeRate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, rate := range rates {
+		rate = copyRate(rate)
+		rate.UpdatedAt = now
+		m.rates[[2]string{rate.Base, rate.Quote}] = rate
+	}
+
+	return nil
+}
+
+// Delete removes the rate converting base into quote.
+func (m *MemoryRateRepository) Delete(ctx context.Context, base, quote string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{base, quote}
+	if _, ok := m.rates[key]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.rates, key)
+
+	return nil
+}
+
+// copyRate returns rate with its own copy of the mutable big.Rat.
+func copyRate(rate ExchangeRate) ExchangeRate {
+	rate.Rate = new(big.Rat).Set(rate.Rate)
+	return rate
+}
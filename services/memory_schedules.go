@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryScheduleRepository is a thread-safe ScheduleRepository kept in
+// process memory. Like the price_schedules table it only accepts schedules
+// of coffees stored in the companion coffee repository.
+type MemoryScheduleRepository struct {
+	mu        sync.Mutex
+	schedules map[string]PriceSchedule
+	coffees   *MemoryCoffeeRepository
+}
+
+// NewMemoryScheduleRepository creates an empty in-memory schedule repository
+// checking coffees against coffees, which may be nil.
+func NewMemoryScheduleRepository(coffees *MemoryCoffeeRepository) *MemoryScheduleRepository {
+	return &MemoryScheduleRepository{schedules: make(map[string]PriceSchedule), coffees: coffees}
+}
+
+// List returns the schedules of a coffee in the order they take effect.
+func (m *MemoryScheduleRepository) List(ctx context.Context, coffeeID string) ([]*PriceSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	schedules := []*PriceSchedule{}
+	for _, schedule := range m.schedules {
+		if schedule.CoffeeID == coffeeID {
+			schedule := copySchedule(schedule)
+			schedules = append(schedules, &schedule)
+		}
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].EffectiveFrom.Before(schedules[j].EffectiveFrom)
+	})
+
+	return schedules, nil
+}
+
+// Active returns the schedules in effect at the given time, keyed by coffee ID.
+func (m *MemoryScheduleRepository) Active(ctx context.Context, coffeeIDs []string, at time.Time) (map[string]*PriceSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wanted := make(map[string]bool, len(coffeeIDs))
+	for _, id := range coffeeIDs {
+		wanted[id] = true
+	}
+
+	active := make(map[string]*PriceSchedule)
+	for _, schedule := range m.schedules {
+		if wanted[schedule.CoffeeID] && schedule.ActiveAt(at) {
+			schedule := copySchedule(schedule)
+			active[schedule.CoffeeID] = &schedule
+		}
+	}
+
+	return active, nil
+}
+
+// Create stores a new schedule under a freshly generated UUID.
+func (m *MemoryScheduleRepository) Create(ctx context.Context, schedule PriceSchedule) (*PriceSchedule, error) {
+	if m.coffees != nil {
+		if _, err := m.coffees.Get(ctx, schedule.CoffeeID); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.schedules {
+		if other.CoffeeID == schedule.CoffeeID && schedule.overlaps(&other) {
+			return nil, NewError(KindConflict, ErrScheduleOverlap)
+		}
+	}
+
+	schedule = copySchedule(schedule)
+	schedule.ID = id
+	schedule.StartedAt, schedule.EndedAt = nil, nil
+	schedule.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.schedules[id] = schedule
+
+	schedule = copySchedule(schedule)
+	return &schedule, nil
+}
+
+// Cancel removes a schedule that has not taken effect yet, or ends one in
+// effect at the given time.
+func (m *MemoryScheduleRepository) Cancel(ctx context.Context, coffeeID, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	schedule, ok := m.schedules[id]
+	if !ok || schedule.CoffeeID != coffeeID {
+		return sql.ErrNoRows
+	}
+
+	switch {
+	case at.Before(schedule.EffectiveFrom):
+		delete(m.schedules, id)
+	case schedule.ActiveAt(at):
+		schedule.EffectiveTo = &at
+		m.schedules[id] = schedule
+	default:
+		return NewError(KindConflict, ErrScheduleEnded)
+	}
+
+	return nil
+}
+
+// Transitions marks the schedules that came into or went out of effect by
+// the given time and returns them, starts first.
+func (m *MemoryScheduleRepository) Transitions(ctx context.Context, at time.Time) ([]ScheduleTransition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var started, ended []*PriceSchedule
+	for id, schedule := range m.schedules {
+		schedule := copySchedule(schedule)
+		if schedule.StartedAt == nil && !at.Before(schedule.EffectiveFrom) {
+			schedule.StartedAt = &at
+			started = append(started, &schedule)
+		}
+		if schedule.EndedAt == nil && schedule.EffectiveTo != nil && !at.Before(*schedule.EffectiveTo) {
+			schedule.EndedAt = &at
+			ended = append(ended, &schedule)
+		}
+		m.schedules[id] = copySchedule(schedule)
+	}
+
+	return scheduleTransitions(started, ended), nil
+}
+
+// copySchedule returns schedule with its own copies of the optional times.
+func copySchedule(schedule PriceSchedule) PriceSchedule {
+	for _, t := range []**time.Time{&schedule.EffectiveTo, &schedule.StartedAt, &schedule.EndedAt} {
+		if *t != nil {
+			v := **t
+			*t = &v
+		}
+	}
+
+	return schedule
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMemoryCoffeeRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("Create Assigns Identity", func(t *testing.T) {
+		// Test that created coffees get a UUID and timestamps.
+		repo := NewMemoryCoffeeRepository()
+
+		created, err := repo.Create(ctx, Coffee{Name: "TestCoffee", Roast: "Light"})
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+		if !uuidPattern.MatchString(created.ID) {
+			t.Errorf("Expected a version 4 UUID, got %q", created.ID)
+		}
+
+		if created.CreatedAt.IsZero() || !created.CreatedAt.Equal(created.UpdatedAt) {
+			t.Errorf("Expected equal non-zero timestamps, got %v and %v", created.CreatedAt, created.UpdatedAt)
+		}
+	})
+
+	t.Run("Returned Copies", func(t *testing.T) {
+		// Test that callers cannot mutate stored coffees through returned pointers.
+		repo := NewMemoryCoffeeRepository()
+
+		created, err := repo.Create(ctx, Coffee{Name: "TestCoffee"})
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+		created.Name = "Mutated"
+
+		stored, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+
+		if stored.Name != "TestCoffee" {
+			t.Errorf("Expected stored name TestCoffee, got %q", stored.Name)
+		}
+	})
+
+	t.Run("Scheduled Price Listing", func(t *testing.T) {
+		// Test that price bounds and sorting by price use the scheduled price.
+		models := NewMemory()
+
+		discounted, err := models.Coffees.Create(ctx, Coffee{Name: "Discounted", Price: Money{2000, "EUR"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		regular, err := models.Coffees.Create(ctx, Coffee{Name: "Regular", Price: Money{1200, "EUR"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := models.Schedules.Create(ctx, PriceSchedule{CoffeeID: discounted.ID, Price: Money{800, "EUR"}, EffectiveFrom: time.Now().Add(-time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+
+		priceMax := int64(1000)
+		page, err := models.Coffees.List(ctx, ListOptions{PriceMax: &priceMax})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Coffees) != 1 || page.Coffees[0].ID != discounted.ID || page.Coffees[0].Price.Amount != 2000 {
+			t.Errorf("List(price_max=10.00) = %+v, want only the discounted coffee at its 20.00 base price", page.Coffees)
+		}
+
+		page, err = models.Coffees.List(ctx, ListOptions{Sort: []SortField{{Field: "price"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Coffees) != 2 || page.Coffees[0].ID != discounted.ID || page.Coffees[1].ID != regular.ID {
+			t.Errorf("List(sort=price) = %+v, want the discounted coffee first", page.Coffees)
+		}
+
+		page, err = models.Coffees.List(ctx, ListOptions{PriceMax: &priceMax, PricedAt: time.Now().Add(-2 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Coffees) != 0 {
+			t.Errorf("List(price_max=10.00) before the schedule = %+v, want none", page.Coffees)
+		}
+	})
+}
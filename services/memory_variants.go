@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryVariantRepository is a thread-safe VariantRepository kept in process
+// memory. Like the coffee_variants table it only accepts variants of coffees
+// stored in the companion coffee repository and keeps SKUs unique, ignoring
+// case. Unlike the table it does not cascade coffee deletes; variants of
+// deleted coffees are simply never looked up again.
+type MemoryVariantRepository struct {
+	mu       sync.RWMutex
+	variants map[string]Variant
+	coffees  *MemoryCoffeeRepository
+}
+
+// NewMemoryVariantRepository creates an empty in-memory variant repository
+// checking coffees against coffees, which may be nil.
+func NewMemoryVariantRepository(coffees *MemoryCoffeeRepository) *MemoryVariantRepository {
+	return &MemoryVariantRepository{variants: make(map[string]Variant), coffees: coffees}
+}
+
+// List returns the variants of a coffee, bags before cups and lightest first.
+func (m *MemoryVariantRepository) List(ctx context.Context, coffeeID string) ([]*Variant, error) {
+	variants, err := m.Find(ctx, []string{coffeeID})
+	if err != nil {
+		return nil, err
+	}
+
+	if variants[coffeeID] == nil {
+		return []*Variant{}, nil
+	}
+
+	return variants[coffeeID], nil
+}
+
+// Find returns the variants of the given coffees keyed by coffee ID.
+func (m *MemoryVariantRepository) Find(ctx context.Context, coffeeIDs []string) (map[string][]*Variant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	variants := make(map[string][]*Variant)
+	for _, variant := range m.variants {
+		if slices.Contains(coffeeIDs, variant.CoffeeID) {
+			variant := copyVariant(variant)
+			variants[variant.CoffeeID] = append(variants[variant.CoffeeID], &variant)
+		}
+	}
+
+	for _, list := range variants {
+		sortVariants(list)
+	}
+
+	return variants, nil
+}
+
+// Get returns a variant of a coffee by its ID.
+func (m *MemoryVariantRepository) Get(ctx context.Context, coffeeID, id string) (*Variant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	variant, ok := m.variants[id]
+	if !ok || variant.CoffeeID != coffeeID {
+		return nil, sql.ErrNoRows
+	}
+
+	variant = copyVariant(variant)
+	return &variant, nil
+}
+
+// Create stores a new variant under a freshly generated UUID.
+func (m *MemoryVariantRepository) Create(ctx context.Context, variant Variant) (*Variant, error) {
+	if m.coffees != nil {
+		if _, err := m.coffees.Get(ctx, variant.CoffeeID); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.skuTaken(variant.SKU, id) {
+		return nil, NewError(KindConflict, ErrDuplicateSKU)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	variant = copyVariant(variant)
+	variant.Stock = nil
+	variant.ID = id
+	variant.CreatedAt = now
+	variant.UpdatedAt = now
+	m.variants[id] = variant
+
+	variant = copyVariant(variant)
+	return &variant, nil
+}
+
+// Update overwrites a stored variant.
+func (m *MemoryVariantRepository) Update(ctx context.Context, variant Variant) (*Variant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.variants[variant.ID]
+	if !ok || stored.CoffeeID != variant.CoffeeID {
+		return nil, sql.ErrNoRows
+	}
+
+	if m.skuTaken(variant.SKU, variant.ID) {
+		return nil, NewError(KindConflict, ErrDuplicateSKU)
+	}
+
+	variant = copyVariant(variant)
+	variant.Stock = nil
+	variant.CreatedAt = stored.CreatedAt
+	variant.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.variants[variant.ID] = variant
+
+	variant = copyVariant(variant)
+	return &variant, nil
+}
+
+// Delete removes a variant of a coffee.
+func (m *MemoryVariantRepository) Delete(ctx context.Context, coffeeID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if variant, ok := m.variants[id]; !ok || variant.CoffeeID != coffeeID {
+		return sql.ErrNoRows
+	}
+	delete(m.variants, id)
+
+	return nil
+}
+
+// skuTaken reports whether a variant other than id uses sku. The caller must
+// hold the lock.
+func (m *MemoryVariantRepository) skuTaken(sku, id string) bool {
+	for _, variant := range m.variants {
+		if variant.ID != id && strings.EqualFold(variant.SKU, sku) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyVariant returns variant with its own copies of the weight and grind units.
+func copyVariant(variant Variant) Variant {
+	if variant.WeightGrams != nil {
+		weight := *variant.WeightGrams
+		variant.WeightGrams = &weight
+	}
+	variant.GrindUnits = append([]int16{}, variant.GrindUnits...)
+
+	return variant
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed for prices that do not name their currency.
+const DefaultCurrency = "EUR"
+
+// ErrInvalidAmount is returned when a price is not a decimal number that fits
+// the minor units of its currency.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// currencyExponents lists the ISO 4217 currencies whose minor unit is not a
+// hundredth of the major unit. Every other currency uses two decimals.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Money is an exact amount in the minor units of an ISO 4217 currency, e.g.
+// {999, "EUR"} is 9.99 euros.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// CurrencyExponent returns the number of decimals of the minor unit of currency.
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+
+	return 2
+}
+
+// ParseMoney converts a decimal amount such as "9.99" into Money. It fails
+// when the amount has more decimals than the currency allows.
+func ParseMoney(amount, currency string) (Money, error) {
+	// big.Rat also parses fractions such as "1/3", which are not amounts.
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || strings.Contains(amount, "/") {
+		return Money{}, fmt.Errorf("%w: %q is not a decimal number", ErrInvalidAmount, amount)
+	}
+
+	exp := CurrencyExponent(currency)
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d decimal places", ErrInvalidAmount, currency, exp)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, amount)
+	}
+
+	return Money{Amount: r.Num().Int64(), Currency: currency}, nil
+}
+
+// String formats the amount in major units with the decimals of its currency,
+// e.g. "9.99"; the currency code is not included.
+func (m Money) String() string {
+	exp := CurrencyExponent(m.Currency)
+	digits := strconv.FormatUint(absInt64(m.Amount), 10)
+	if exp > 0 {
+		if len(digits) <= exp {
+			digits = strings.Repeat("0", exp-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+	}
+
+	if m.Amount < 0 {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// absInt64 returns |n| without overflowing on math.MinInt64.
+func absInt64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+
+	return uint64(n)
+}
+
+// IsCurrencyCode reports whether s looks like an ISO 4217 alphabetic code.
+func IsCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MarshalJSON encodes m as {"amount": 9.99, "currency": "EUR"} with the
+// amount as a JSON number in major units.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}{json.Number(m.String()), m.Currency})
+}
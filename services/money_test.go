@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Exact Amounts", func(t *testing.T) {
+		// Test that decimal amounts convert to minor units and back unchanged.
+		cases := []struct {
+			amount   string
+			currency string
+			want     int64
+			text     string
+		}{
+			{"9.99", "EUR", 999, "9.99"},
+			{"0.1", "USD", 10, "0.10"},
+			{"-0.05", "EUR", -5, "-0.05"},
+			{"1200", "JPY", 1200, "1200"},
+			{"1.234", "KWD", 1234, "1.234"},
+			{"1e2", "EUR", 10000, "100.00"},
+		}
+
+		for _, tc := range cases {
+			got, err := ParseMoney(tc.amount, tc.currency)
+			if err != nil {
+				t.Errorf("ParseMoney(%q, %s) error = %v", tc.amount, tc.currency, err)
+				continue
+			}
+
+			if got.Amount != tc.want || got.Currency != tc.currency {
+				t.Errorf("ParseMoney(%q, %s) = %+v, want %d %s", tc.amount, tc.currency, got, tc.want, tc.currency)
+			}
+
+			if got.String() != tc.text {
+				t.Errorf("Money.String() = %q, want %q", got.String(), tc.text)
+			}
+		}
+	})
+
+	t.Run("Invalid Amounts", func(t *testing.T) {
+		// Test that excess decimals, fractions and garbage are rejected.
+		for _, tc := range [][2]string{{"9.999", "EUR"}, {"1.5", "JPY"}, {"1/3", "EUR"}, {"cheap", "EUR"}, {"99999999999999999999", "EUR"}} {
+			if _, err := ParseMoney(tc[0], tc[1]); !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("ParseMoney(%q, %s) error = %v, want ErrInvalidAmount", tc[0], tc[1], err)
+			}
+		}
+	})
+}
+
+func TestCoffeeJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Numeric Price", func(t *testing.T) {
+		// Test that the price stays a JSON number next to its currency.
+		coffee := Coffee{Name: "Kenya AA", Price: Money{Amount: 999, Currency: "EUR"}}
+
+		data, err := json.Marshal(coffee)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(fields["price"]) != "9.99" || string(fields["currency"]) != `"EUR"` {
+			t.Errorf("json.Marshal() price = %s, currency = %s, want 9.99 and \"EUR\"", fields["price"], fields["currency"])
+		}
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		// Test that decoding an encoded coffee yields the same amount.
+		want := Coffee{Name: "Kenya AA", Price: Money{Amount: 1325, Currency: "USD"}}
+
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Coffee
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Price != want.Price || got.Name != want.Name {
+			t.Errorf("json.Unmarshal() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Default Currency", func(t *testing.T) {
+		// Test that legacy payloads without a currency are priced in euros.
+		var coffee Coffee
+		if err := json.Unmarshal([]byte(`{"name": "Java", "price": 9.99}`), &coffee); err != nil {
+			t.Fatal(err)
+		}
+
+		if coffee.Price != (Money{Amount: 999, Currency: DefaultCurrency}) {
+			t.Errorf("json.Unmarshal() price = %+v, want 999 %s", coffee.Price, DefaultCurrency)
+		}
+	})
+
+	t.Run("Unrepresentable Price", func(t *testing.T) {
+		// Test that sub-cent prices are reported as a field error.
+		var coffee Coffee
+		err := json.Unmarshal([]byte(`{"price": 9.999, "currency": "eur"}`), &coffee)
+
+		var fieldErrors ValidationErrors
+		if !errors.As(err, &fieldErrors) || fieldErrors["price"] == "" {
+			t.Errorf("json.Unmarshal() error = %v, want a price ValidationErrors", err)
+		}
+	})
+}
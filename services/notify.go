@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LowStockAlert reports a coffee whose available stock fell to or below its
+// reorder threshold.
+type LowStockAlert struct {
+	CoffeeID   string    `json:"coffee_id"`
+	CoffeeName string    `json:"coffee_name"`
+	Available  int       `json:"available"`
+	Threshold  int       `json:"threshold"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// String describes the alert for humans.
+func (a LowStockAlert) String() string {
+	return fmt.Sprintf("low stock: %s (%s) has %d available, reorder threshold %d", a.CoffeeName, a.CoffeeID, a.Available, a.Threshold)
+}
+
+// Notifier delivers low-stock alerts to the people who reorder coffee.
+type Notifier interface {
+	Notify(ctx context.Context, alert LowStockAlert) error
+}
+
+// LogNotifier writes alerts to a logger.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+// Notify logs the alert.
+func (n LogNotifier) Notify(ctx context.Context, alert LowStockAlert) error {
+	n.Logger.Println(alert)
+	return nil
+}
+
+// WebhookNotifier posts alerts as JSON to a URL, such as a chat webhook.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url with a short timeout.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the alert, failing unless the webhook answers with a 2xx status.
+func (n *WebhookNotifier) Notify(ctx context.Context, alert LowStockAlert) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+		LowStockAlert
+	}{alert.String(), alert})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// FileNotifier appends alerts as JSON lines to an outbox file that another
+// process, such as a mailer, picks up.
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Notify appends the alert to the outbox file, creating it when needed.
+func (n *FileNotifier) Notify(ctx context.Context, alert LowStockAlert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// MultiNotifier sends every alert to all of its notifiers, reporting the
+// failures of any of them.
+type MultiNotifier []Notifier
+
+// Notify sends the alert to each notifier in turn.
+func (m MultiNotifier) Notify(ctx context.Context, alert LowStockAlert) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
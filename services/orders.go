@@ -0,0 +1,695 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrVariantUnavailable is returned when ordering a variant that is not on sale.
+	ErrVariantUnavailable = errors.New("the variant is not available for sale")
+	// ErrIllegalTransition is returned when moving an order to a status that
+	// cannot follow its current one.
+	ErrIllegalTransition = errors.New("illegal order status transition")
+)
+
+// Order states. An order is placed pending and moves through paid,
+// preparing and ready to completed. It can be cancelled until it is paid and
+// refunded once it is.
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderPreparing = "preparing"
+	OrderReady     = "ready"
+	OrderCompleted = "completed"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+// OrderStatuses lists the accepted values of Order.Status in lifecycle order.
+var OrderStatuses = []string{OrderPending, OrderPaid, OrderPreparing, OrderReady, OrderCompleted, OrderCancelled, OrderRefunded}
+
+// orderTransitions maps every status to the statuses that may follow it.
+// Cancelled and refunded orders are final.
+var orderTransitions = map[string][]string{
+	OrderPending:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderPreparing, OrderRefunded},
+	OrderPreparing: {OrderReady, OrderRefunded},
+	OrderReady:     {OrderCompleted, OrderRefunded},
+	OrderCompleted: {OrderRefunded},
+}
+
+const (
+	// MaxOrderItems caps the number of lines of one order.
+	MaxOrderItems = 50
+	// MaxItemQuantity caps the quantity of one line.
+	MaxItemQuantity = 100
+)
+
+// OrderRepository stores orders. Get and Transition report a missing order
+// as sql.ErrNoRows. Transition moves an order to a new status with
+// Order.Transition, committing its stock reservations when it is paid and
+// releasing them when it is cancelled, all in one transaction. Stale lists
+// the IDs of the orders still pending that were placed before a cut-off,
+// oldest first, for OrderExpirer to cancel.
+type OrderRepository interface {
+	Create(ctx context.Context, order Order) (*Order, error)
+	Get(ctx context.Context, id string) (*Order, error)
+	Transition(ctx context.Context, id, status string) (*Order, error)
+	Stale(ctx context.Context, placedBefore time.Time) ([]string, error)
+}
+
+// Order is a purchase of one or more coffees. Its items keep the name and
+// price the coffees had when it was placed, so later catalog changes never
+// alter it. All amounts are in the currency of its items. Timeline records
+// when the order entered each status it went through.
+type Order struct {
+	ID        string
+	Status    string
+	Timeline  map[string]time.Time
+	Items     []*OrderItem
+	Subtotal  Money
+	TaxRate   *big.Rat
+	Tax       Money
+	Total     Money
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MarshalJSON encodes the amounts as JSON numbers in major units next to the
+// currency of the order.
+func (o Order) MarshalJSON() ([]byte, error) {
+	taxRate := json.Number("0")
+	if o.TaxRate != nil {
+		taxRate = json.Number(formatRate(o.TaxRate))
+	}
+
+	return json.Marshal(struct {
+		ID        string               `json:"id"`
+		Status    string               `json:"status"`
+		Timeline  map[string]time.Time `json:"timeline"`
+		Currency  string               `json:"currency"`
+		Items     []*OrderItem         `json:"items"`
+		Subtotal  json.Number          `json:"subtotal"`
+		TaxRate   json.Number          `json:"tax_rate"`
+		Tax       json.Number          `json:"tax"`
+		Total     json.Number          `json:"total"`
+		CreatedAt time.Time            `json:"created_at"`
+		UpdatedAt time.Time            `json:"updated_at"`
+	}{
+		ID:        o.ID,
+		Status:    o.Status,
+		Timeline:  o.Timeline,
+		Currency:  o.Total.Currency,
+		Items:     o.Items,
+		Subtotal:  json.Number(o.Subtotal.String()),
+		TaxRate:   taxRate,
+		Tax:       json.Number(o.Tax.String()),
+		Total:     json.Number(o.Total.String()),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	})
+}
+
+// OrderItem is one line of an order. Clients only supply the coffee, the
+// optional variant and the quantity; the rest is filled in when the order
+// is placed. ReservationID is set for coffees whose stock is tracked.
+type OrderItem struct {
+	ID            string `json:"id"`
+	CoffeeID      string `json:"coffee_id"`
+	VariantID     string `json:"variant_id,omitempty"`
+	Name          string `json:"name"`
+	UnitPrice     Money  `json:"-"`
+	Quantity      int    `json:"quantity"`
+	LineTotal     Money  `json:"-"`
+	ReservationID string `json:"reservation_id,omitempty"`
+}
+
+// orderItemFields has the fields of OrderItem without its JSON methods.
+type orderItemFields OrderItem
+
+// MarshalJSON encodes the prices as JSON numbers in major units.
+func (i OrderItem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		orderItemFields
+		UnitPrice json.Number `json:"unit_price"`
+		LineTotal json.Number `json:"line_total"`
+	}{orderItemFields(i), json.Number(i.UnitPrice.String()), json.Number(i.LineTotal.String())})
+}
+
+// Validate checks the client supplied items of an order and returns the
+// problems found, or nil when the order is valid. Problems with the items
+// are reported under "items", naming the first offending line.
+func (o *Order) Validate() ValidationErrors {
+	v := ValidationErrors{}
+
+	v.check(len(o.Items) > 0, "items", "must contain at least one item")
+	v.check(len(o.Items) <= MaxOrderItems, "items", fmt.Sprintf("must not contain more than %d items", MaxOrderItems))
+
+	for i, item := range o.Items {
+		v.check(isUUID(item.CoffeeID), "items", fmt.Sprintf("item %d must name a coffee on the menu", i+1))
+		v.check(item.VariantID == "" || isUUID(item.VariantID), "items", fmt.Sprintf("item %d must name a variant of its coffee", i+1))
+		v.check(item.Quantity > 0 && item.Quantity <= MaxItemQuantity, "items", fmt.Sprintf("item %d must have a quantity between 1 and %d", i+1, MaxItemQuantity))
+	}
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// Transition moves the order to status at the given time. An unknown status
+// is reported as a ValidationErrors and one that cannot follow the current
+// status as a conflict wrapping ErrIllegalTransition.
+func (o *Order) Transition(status string, at time.Time) error {
+	v := ValidationErrors{}
+	v.check(status != "", "status", "must be provided")
+	v.check(status == "" || slices.Contains(OrderStatuses, status), "status", "must be one of "+strings.Join(OrderStatuses, ", "))
+	if len(v) > 0 {
+		return v
+	}
+
+	if !slices.Contains(orderTransitions[o.Status], status) {
+		return NewError(KindConflict, fmt.Errorf("%w: a %s order cannot become %s", ErrIllegalTransition, o.Status, status))
+	}
+
+	if o.Timeline == nil {
+		o.Timeline = make(map[string]time.Time)
+	}
+	o.Status = status
+	o.Timeline[status] = at
+	o.UpdatedAt = at
+
+	return nil
+}
+
+// reservationStatus returns the status the stock reservations of an order
+// move to when the order enters status, or "" when they stay as they are.
+// Paying turns them into sales; refunds leave the sold stock alone.
+func reservationStatus(status string) string {
+	switch status {
+	case OrderPaid:
+		return ReservationCommitted
+	case OrderCancelled:
+		return ReservationReleased
+	default:
+		return ""
+	}
+}
+
+// reservations returns the IDs of the stock reservations of the order items.
+func (o *Order) reservations() []string {
+	var ids []string
+	for _, item := range o.Items {
+		if item.ReservationID != "" {
+			ids = append(ids, item.ReservationID)
+		}
+	}
+
+	return ids
+}
+
+// ParseTaxRate parses a sales tax rate given as a decimal fraction such as
+// "0.19", which must be at least zero and below one.
+func ParseTaxRate(s string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || strings.Contains(s, "/") || rate.Sign() < 0 || rate.Cmp(big.NewRat(1, 1)) >= 0 {
+		return nil, fmt.Errorf("%q is not a tax rate between 0 and 1", s)
+	}
+
+	return rate, nil
+}
+
+// priceItem snapshots the name and unit price of the nth line of an order
+// from its coffee, with the schedule in effect and the variant named by the
+// line, either of which may be nil. A missing coffee or variant is reported
+// as a ValidationErrors.
+func priceItem(item *OrderItem, n int, coffee *Coffee, schedule *PriceSchedule, variant *Variant) error {
+	if coffee == nil {
+		return ValidationErrors{"items": fmt.Sprintf("item %d must name a coffee on the menu", n)}
+	}
+
+	item.Name = coffee.Name
+	item.UnitPrice = coffee.Price
+	if schedule != nil {
+		item.UnitPrice = schedule.Price
+	}
+
+	if item.VariantID != "" {
+		if variant == nil {
+			return ValidationErrors{"items": fmt.Sprintf("item %d must name a variant of its coffee", n)}
+		}
+		if !variant.Available {
+			return NewError(KindConflict, fmt.Errorf("%w: %s", ErrVariantUnavailable, variant.SKU))
+		}
+		item.Name = coffee.Name + " (" + variant.Size + ")"
+		item.UnitPrice = variant.Price
+	}
+
+	return nil
+}
+
+// byStockKey returns the items of an order sorted by coffee and variant, the
+// order in which their stock is reserved. Orders that lock the same stock
+// levels always lock them in the same order, so they cannot deadlock.
+func byStockKey(items []*OrderItem) []*OrderItem {
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b *OrderItem) int {
+		if c := strings.Compare(a.CoffeeID, b.CoffeeID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.VariantID, b.VariantID)
+	})
+
+	return sorted
+}
+
+// total computes the line totals, subtotal, tax and total of an order whose
+// items are priced, rounding the tax half away from zero.
+func (o *Order) total() error {
+	if len(o.Items) == 0 {
+		return ValidationErrors{"items": "must contain at least one item"}
+	}
+
+	currency := o.Items[0].UnitPrice.Currency
+	subtotal := Money{Currency: currency}
+
+	for i, item := range o.Items {
+		if item.UnitPrice.Currency != currency {
+			return ValidationErrors{"items": fmt.Sprintf("item %d is priced in %s while the order is in %s", i+1, item.UnitPrice.Currency, currency)}
+		}
+		item.LineTotal = Money{Amount: item.UnitPrice.Amount * int64(item.Quantity), Currency: currency}
+		subtotal.Amount += item.LineTotal.Amount
+	}
+
+	if o.TaxRate == nil {
+		o.TaxRate = new(big.Rat)
+	}
+
+	tax, ok := roundHalfAway(new(big.Rat).Mul(new(big.Rat).SetInt64(subtotal.Amount), o.TaxRate))
+	if !ok {
+		return fmt.Errorf("%w: tax is out of range", ErrInvalidAmount)
+	}
+
+	o.Subtotal = subtotal
+	o.Tax = Money{Amount: tax, Currency: currency}
+	o.Total = Money{Amount: subtotal.Amount + tax, Currency: currency}
+
+	return nil
+}
+
+// PostgresOrderRepository is an OrderRepository backed by the orders and
+// order_items tables.
+type PostgresOrderRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresOrderRepository creates a repository using the given connection pool.
+func NewPostgresOrderRepository(db *sql.DB) *PostgresOrderRepository {
+	return &PostgresOrderRepository{db: db}
+}
+
+// Create places an order in one transaction: it snapshots the current name
+// and price of every item, computes the totals, reserves the stock of
+// tracked coffees and stores the order with its items. Coffees and variants
+// are read FOR SHARE so that they cannot change until the order is stored,
+// and stock levels are locked in byStockKey order.
+func (r *PostgresOrderRepository) Create(ctx context.Context, order Order) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for i, item := range order.Items {
+		if err := priceItemTx(ctx, tx, item, i+1, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := order.total(); err != nil {
+		return nil, err
+	}
+
+	for _, item := range byStockKey(order.Items) {
+		if err := reserveItemTx(ctx, tx, item); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+        INSERT INTO orders(status, currency, subtotal_minor, tax_rate, tax_minor, total_minor, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+        RETURNING id, created_at, updated_at
+    `
+
+	order.Status = OrderPending
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		order.Status,
+		order.Total.Currency,
+		order.Subtotal.Amount,
+		order.TaxRate.FloatString(rateDecimals),
+		order.Tax.Amount,
+		order.Total.Amount,
+		now,
+	).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	order.Timeline = map[string]time.Time{OrderPending: order.CreatedAt}
+
+	query = `
+        INSERT INTO order_items(order_id, position, coffee_id, variant_id, name, unit_price_minor, quantity, line_total_minor, reservation_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id
+    `
+
+	for i, item := range order.Items {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			order.ID,
+			i+1,
+			item.CoffeeID,
+			nullString(item.VariantID),
+			item.Name,
+			item.UnitPrice.Amount,
+			item.Quantity,
+			item.LineTotal.Amount,
+			nullString(item.ReservationID),
+		).Scan(&item.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// priceItemTx reads the coffee, schedule and variant of the nth line of an
+// order within tx and prices the line with priceItem.
+func priceItemTx(ctx context.Context, tx *sql.Tx, item *OrderItem, n int, at time.Time) error {
+	coffee := &Coffee{ID: item.CoffeeID}
+	err := tx.QueryRowContext(ctx, `SELECT name, price_minor, currency FROM coffees WHERE id = $1 AND deleted_at IS NULL FOR SHARE`, item.CoffeeID).
+		Scan(&coffee.Name, &coffee.Price.Amount, &coffee.Price.Currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		coffee = nil
+	} else if err != nil {
+		return err
+	}
+
+	var schedule *PriceSchedule
+	if coffee != nil {
+		query := `
+		SELECT price_minor, currency FROM price_schedules
+		WHERE coffee_id = $1 AND effective_from <= $2 AND (effective_to IS NULL OR effective_to > $2)
+		ORDER BY effective_from DESC
+		LIMIT 1
+		`
+		var active PriceSchedule
+		err := tx.QueryRowContext(ctx, query, item.CoffeeID, at).Scan(&active.Price.Amount, &active.Price.Currency)
+		if err == nil {
+			schedule = &active
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	var variant *Variant
+	if coffee != nil && item.VariantID != "" {
+		found := Variant{ID: item.VariantID}
+		err := tx.QueryRowContext(ctx, `SELECT sku, size, price_minor, currency, available FROM coffee_variants WHERE id = $1 AND coffee_id = $2 FOR SHARE`, item.VariantID, item.CoffeeID).
+			Scan(&found.SKU, &found.Size, &found.Price.Amount, &found.Price.Currency, &found.Available)
+		if err == nil {
+			variant = &found
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	return priceItem(item, n, coffee, schedule, variant)
+}
+
+// reserveItemTx reserves the stock of an order line within tx when its
+// coffee has stock levels, at DefaultLocation. Untracked coffees are sold
+// without a reservation.
+func reserveItemTx(ctx context.Context, tx *sql.Tx, item *OrderItem) error {
+	var tracked bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM stock_levels WHERE coffee_id = $1)`, item.CoffeeID).Scan(&tracked); err != nil {
+		return err
+	}
+	if !tracked {
+		return nil
+	}
+
+	reservation := Reservation{StockKey: StockKey{CoffeeID: item.CoffeeID, VariantID: item.VariantID, Location: DefaultLocation}, Quantity: item.Quantity}
+	if err := reserveTx(ctx, tx, &reservation); err != nil {
+		return err
+	}
+	item.ReservationID = reservation.ID
+
+	return nil
+}
+
+// Get returns the order with the given ID and its items.
+func (r *PostgresOrderRepository) Get(ctx context.Context, id string) (*Order, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	return getOrder(ctx, r.db, id, false)
+}
+
+// Transition moves an order to status in one transaction, locking the order
+// row so that concurrent transitions are applied one after the other.
+func (r *PostgresOrderRepository) Transition(ctx context.Context, id, status string) (*Order, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := getOrder(ctx, tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := order.Transition(status, time.Now().UTC().Truncate(time.Microsecond)); err != nil {
+		return nil, err
+	}
+
+	// The status is one of OrderStatuses, so it names a column.
+	query := `UPDATE orders SET status = $2, ` + status + `_at = $3, updated_at = $3 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id, status, order.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if closed := reservationStatus(status); closed != "" {
+		for _, reservationID := range order.reservations() {
+			if _, err := closeTx(ctx, tx, reservationID, closed); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// Stale returns the IDs of the pending orders placed before placedBefore,
+// oldest first.
+func (r *PostgresOrderRepository) Stale(ctx context.Context, placedBefore time.Time) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM orders WHERE status = $1 AND created_at < $2 ORDER BY created_at, id`, OrderPending, placedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// OrderExpirer periodically cancels the orders left pending for longer than
+// Expiry, releasing the stock they reserved.
+type OrderExpirer struct {
+	Orders   OrderRepository
+	Expiry   time.Duration
+	Interval time.Duration
+	Logger   *log.Logger
+}
+
+// Run expires orders every Interval until ctx is done.
+func (e *OrderExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := e.Expire(ctx); err != nil && ctx.Err() == nil {
+			e.Logger.Printf("OrderExpirer: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Expire runs one pass and returns the number of orders cancelled. Orders
+// paid or cancelled since they were listed are skipped.
+func (e *OrderExpirer) Expire(ctx context.Context) (int, error) {
+	ids, err := e.Orders.Stale(ctx, time.Now().Add(-e.Expiry))
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		_, err := e.Orders.Transition(ctx, id, OrderCancelled)
+		if errors.Is(err, ErrIllegalTransition) || errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+
+	return cancelled, nil
+}
+
+// orderQueryer is implemented by both *sql.DB and *sql.Tx.
+type orderQueryer interface {
+	queryer
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// orderTimeColumns lists the columns holding when an order entered each
+// status after pending, which is its created_at.
+var orderTimeColumns = func() []string {
+	columns := make([]string, 0, len(OrderStatuses)-1)
+	for _, status := range OrderStatuses[1:] {
+		columns = append(columns, status+"_at")
+	}
+
+	return columns
+}()
+
+// getOrder reads an order and its items through q, locking the order row
+// FOR UPDATE when lock is set.
+func getOrder(ctx context.Context, q orderQueryer, id string, lock bool) (*Order, error) {
+	query := `
+	SELECT id, status, currency, subtotal_minor, tax_rate::text, tax_minor, total_minor, created_at, updated_at, ` + strings.Join(orderTimeColumns, ", ") + `
+	FROM orders
+	WHERE id = $1
+	`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var order Order
+	var currency, taxRate string
+	times := make([]sql.NullTime, len(orderTimeColumns))
+	dest := []interface{}{
+		&order.ID,
+		&order.Status,
+		&currency,
+		&order.Subtotal.Amount,
+		&taxRate,
+		&order.Tax.Amount,
+		&order.Total.Amount,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	}
+	for i := range times {
+		dest = append(dest, &times[i])
+	}
+
+	if err := q.QueryRowContext(ctx, query, id).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	order.Subtotal.Currency, order.Tax.Currency, order.Total.Currency = currency, currency, currency
+	var err error
+	if order.TaxRate, err = ParseTaxRate(taxRate); err != nil {
+		return nil, err
+	}
+
+	order.Timeline = map[string]time.Time{OrderPending: order.CreatedAt}
+	for i, t := range times {
+		if t.Valid {
+			order.Timeline[OrderStatuses[i+1]] = t.Time
+		}
+	}
+
+	query = `
+	SELECT id, coffee_id, COALESCE(variant_id::text, ''), name, unit_price_minor, quantity, line_total_minor, COALESCE(reservation_id::text, '')
+	FROM order_items
+	WHERE order_id = $1
+	ORDER BY position
+	`
+
+	rows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	order.Items = []*OrderItem{}
+	for rows.Next() {
+		item := OrderItem{UnitPrice: Money{Currency: currency}, LineTotal: Money{Currency: currency}}
+		if err := rows.Scan(&item.ID, &item.CoffeeID, &item.VariantID, &item.Name, &item.UnitPrice.Amount, &item.Quantity, &item.LineTotal.Amount, &item.ReservationID); err != nil {
+			return nil, err
+		}
+		order.Items = append(order.Items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOrderValidate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("No Items", func(t *testing.T) {
+		// Test that an order must buy something.
+		order := Order{}
+		if errs := order.Validate(); errs["items"] == "" {
+			t.Errorf("Validate() = %v, want an error for items", errs)
+		}
+	})
+
+	t.Run("Invalid Item", func(t *testing.T) {
+		// Test that the first offending line is named.
+		order := Order{Items: []*OrderItem{
+			{CoffeeID: testCoffeeID, Quantity: 1},
+			{CoffeeID: testCoffeeID, Quantity: MaxItemQuantity + 1},
+		}}
+		if errs := order.Validate(); errs["items"] != "item 2 must have a quantity between 1 and 100" {
+			t.Errorf("Validate() = %v, want an error for item 2", errs)
+		}
+	})
+}
+
+func TestOrderTotal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Tax Rounded", func(t *testing.T) {
+		// Test the line totals and a tax rounded half away from zero.
+		order := Order{TaxRate: big.NewRat(19, 100), Items: []*OrderItem{
+			{UnitPrice: Money{350, "EUR"}, Quantity: 2},
+			{UnitPrice: Money{1250, "EUR"}, Quantity: 1},
+		}}
+
+		if err := order.total(); err != nil {
+			t.Fatal(err)
+		}
+		if order.Items[0].LineTotal != (Money{700, "EUR"}) {
+			t.Errorf("LineTotal = %v, want 7.00 EUR", order.Items[0].LineTotal)
+		}
+		// 19% of 19.50 is 3.705.
+		if order.Subtotal.Amount != 1950 || order.Tax.Amount != 371 || order.Total.Amount != 2321 {
+			t.Errorf("totals = %v + %v = %v, want 19.50 + 3.71 = 23.21", order.Subtotal, order.Tax, order.Total)
+		}
+	})
+
+	t.Run("Mixed Currencies", func(t *testing.T) {
+		// Test that an order is priced in a single currency.
+		order := Order{Items: []*OrderItem{
+			{UnitPrice: Money{350, "EUR"}, Quantity: 1},
+			{UnitPrice: Money{400, "USD"}, Quantity: 1},
+		}}
+		if err := order.total(); KindOf(err) != KindValidation {
+			t.Errorf("total() error = %v, want a validation error", err)
+		}
+	})
+}
+
+func TestParseTaxRate(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"0", "0.19", " 0.075 "} {
+		if _, err := ParseTaxRate(s); err != nil {
+			t.Errorf("ParseTaxRate(%q) error = %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "1", "-0.1", "19%", "19/100"} {
+		if _, err := ParseTaxRate(s); err == nil {
+			t.Errorf("ParseTaxRate(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestMemoryOrderRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	models := NewMemory()
+
+	espresso, _ := models.Coffees.Create(ctx, Coffee{Name: "Espresso", Price: Money{350, "EUR"}})
+	geisha, _ := models.Coffees.Create(ctx, Coffee{Name: "Geisha", Price: Money{1800, "EUR"}})
+	large, _ := models.Variants.Create(ctx, Variant{CoffeeID: geisha.ID, SKU: "GEI-L", Size: "large", Price: Money{2200, "EUR"}, Available: true})
+	retired, _ := models.Variants.Create(ctx, Variant{CoffeeID: geisha.ID, SKU: "GEI-S", Size: "small", Price: Money{1500, "EUR"}})
+	if _, err := models.Schedules.Create(ctx, PriceSchedule{CoffeeID: espresso.ID, Price: Money{300, "EUR"}, EffectiveFrom: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Inventory.Adjust(ctx, Adjustment{StockKey: StockKey{CoffeeID: geisha.ID, VariantID: large.ID, Location: DefaultLocation}, Delta: 3, Reason: ReasonReceived}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Placed", func(t *testing.T) {
+		// Test that prices are snapshotted and tracked stock is reserved.
+		order, err := models.Orders.Create(ctx, Order{TaxRate: big.NewRat(1, 10), Items: []*OrderItem{
+			{CoffeeID: espresso.ID, Quantity: 2},
+			{CoffeeID: geisha.ID, VariantID: large.ID, Quantity: 1},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if order.Status != OrderPending || order.Total.Amount != 3080 {
+			t.Errorf("order = %s, %v, want pending, 30.80 EUR", order.Status, order.Total)
+		}
+		if order.Items[0].UnitPrice.Amount != 300 || order.Items[0].ReservationID != "" {
+			t.Errorf("espresso item = %+v, want the scheduled price and no reservation", order.Items[0])
+		}
+		if order.Items[1].Name != "Geisha (large)" || order.Items[1].ReservationID == "" {
+			t.Errorf("geisha item = %+v, want the variant name and a reservation", order.Items[1])
+		}
+
+		// Later catalog changes leave the order alone.
+		espresso.Price = Money{500, "EUR"}
+		if _, err := models.Coffees.Update(ctx, *espresso, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := models.Orders.Get(ctx, order.ID)
+		if err != nil || stored.Items[0].UnitPrice.Amount != 300 {
+			t.Errorf("Get() = %+v, %v, want the price paid", stored, err)
+		}
+	})
+
+	t.Run("Insufficient Stock", func(t *testing.T) {
+		// Test that an order is refused as a whole when one line cannot be met.
+		_, err := models.Orders.Create(ctx, Order{Items: []*OrderItem{
+			{CoffeeID: geisha.ID, VariantID: large.ID, Quantity: 1},
+			{CoffeeID: geisha.ID, VariantID: large.ID, Quantity: 2},
+		}})
+		if !errors.Is(err, ErrInsufficientStock) || KindOf(err) != KindConflict {
+			t.Fatalf("Create() error = %v, want a conflict wrapping ErrInsufficientStock", err)
+		}
+
+		levels, _ := models.Inventory.Levels(ctx, geisha.ID)
+		if len(levels) != 1 || levels[0].Reserved != 1 {
+			t.Errorf("levels = %+v, want only the earlier reservation", levels)
+		}
+	})
+
+	t.Run("Unavailable Variant", func(t *testing.T) {
+		// Test that variants off sale cannot be ordered.
+		_, err := models.Orders.Create(ctx, Order{Items: []*OrderItem{{CoffeeID: geisha.ID, VariantID: retired.ID, Quantity: 1}}})
+		if !errors.Is(err, ErrVariantUnavailable) || KindOf(err) != KindConflict {
+			t.Errorf("Create() error = %v, want a conflict wrapping ErrVariantUnavailable", err)
+		}
+	})
+
+	t.Run("Unknown Coffee", func(t *testing.T) {
+		// Test that unknown coffees are reported against the offending line.
+		_, err := models.Orders.Create(ctx, Order{Items: []*OrderItem{{CoffeeID: missingCoffeeID, Quantity: 1}}})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || errs["items"] != "item 1 must name a coffee on the menu" {
+			t.Errorf("Create() error = %v, want a validation error for item 1", err)
+		}
+	})
+}
+
+func TestPostgresOrderCreate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Placed", func(t *testing.T) {
+		// Test that pricing, the stock check and the inserts share one transaction.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		now := time.Now()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM coffees WHERE id = $1 AND deleted_at IS NULL FOR SHARE")).
+			WithArgs(testCoffeeID).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "price_minor", "currency"}).AddRow("Espresso", 350, "EUR"))
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY effective_from DESC LIMIT 1")).WillReturnRows(sqlmock.NewRows([]string{"price_minor", "currency"}))
+		mock.ExpectQuery("FROM stock_levels").WithArgs(testCoffeeID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery("^INSERT INTO orders").
+			WithArgs(OrderPending, "EUR", 700, "0.100000000000", 70, 770, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(missingCoffeeID, now, now))
+		mock.ExpectQuery("^INSERT INTO order_items").
+			WithArgs(missingCoffeeID, 1, testCoffeeID, nil, "Espresso", 350, 2, 700, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(missingCoffeeID))
+		mock.ExpectCommit()
+
+		order, err := NewPostgresOrderRepository(db).Create(context.Background(), Order{TaxRate: big.NewRat(1, 10), Items: []*OrderItem{{CoffeeID: testCoffeeID, Quantity: 2}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.ID != missingCoffeeID || order.Total.Amount != 770 {
+			t.Errorf("Create() = %+v, want the stored order totalling 7.70", order)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Unknown Coffee", func(t *testing.T) {
+		// Test that nothing is written when a line names no coffee.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM coffees").WillReturnRows(sqlmock.NewRows([]string{"name", "price_minor", "currency"}))
+		mock.ExpectRollback()
+
+		_, err := NewPostgresOrderRepository(db).Create(context.Background(), Order{Items: []*OrderItem{{CoffeeID: missingCoffeeID, Quantity: 1}}})
+		if KindOf(err) != KindValidation {
+			t.Errorf("Create() error = %v, want a validation error", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestOrderTransition(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		// Test the happy path, recording when each status was entered.
+		order := Order{Status: OrderPending}
+		for i, status := range []string{OrderPaid, OrderPreparing, OrderReady, OrderCompleted, OrderRefunded} {
+			at := now.Add(time.Duration(i) * time.Minute)
+			if err := order.Transition(status, at); err != nil {
+				t.Fatalf("Transition(%s) error = %v", status, err)
+			}
+			if order.Status != status || !order.Timeline[status].Equal(at) {
+				t.Errorf("after Transition(%s) order = %s, %v", status, order.Status, order.Timeline)
+			}
+		}
+	})
+
+	t.Run("Illegal Move", func(t *testing.T) {
+		// Test that skipping a step and leaving a final status are conflicts.
+		for _, tc := range []struct{ from, to string }{
+			{OrderPending, OrderReady},
+			{OrderPending, OrderRefunded},
+			{OrderPaid, OrderCancelled},
+			{OrderCancelled, OrderPaid},
+			{OrderRefunded, OrderCompleted},
+			{OrderCompleted, OrderCompleted},
+		} {
+			order := Order{Status: tc.from}
+			err := order.Transition(tc.to, now)
+			if !errors.Is(err, ErrIllegalTransition) || KindOf(err) != KindConflict {
+				t.Errorf("%s -> %s error = %v, want a conflict wrapping ErrIllegalTransition", tc.from, tc.to, err)
+			}
+			if order.Status != tc.from {
+				t.Errorf("%s -> %s left the order %s", tc.from, tc.to, order.Status)
+			}
+		}
+	})
+
+	t.Run("Unknown Status", func(t *testing.T) {
+		// Test that unknown statuses are rejected as invalid.
+		order := Order{Status: OrderPending}
+		if err := order.Transition("shipped", now); KindOf(err) != KindValidation {
+			t.Errorf("Transition() error = %v, want a validation error", err)
+		}
+	})
+}
+
+func TestMemoryOrderTransition(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	models := NewMemory()
+
+	coffee, _ := models.Coffees.Create(ctx, Coffee{Name: "Geisha", Price: Money{1800, "EUR"}})
+	key := StockKey{CoffeeID: coffee.ID, Location: DefaultLocation}
+	if _, err := models.Inventory.Adjust(ctx, Adjustment{StockKey: key, Delta: 5, Reason: ReasonReceived}); err != nil {
+		t.Fatal(err)
+	}
+
+	place := func(t *testing.T) *Order {
+		order, err := models.Orders.Create(ctx, Order{Items: []*OrderItem{{CoffeeID: coffee.ID, Quantity: 2}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order
+	}
+	reservation := func(t *testing.T, order *Order) *Reservation {
+		reservation, err := models.Inventory.GetReservation(ctx, order.Items[0].ReservationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reservation
+	}
+
+	t.Run("Paid", func(t *testing.T) {
+		// Test that paying for an order turns its reservation into a sale.
+		order := place(t)
+
+		paid, err := models.Orders.Transition(ctx, order.ID, OrderPaid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paid.Timeline[OrderPaid].IsZero() || paid.Timeline[OrderPending].IsZero() {
+			t.Errorf("Timeline = %v, want pending and paid", paid.Timeline)
+		}
+		if got := reservation(t, order); got.Status != ReservationCommitted {
+			t.Errorf("reservation = %s, want committed", got.Status)
+		}
+
+		stored, _ := models.Orders.Get(ctx, order.ID)
+		if stored.Status != OrderPaid {
+			t.Errorf("Get() status = %s, want paid", stored.Status)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		// Test that cancelling an order returns its stock to the shelf.
+		order := place(t)
+
+		if _, err := models.Orders.Transition(ctx, order.ID, OrderCancelled); err != nil {
+			t.Fatal(err)
+		}
+		if got := reservation(t, order); got.Status != ReservationReleased {
+			t.Errorf("reservation = %s, want released", got.Status)
+		}
+
+		_, err := models.Orders.Transition(ctx, order.ID, OrderPaid)
+		if !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("Transition() error = %v, want ErrIllegalTransition", err)
+		}
+	})
+
+	t.Run("Reservation Closed Outside Order", func(t *testing.T) {
+		// Test that the inventory cannot close the reservation of a pending order.
+		order := place(t)
+
+		if _, err := models.Inventory.Release(ctx, order.Items[0].ReservationID); !errors.Is(err, ErrReservationOrdered) || KindOf(err) != KindConflict {
+			t.Errorf("Release() error = %v, want a conflict wrapping ErrReservationOrdered", err)
+		}
+		if _, err := models.Inventory.Commit(ctx, order.Items[0].ReservationID); !errors.Is(err, ErrReservationOrdered) || KindOf(err) != KindConflict {
+			t.Errorf("Commit() error = %v, want a conflict wrapping ErrReservationOrdered", err)
+		}
+
+		if _, err := models.Orders.Transition(ctx, order.ID, OrderPaid); err != nil {
+			t.Errorf("Transition() error = %v, want the order paid", err)
+		}
+		if got := reservation(t, order); got.Status != ReservationCommitted {
+			t.Errorf("reservation = %s, want committed", got.Status)
+		}
+	})
+
+	t.Run("Unknown Order", func(t *testing.T) {
+		// Test that a missing order is reported as sql.ErrNoRows.
+		if _, err := models.Orders.Transition(ctx, missingCoffeeID, OrderPaid); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Transition() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestByStockKey(t *testing.T) {
+	t.Parallel()
+
+	// Test that stock is reserved by coffee then variant, whatever the line order.
+	items := []*OrderItem{
+		{CoffeeID: missingCoffeeID},
+		{CoffeeID: testCoffeeID, VariantID: "b"},
+		{CoffeeID: testCoffeeID},
+		{CoffeeID: testCoffeeID, VariantID: "a"},
+	}
+
+	sorted := byStockKey(items)
+	want := []*OrderItem{items[0], items[2], items[3], items[1]}
+	for i := range want {
+		if sorted[i] != want[i] {
+			t.Fatalf("byStockKey()[%d] = %+v, want %+v", i, sorted[i], want[i])
+		}
+	}
+	if items[1].VariantID != "b" {
+		t.Error("byStockKey() reordered the order lines")
+	}
+}
+
+func TestOrderExpirer(t *testing.T) {
+	t.Parallel()
+
+	// Test that stale pending orders are cancelled and release their stock,
+	// while paid orders are left alone.
+	ctx := context.Background()
+	models := NewMemory()
+
+	coffee, _ := models.Coffees.Create(ctx, Coffee{Name: "Geisha", Price: Money{1800, "EUR"}})
+	if _, err := models.Inventory.Adjust(ctx, Adjustment{StockKey: StockKey{CoffeeID: coffee.ID, Location: DefaultLocation}, Delta: 5, Reason: ReasonReceived}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := models.Orders.Create(ctx, Order{Items: []*OrderItem{{CoffeeID: coffee.ID, Quantity: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, err := models.Orders.Create(ctx, Order{Items: []*OrderItem{{CoffeeID: coffee.ID, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Orders.Transition(ctx, paid.ID, OrderPaid); err != nil {
+		t.Fatal(err)
+	}
+
+	expirer := &OrderExpirer{Orders: models.Orders}
+	if n, err := expirer.Expire(ctx); n != 1 || err != nil {
+		t.Fatalf("Expire() = %d, %v, want 1 order", n, err)
+	}
+
+	if stored, _ := models.Orders.Get(ctx, pending.ID); stored.Status != OrderCancelled {
+		t.Errorf("pending order = %s, want cancelled", stored.Status)
+	}
+	if stored, _ := models.Orders.Get(ctx, paid.ID); stored.Status != OrderPaid {
+		t.Errorf("paid order = %s, want paid", stored.Status)
+	}
+	if reservation, _ := models.Inventory.GetReservation(ctx, pending.Items[0].ReservationID); reservation.Status != ReservationReleased {
+		t.Errorf("reservation = %s, want released", reservation.Status)
+	}
+}
+
+func TestPostgresOrderTransition(t *testing.T) {
+	t.Parallel()
+
+	orderColumns := []string{"id", "status", "currency", "subtotal_minor", "tax_rate", "tax_minor", "total_minor", "created_at", "updated_at",
+		"paid_at", "preparing_at", "ready_at", "completed_at", "cancelled_at", "refunded_at"}
+	itemColumns := []string{"id", "coffee_id", "variant_id", "name", "unit_price_minor", "quantity", "line_total_minor", "reservation_id"}
+	lock := `(?s)FROM orders.*FOR UPDATE`
+
+	expectOrder := func(mock sqlmock.Sqlmock, status string, paidAt interface{}) {
+		now := time.Now()
+		mock.ExpectQuery(lock).WithArgs(testCoffeeID).
+			WillReturnRows(sqlmock.NewRows(orderColumns).AddRow(testCoffeeID, status, "EUR", 700, "0", 0, 700, now, now, paidAt, nil, nil, nil, nil, nil))
+		mock.ExpectQuery("FROM order_items").WithArgs(testCoffeeID).
+			WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(missingCoffeeID, testCoffeeID, "", "Espresso", 350, 2, 700, ""))
+	}
+
+	t.Run("Preparing", func(t *testing.T) {
+		// Test that the order row is locked and the status and its time stored together.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		expectOrder(mock, OrderPaid, time.Now())
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE orders SET status = $2, preparing_at = $3, updated_at = $3 WHERE id = $1")).
+			WithArgs(testCoffeeID, OrderPreparing, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		order, err := NewPostgresOrderRepository(db).Transition(context.Background(), testCoffeeID, OrderPreparing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Status != OrderPreparing || len(order.Timeline) != 3 {
+			t.Errorf("Transition() = %s, %v, want preparing with three timestamps", order.Status, order.Timeline)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Illegal Move", func(t *testing.T) {
+		// Test that an illegal move is rolled back without writing.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		expectOrder(mock, OrderPending, nil)
+		mock.ExpectRollback()
+
+		_, err := NewPostgresOrderRepository(db).Transition(context.Background(), testCoffeeID, OrderReady)
+		if !errors.Is(err, ErrIllegalTransition) || KindOf(err) != KindConflict {
+			t.Errorf("Transition() error = %v, want a conflict wrapping ErrIllegalTransition", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrOriginInUse is returned when deleting an origin that coffees still
+// reference.
+var ErrOriginInUse = errors.New("the origin is still referenced by coffees")
+
+// ProcessingMethods lists the accepted values of Origin.Process.
+var ProcessingMethods = []string{"washed", "natural", "honey", "wet-hulled", "anaerobic"}
+
+// maxAltitude bounds Origin altitudes, in metres above sea level.
+const maxAltitude = 6000
+
+// OriginRepository is the storage contract for coffee origins. Get, Update
+// and Delete report a missing origin as sql.ErrNoRows.
+type OriginRepository interface {
+	List(ctx context.Context) ([]*Origin, error)
+	Get(ctx context.Context, id string) (*Origin, error)
+	Find(ctx context.Context, ids []string) (map[string]*Origin, error)
+	Create(ctx context.Context, origin Origin) (*Origin, error)
+	Update(ctx context.Context, origin Origin) (*Origin, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Origin is where a coffee is grown, with the details used by the origin
+// stories on the marketing pages. Altitudes are in metres above sea level.
+type Origin struct {
+	ID            string    `json:"id"`
+	Country       string    `json:"country"`
+	Region        string    `json:"region"`
+	Farm          string    `json:"farm"`
+	AltitudeMin   *int      `json:"altitude_min"`
+	AltitudeMax   *int      `json:"altitude_max"`
+	Process       string    `json:"process"`
+	HarvestSeason string    `json:"harvest_season"`
+	Story         string    `json:"story"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Validate checks the client supplied fields of an origin and returns the
+// problems found, or nil when the origin is valid.
+func (o *Origin) Validate() ValidationErrors {
+	v := ValidationErrors{}
+
+	v.check(strings.TrimSpace(o.Country) != "", "country", "must be provided")
+	v.check(utf8.RuneCountInString(o.Country) <= maxNameLength, "country", fmt.Sprintf("must not be more than %d characters long", maxNameLength))
+	v.check(utf8.RuneCountInString(o.Region) <= maxNameLength, "region", fmt.Sprintf("must not be more than %d characters long", maxNameLength))
+	v.check(utf8.RuneCountInString(o.Farm) <= maxNameLength, "farm", fmt.Sprintf("must not be more than %d characters long", maxNameLength))
+
+	for field, altitude := range map[string]*int{"altitude_min": o.AltitudeMin, "altitude_max": o.AltitudeMax} {
+		v.check(altitude == nil || (*altitude >= 0 && *altitude <= maxAltitude), field, fmt.Sprintf("must be between 0 and %d metres", maxAltitude))
+	}
+	if o.AltitudeMin != nil && o.AltitudeMax != nil {
+		v.check(*o.AltitudeMin <= *o.AltitudeMax, "altitude_max", "must not be lower than altitude_min")
+	}
+
+	v.check(o.Process == "" || slices.Contains(ProcessingMethods, o.Process), "process", "must be one of "+strings.Join(ProcessingMethods, ", "))
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// ExpandOrigins sets Origin on every coffee that references one, loading all
+// origins of the page with a single lookup.
+func ExpandOrigins(ctx context.Context, origins OriginRepository, coffees []*Coffee) error {
+	var ids []string
+	for _, coffee := range coffees {
+		if coffee.OriginID != "" {
+			ids = append(ids, coffee.OriginID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	found, err := origins.Find(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, coffee := range coffees {
+		coffee.Origin = found[coffee.OriginID]
+	}
+
+	return nil
+}
+
+// PostgresOriginRepository is an OriginRepository backed by the origins table.
+type PostgresOriginRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresOriginRepository creates a repository using the given connection pool.
+func NewPostgresOriginRepository(db *sql.DB) *PostgresOriginRepository {
+	return &PostgresOriginRepository{db: db}
+}
+
+const originColumns = `id, country, region, farm, altitude_min, altitude_max, process, harvest_season, story, created_at, updated_at`
+
+// scanOrigin reads a row selected with originColumns.
+func scanOrigin(row rowScanner) (*Origin, error) {
+	var origin Origin
+	if err := row.Scan(
+		&origin.ID,
+		&origin.Country,
+		&origin.Region,
+		&origin.Farm,
+		&origin.AltitudeMin,
+		&origin.AltitudeMax,
+		&origin.Process,
+		&origin.HarvestSeason,
+		&origin.Story,
+		&origin.CreatedAt,
+		&origin.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &origin, nil
+}
+
+// List returns every origin ordered by country, region and farm.
+func (r *PostgresOriginRepository) List(ctx context.Context) ([]*Origin, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+originColumns+` FROM origins ORDER BY country, region, farm, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	origins := []*Origin{}
+	for rows.Next() {
+		origin, err := scanOrigin(rows)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, origin)
+	}
+
+	return origins, rows.Err()
+}
+
+// Get retrieves an origin by its ID.
+func (r *PostgresOriginRepository) Get(ctx context.Context, id string) (*Origin, error) {
+	if !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	return scanOrigin(r.db.QueryRowContext(ctx, `SELECT `+originColumns+` FROM origins WHERE id = $1`, id))
+}
+
+// Find retrieves the origins with the given IDs, keyed by ID. Unknown IDs are
+// absent from the map.
+func (r *PostgresOriginRepository) Find(ctx context.Context, ids []string) (map[string]*Origin, error) {
+	origins := make(map[string]*Origin)
+
+	var args queryArgs
+	placeholders := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if isUUID(id) {
+			placeholders = append(placeholders, args.add(id)+"::uuid")
+		}
+	}
+	if len(placeholders) == 0 {
+		return origins, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + originColumns + ` FROM origins WHERE id IN (` + strings.Join(placeholders, ", ") + `)`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		origin, err := scanOrigin(rows)
+		if err != nil {
+			return nil, err
+		}
+		origins[origin.ID] = origin
+	}
+
+	return origins, rows.Err()
+}
+
+// Create inserts a new origin.
+func (r *PostgresOriginRepository) Create(ctx context.Context, origin Origin) (*Origin, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO origins(country, region, farm, altitude_min, altitude_max, process, harvest_season, story, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+        RETURNING id, created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		origin.Country,
+		origin.Region,
+		origin.Farm,
+		origin.AltitudeMin,
+		origin.AltitudeMax,
+		origin.Process,
+		origin.HarvestSeason,
+		origin.Story,
+		time.Now(),
+	).Scan(&origin.ID, &origin.CreatedAt, &origin.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &origin, nil
+}
+
+// Update overwrites the editable fields of an existing origin.
+func (r *PostgresOriginRepository) Update(ctx context.Context, origin Origin) (*Origin, error) {
+	if !isUUID(origin.ID) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE origins
+        SET country = $1, region = $2, farm = $3, altitude_min = $4, altitude_max = $5,
+            process = $6, harvest_season = $7, story = $8, updated_at = $9
+        WHERE id = $10
+        RETURNING created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		origin.Country,
+		origin.Region,
+		origin.Farm,
+		origin.AltitudeMin,
+		origin.AltitudeMax,
+		origin.Process,
+		origin.HarvestSeason,
+		origin.Story,
+		time.Now(),
+		origin.ID,
+	).Scan(&origin.CreatedAt, &origin.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &origin, nil
+}
+
+// Delete removes an origin. Origins still referenced by coffees are kept and
+// ErrOriginInUse is returned.
+func (r *PostgresOriginRepository) Delete(ctx context.Context, id string) error {
+	if !isUUID(id) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM origins WHERE id = $1`, id)
+	if hasSQLState(err, pgForeignKeyViolation) {
+		return NewError(KindConflict, ErrOriginInUse)
+	}
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
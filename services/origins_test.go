@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOriginValidate(t *testing.T) {
+	t.Parallel()
+
+	altitude := func(m int) *int { return &m }
+
+	t.Run("Valid Origin", func(t *testing.T) {
+		// Test that a fully described origin passes.
+		origin := Origin{Country: "Ethiopia", Region: "Yirgacheffe", AltitudeMin: altitude(1700), AltitudeMax: altitude(2200), Process: "washed"}
+
+		if errs := origin.Validate(); errs != nil {
+			t.Errorf("Validate() = %v, want nil", errs)
+		}
+	})
+
+	t.Run("Invalid Fields", func(t *testing.T) {
+		// Test that each broken field is reported under its own key.
+		origin := Origin{AltitudeMin: altitude(2200), AltitudeMax: altitude(1700), Process: "dried"}
+
+		errs := origin.Validate()
+		for _, field := range []string{"country", "altitude_max", "process"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("Validate() = %v, want an error for %s", errs, field)
+			}
+		}
+	})
+
+	t.Run("Altitude Out Of Range", func(t *testing.T) {
+		// Test that altitudes are bounded even when only one is given.
+		origin := Origin{Country: "Peru", AltitudeMax: altitude(maxAltitude + 1)}
+
+		if _, ok := origin.Validate()["altitude_max"]; !ok {
+			t.Error("Validate() accepted an altitude above maxAltitude")
+		}
+	})
+}
+
+func TestExpandOrigins(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	origins := NewMemoryOriginRepository(nil)
+	origin, err := origins.Create(ctx, Origin{Country: "Kenya", Region: "Nyeri"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linked := &Coffee{ID: "a", OriginID: origin.ID}
+	unlinked := &Coffee{ID: "b"}
+
+	if err := ExpandOrigins(ctx, origins, []*Coffee{linked, unlinked}); err != nil {
+		t.Fatalf("ExpandOrigins() error = %v", err)
+	}
+
+	if linked.Origin == nil || linked.Origin.Region != "Nyeri" {
+		t.Errorf("linked coffee Origin = %+v, want Nyeri", linked.Origin)
+	}
+	if unlinked.Origin != nil {
+		t.Errorf("unlinked coffee Origin = %+v, want nil", unlinked.Origin)
+	}
+}
+
+func TestMemoryOriginDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	coffees := NewMemoryCoffeeRepository()
+	origins := NewMemoryOriginRepository(coffees)
+
+	origin, err := origins.Create(ctx, Origin{Country: "Colombia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coffee, err := coffees.Create(ctx, Coffee{Name: "Huila", Roast: "medium", Price: Money{1200, "EUR"}, OriginID: origin.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Referenced Origin", func(t *testing.T) {
+		// Test that an origin in use is kept and reported as a conflict.
+		err := origins.Delete(ctx, origin.ID)
+		if !errors.Is(err, ErrOriginInUse) || KindOf(err) != KindConflict {
+			t.Fatalf("Delete() error = %v, want a conflict wrapping ErrOriginInUse", err)
+		}
+	})
+
+	t.Run("Deleted Coffee", func(t *testing.T) {
+		// Test that a deleted coffee keeps its origin, as it can still be restored.
+		if err := coffees.Delete(ctx, coffee.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := origins.Delete(ctx, origin.ID); !errors.Is(err, ErrOriginInUse) {
+			t.Fatalf("Delete() error = %v, want ErrOriginInUse", err)
+		}
+	})
+
+	t.Run("Unreferenced Origin", func(t *testing.T) {
+		// Test that the origin can go once no coffee points at it.
+		if _, err := coffees.Purge(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := origins.Delete(ctx, origin.ID); err != nil {
+			t.Fatalf("Delete() error = %v, want nil", err)
+		}
+		if _, err := origins.Get(ctx, origin.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultPageSize is used when a listing does not ask for a limit.
+	DefaultPageSize = 20
+	// MaxPageSize caps the number of coffees returned by one listing call.
+	MaxPageSize = 100
+)
+
+// ErrInvalidCursor is returned for cursors that were not produced by a
+// previous listing, or that are combined with an offset or a custom sort.
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// ListOptions filters, sorts and selects one page of a coffee listing. Pages
+// are addressed either by Offset or by an opaque keyset Cursor taken from a
+// previous CoffeePage; cursors are only issued for the default sort order.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Cursor string
+
+	// Roasts and Regions match any of the listed values, ignoring case.
+	Roasts  []string
+	Regions []string
+	// PriceMin and PriceMax bound the selling price inclusively when set, in
+	// minor units of PriceCurrency, DefaultCurrency when empty. Coffees
+	// selling in another currency never match a price bound.
+	PriceMin      *int64
+	PriceMax      *int64
+	PriceCurrency string
+	// PricedAt is the moment whose price schedules set the selling price
+	// that price bounds and sorting by price use, now when zero.
+	PricedAt time.Time
+	// OriginID restricts the listing to coffees of one origin when set.
+	OriginID string
+	// InStock hides coffees whose stock levels have nothing available.
+	// Coffees without stock levels are untracked and always listed.
+	InStock bool
+	// IncludeDeleted lists deleted coffees next to the others.
+	IncludeDeleted bool
+
+	Sort []SortField
+}
+
+// priceCurrency returns the currency of the price bounds of the listing.
+func (o ListOptions) priceCurrency() string {
+	if o.PriceCurrency == "" {
+		return DefaultCurrency
+	}
+
+	return o.PriceCurrency
+}
+
+// pricedAt returns the moment whose price schedules the listing uses.
+func (o ListOptions) pricedAt() time.Time {
+	if o.PricedAt.IsZero() {
+		return time.Now()
+	}
+
+	return o.PricedAt
+}
+
+// byPrice reports whether the listing filters or sorts on the selling price,
+// which needs the price schedules in effect at pricedAt.
+func (o ListOptions) byPrice() bool {
+	if o.PriceMin != nil || o.PriceMax != nil {
+		return true
+	}
+	for _, field := range o.Sort {
+		if field.Field == "price" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CoffeePage is one page of a coffee listing. Total counts every coffee
+// matching the listing, and NextCursor is empty on the last page.
+type CoffeePage struct {
+	Coffees    []*Coffee
+	Total      int
+	NextCursor string
+}
+
+// pageSize returns the effective limit of the listing.
+func (o ListOptions) pageSize() int {
+	if o.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if o.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+
+	return o.Limit
+}
+
+// keyset is the position of a coffee in the (created_at, id) listing order.
+type keyset struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+// after decodes the cursor of the listing, returning nil when there is none.
+func (o ListOptions) after() (*keyset, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+	if o.Offset != 0 || len(o.Sort) > 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var position keyset
+	if err := json.Unmarshal(raw, &position); err != nil || position.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &position, nil
+}
+
+// encodeCursor returns the opaque cursor pointing just after coffee.
+func encodeCursor(coffee *Coffee) string {
+	raw, _ := json.Marshal(keyset{CreatedAt: coffee.CreatedAt, ID: coffee.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// newPage trims a result fetched with one extra row down to the page size
+// and, for the default sort order, derives the cursor of the following page.
+func (o ListOptions) newPage(coffees []*Coffee, total int) *CoffeePage {
+	limit := o.pageSize()
+
+	page := &CoffeePage{Coffees: coffees, Total: total}
+	if len(coffees) > limit {
+		page.Coffees = coffees[:limit]
+		if len(o.Sort) == 0 {
+			page.NextCursor = encodeCursor(page.Coffees[limit-1])
+		}
+	}
+
+	return page
+}
+
+// page filters, sorts and pages coffees held in memory like the PostgreSQL
+// listing does, except for InStock which is left to the caller.
+func (o ListOptions) page(coffees []*Coffee) (*CoffeePage, error) {
+	after, err := o.after()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]*Coffee, 0, len(coffees))
+	for _, coffee := range coffees {
+		if o.matches(coffee) {
+			matching = append(matching, coffee)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return o.less(matching[i], matching[j])
+	})
+
+	total := len(matching)
+
+	if after != nil {
+		start := sort.Search(len(matching), func(i int) bool {
+			return keysetLess(after.CreatedAt, after.ID, matching[i].CreatedAt, matching[i].ID)
+		})
+		matching = matching[start:]
+	}
+
+	start := min(o.Offset, len(matching))
+	end := min(start+o.pageSize()+1, len(matching))
+
+	return o.newPage(matching[start:end], total), nil
+}
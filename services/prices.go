@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PriceRepository stores the explicit per-currency price lists of coffees.
+// Delete reports a missing price as sql.ErrNoRows.
+type PriceRepository interface {
+	List(ctx context.Context, coffeeID string) ([]Money, error)
+	Find(ctx context.Context, coffeeIDs []string, currency string) (map[string]Money, error)
+	Set(ctx context.Context, coffeeID string, price Money) error
+	Delete(ctx context.Context, coffeeID, currency string) error
+}
+
+// RateRepository stores the exchange rates maintained by staff. Get and
+// Delete report a missing rate as sql.ErrNoRows.
+type RateRepository interface {
+	List(ctx context.Context) ([]ExchangeRate, error)
+	Get(ctx context.Context, base, quote string) (*ExchangeRate, error)
+	Save(ctx context.Context, rates []ExchangeRate) error
+	Delete(ctx context.Context, base, quote string) error
+}
+
+// PriceQuote is the price of a coffee in a requested currency. Rate is nil
+// when Price comes from the coffee's price list; otherwise Price is the base
+// price converted with Rate.
+type PriceQuote struct {
+	Price Money
+	Rate  *ExchangeRate
+}
+
+// MarshalJSON encodes the quote with the price as a JSON number and, for
+// converted prices, the rate that was applied.
+func (q PriceQuote) MarshalJSON() ([]byte, error) {
+	source := "list"
+	if q.Rate != nil {
+		source = "converted"
+	}
+
+	return json.Marshal(struct {
+		Price    json.Number   `json:"price"`
+		Currency string        `json:"currency"`
+		Source   string        `json:"source"`
+		Rate     *ExchangeRate `json:"rate,omitempty"`
+	}{json.Number(q.Price.String()), q.Price.Currency, source, q.Rate})
+}
+
+// Pricing resolves coffee prices in other currencies from the price lists,
+// falling back to converting the base price with a configured exchange rate.
+type Pricing struct {
+	Prices PriceRepository
+	Rates  RateRepository
+}
+
+// Quote sets LocalPrice on every coffee to its price in currency. It fails
+// with ErrNoExchangeRate when a coffee has neither a list price nor a rate
+// from its base currency. List prices are ignored while a price schedule is
+// in effect, so the scheduled price is converted instead. List prices and
+// rates are always the current ones, as neither has a history.
+func (p Pricing) Quote(ctx context.Context, coffees []*Coffee, currency string) error {
+	if len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(coffees))
+	for i, coffee := range coffees {
+		ids[i] = coffee.ID
+	}
+
+	listed, err := p.Prices.Find(ctx, ids, currency)
+	if err != nil {
+		return err
+	}
+
+	rates := make(map[string]*ExchangeRate)
+	for _, coffee := range coffees {
+		if price, ok := listed[coffee.ID]; ok && coffee.Schedule == nil {
+			coffee.LocalPrice = &PriceQuote{Price: price}
+			continue
+		}
+
+		selling := coffee.SellingPrice()
+		if selling.Currency == currency {
+			coffee.LocalPrice = &PriceQuote{Price: selling}
+			continue
+		}
+
+		rate, ok := rates[selling.Currency]
+		if !ok {
+			rate, err = p.rate(ctx, selling.Currency, currency)
+			if err != nil {
+				return err
+			}
+			rates[selling.Currency] = rate
+		}
+
+		price, err := rate.Convert(selling)
+		if err != nil {
+			return err
+		}
+		coffee.LocalPrice = &PriceQuote{Price: price, Rate: rate}
+	}
+
+	return nil
+}
+
+// rate finds the rate from base to quote, inverting the opposite rate when
+// only that one is configured.
+func (p Pricing) rate(ctx context.Context, base, quote string) (*ExchangeRate, error) {
+	rate, err := p.Rates.Get(ctx, base, quote)
+	if err == nil {
+		return rate, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	rate, err = p.Rates.Get(ctx, quote, base)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, base, quote)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inverse := rate.Inverse()
+	return &inverse, nil
+}
+
+// PostgresPriceRepository is a PriceRepository backed by the coffee_prices table.
+type PostgresPriceRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresPriceRepository creates a repository using the given connection pool.
+func NewPostgresPriceRepository(db *sql.DB) *PostgresPriceRepository {
+	return &PostgresPriceRepository{db: db}
+}
+
+// List returns the price list of a coffee ordered by currency.
+func (r *PostgresPriceRepository) List(ctx context.Context, coffeeID string) ([]Money, error) {
+	prices := []Money{}
+	if !isUUID(coffeeID) {
+		return prices, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT price_minor, currency FROM coffee_prices WHERE coffee_id = $1 ORDER BY currency`
+	rows, err := r.db.QueryContext(ctx, query, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var price Money
+		if err := rows.Scan(&price.Amount, &price.Currency); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// Find returns the list prices in currency of the given coffees, keyed by
+// coffee ID. Coffees without a price in currency are absent from the map.
+func (r *PostgresPriceRepository) Find(ctx context.Context, coffeeIDs []string, currency string) (map[string]Money, error) {
+	prices := make(map[string]Money)
+
+	var args queryArgs
+	placeholders := make([]string, 0, len(coffeeIDs))
+	for _, id := range coffeeIDs {
+		if isUUID(id) {
+			placeholders = append(placeholders, args.add(id)+"::uuid")
+		}
+	}
+	if len(placeholders) == 0 {
+		return prices, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+	SELECT coffee_id, price_minor
+	FROM coffee_prices
+	WHERE currency = %s AND coffee_id IN (%s)
+	`, args.add(currency), strings.Join(placeholders, ", "))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		price := Money{Currency: currency}
+		if err := rows.Scan(&id, &price.Amount); err != nil {
+			return nil, err
+		}
+		prices[id] = price
+	}
+
+	return prices, rows.Err()
+}
+
+// Set creates or replaces the price of a coffee in the currency of price.
+func (r *PostgresPriceRepository) Set(ctx context.Context, coffeeID string, price Money) error {
+	if !isUUID(coffeeID) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO coffee_prices(coffee_id, currency, price_minor, updated_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (coffee_id, currency) DO UPDATE
+        SET price_minor = EXCLUDED.price_minor, updated_at = EXCLUDED.updated_at
+    `
+
+	_, err := r.db.ExecContext(ctx, query, coffeeID, price.Currency, price.Amount, time.Now())
+
+	return err
+}
+
+// Delete removes the price of a coffee in currency.
+func (r *PostgresPriceRepository) Delete(ctx context.Context, coffeeID, currency string) error {
+	if !isUUID(coffeeID) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM coffee_prices WHERE coffee_id = $1 AND currency = $2`, coffeeID, currency)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PostgresRateRepository is a RateRepository backed by the exchange_rates table.
+type PostgresRateRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRateRepository creates a repository using the given connection pool.
+func NewPostgresRateRepository(db *sql.DB) *PostgresRateRepository {
+	return &PostgresRateRepository{db: db}
+}
+
+// List returns every configured rate ordered by currency pair.
+func (r *PostgresRateRepository) List(ctx context.Context) ([]ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT base, quote, rate::text, updated_at FROM exchange_rates ORDER BY base, quote`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []ExchangeRate{}
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// Get returns the rate converting base into quote.
+func (r *PostgresRateRepository) Get(ctx context.Context, base, quote string) (*ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, `SELECT base, quote, rate::text, updated_at FROM exchange_rates WHERE base = $1 AND quote = $2`, base, quote)
+
+	return scanRate(row)
+}
+
+// Save creates or replaces all rates in a single transaction, so a rate file
+// is either loaded completely or not at all.
+func (r *PostgresRateRepository) Save(ctx context.Context, rates []ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO exchange_rates(base, quote, rate, updated_at)
+        VALUES ($1, $2, $3::numeric, $4)
+        ON CONFLICT (base, quote) DO UPDATE
+        SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at
+    `
+
+	now := time.Now()
+	for _, rate := range rates {
+		if _, err := tx.ExecContext(ctx, query, rate.Base, rate.Quote, rate.Rate.FloatString(rateDecimals), now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the rate converting base into quote.
+func (r *PostgresRateRepository) Delete(ctx context.Context, base, quote string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM exchange_rates WHERE base = $1 AND quote = $2`, base, quote)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRate reads a base, quote, rate::text, updated_at row.
+func scanRate(row rowScanner) (*ExchangeRate, error) {
+	var rate ExchangeRate
+	var value string
+	if err := row.Scan(&rate.Base, &rate.Quote, &value, &rate.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	parsed, err := ParseRate(value)
+	if err != nil {
+		return nil, err
+	}
+	rate.Rate = parsed
+
+	return &rate, nil
+}
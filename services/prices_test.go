@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPricingQuote(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newPricing := func(t *testing.T) Pricing {
+		pricing := Pricing{Prices: NewMemoryPriceRepository(), Rates: NewMemoryRateRepository()}
+		if err := pricing.Rates.Save(ctx, []ExchangeRate{mustRate(t, "EUR", "GBP", "0.86"), mustRate(t, "RON", "EUR", "0.2")}); err != nil {
+			t.Fatal(err)
+		}
+		return pricing
+	}
+
+	t.Run("List Price First", func(t *testing.T) {
+		// Test that an explicit price wins over converting the base price.
+		pricing := newPricing(t)
+		coffee := &Coffee{ID: "a", Price: Money{1000, "EUR"}}
+		pricing.Prices.Set(ctx, "a", Money{799, "GBP"})
+
+		if err := pricing.Quote(ctx, []*Coffee{coffee}, "GBP"); err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+
+		if coffee.LocalPrice.Price != (Money{799, "GBP"}) || coffee.LocalPrice.Rate != nil {
+			t.Errorf("Quote() = %+v, want the 7.99 GBP list price", coffee.LocalPrice)
+		}
+	})
+
+	t.Run("Scheduled Price", func(t *testing.T) {
+		// Test that a price schedule in effect overrides the list price.
+		pricing := newPricing(t)
+		coffee := &Coffee{ID: "a", Price: Money{1000, "EUR"}, Schedule: &ScheduledPrice{BasePrice: Money{1000, "EUR"}}, EffectivePrice: &Money{500, "EUR"}}
+		pricing.Prices.Set(ctx, "a", Money{799, "GBP"})
+
+		if err := pricing.Quote(ctx, []*Coffee{coffee}, "GBP"); err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+
+		if coffee.LocalPrice.Price != (Money{430, "GBP"}) {
+			t.Errorf("Quote() = %+v, want the 4.30 GBP scheduled price", coffee.LocalPrice)
+		}
+	})
+
+	t.Run("Converted Price", func(t *testing.T) {
+		// Test conversion with the direct rate, reporting the rate used.
+		pricing := newPricing(t)
+		coffee := &Coffee{ID: "a", Price: Money{1000, "EUR"}}
+
+		if err := pricing.Quote(ctx, []*Coffee{coffee}, "GBP"); err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+
+		if coffee.LocalPrice.Price != (Money{860, "GBP"}) || coffee.LocalPrice.Rate == nil || coffee.LocalPrice.Rate.Quote != "GBP" {
+			t.Errorf("Quote() = %+v, want 8.60 GBP converted", coffee.LocalPrice)
+		}
+	})
+
+	t.Run("Inverse Rate", func(t *testing.T) {
+		// Test that only the opposite rate being configured is enough.
+		pricing := newPricing(t)
+		coffee := &Coffee{ID: "a", Price: Money{1000, "EUR"}}
+
+		if err := pricing.Quote(ctx, []*Coffee{coffee}, "RON"); err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+
+		if coffee.LocalPrice.Price != (Money{5000, "RON"}) {
+			t.Errorf("Quote() = %+v, want 50.00 RON", coffee.LocalPrice)
+		}
+	})
+
+	t.Run("Missing Rate", func(t *testing.T) {
+		// Test that a currency without list price or rate is reported.
+		pricing := newPricing(t)
+		coffee := &Coffee{ID: "a", Price: Money{1000, "EUR"}}
+
+		if err := pricing.Quote(ctx, []*Coffee{coffee}, "USD"); !errors.Is(err, ErrNoExchangeRate) {
+			t.Errorf("Quote() error = %v, want ErrNoExchangeRate", err)
+		}
+	})
+}
+
+func TestFindPrices(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Batched Lookup", func(t *testing.T) {
+		// Test that one query fetches the prices of a whole page.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE currency = $3 AND coffee_id IN ($1::uuid, $2::uuid)")).
+			WithArgs(testCoffeeID, missingCoffeeID, "GBP").
+			WillReturnRows(sqlmock.NewRows([]string{"coffee_id", "price_minor"}).AddRow(testCoffeeID, 799))
+
+		prices, err := NewPostgresPriceRepository(db).Find(context.Background(), []string{testCoffeeID, missingCoffeeID, "not-a-uuid"}, "GBP")
+		if err != nil {
+			t.Fatalf("Find() error = %v", err)
+		}
+
+		if len(prices) != 1 || prices[testCoffeeID] != (Money{799, "GBP"}) {
+			t.Errorf("Find() = %v, want only the listed coffee", prices)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// CoffeePurger periodically removes coffees that have been deleted for
+// longer than Retention, which is how long a deleted coffee can be restored.
+type CoffeePurger struct {
+	Coffees   CoffeeRepository
+	Retention time.Duration
+	Interval  time.Duration
+	Logger    *log.Logger
+}
+
+// Run purges every Interval until ctx is done.
+func (p *CoffeePurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+			p.Logger.Printf("CoffeePurger: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge runs one pass and returns the number of coffees removed.
+func (p *CoffeePurger) Purge(ctx context.Context) (int64, error) {
+	return p.Coffees.Purge(ctx, time.Now().Add(-p.Retention))
+}
@@ -0,0 +1,481 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrScheduleOverlap is returned when a price schedule would be in
+	// effect at the same time as another schedule of the coffee.
+	ErrScheduleOverlap = errors.New("the price schedule overlaps another schedule of the coffee")
+	// ErrScheduleEnded is returned when cancelling a schedule that is over.
+	ErrScheduleEnded = errors.New("the price schedule has already ended")
+)
+
+// ScheduleRepository stores the price schedules of coffees. Cancel reports a
+// missing schedule as sql.ErrNoRows, and Create reports a missing coffee the
+// same way.
+type ScheduleRepository interface {
+	List(ctx context.Context, coffeeID string) ([]*PriceSchedule, error)
+	Active(ctx context.Context, coffeeIDs []string, at time.Time) (map[string]*PriceSchedule, error)
+	Create(ctx context.Context, schedule PriceSchedule) (*PriceSchedule, error)
+	Cancel(ctx context.Context, coffeeID, id string, at time.Time) error
+	Transitions(ctx context.Context, at time.Time) ([]ScheduleTransition, error)
+}
+
+// PriceSchedule replaces the price of a coffee from EffectiveFrom until
+// EffectiveTo, or for good when EffectiveTo is nil. The schedules of a
+// coffee never overlap. StartedAt and EndedAt are set once the applier has
+// seen the schedule come into and go out of effect.
+type PriceSchedule struct {
+	ID            string     `json:"id"`
+	CoffeeID      string     `json:"coffee_id"`
+	Price         Money      `json:"price"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	StartedAt     *time.Time `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// scheduleFields has the fields of PriceSchedule without its JSON methods.
+type scheduleFields PriceSchedule
+
+// MarshalJSON encodes the price like Coffee does, as a JSON number in major
+// units next to its currency.
+func (s PriceSchedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		scheduleFields
+		Price    json.Number `json:"price"`
+		Currency string      `json:"currency"`
+	}{scheduleFields(s), json.Number(s.Price.String()), s.Price.Currency})
+}
+
+// UnmarshalJSON decodes a numeric price in major units. The currency member is
+// optional and defaults to the current currency, or DefaultCurrency, so
+// callers can preset the currency of the coffee.
+func (s *PriceSchedule) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*scheduleFields
+		Price    json.Number `json:"price"`
+		Currency string      `json:"currency"`
+	}{scheduleFields: (*scheduleFields)(s)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(aux.Currency))
+	if currency == "" {
+		currency = s.Price.Currency
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	s.Price = Money{Currency: currency}
+	if aux.Price == "" {
+		return nil
+	}
+
+	price, err := ParseMoney(aux.Price.String(), currency)
+	if err != nil {
+		return ValidationErrors{"price": fmt.Sprintf("must be a decimal amount with at most %d decimal places for %s", CurrencyExponent(currency), currency)}
+	}
+	s.Price = price
+
+	return nil
+}
+
+// Validate checks the client supplied fields of a schedule created at now and
+// returns the problems found, or nil when it is valid. A schedule without
+// EffectiveFrom starts at now; schedules cannot start in the past, since
+// that would rewrite the prices customers have already seen.
+func (s *PriceSchedule) Validate(now time.Time) ValidationErrors {
+	errs := ValidationErrors{}
+
+	if s.EffectiveFrom.IsZero() {
+		s.EffectiveFrom = now
+	}
+
+	errs.check(s.Price.Amount > 0, "price", "must be greater than zero")
+	errs.check(IsCurrencyCode(s.Price.Currency), "currency", "must be a three-letter ISO 4217 code")
+	errs.check(!s.EffectiveFrom.Before(now), "effective_from", "must not be in the past")
+	errs.check(s.EffectiveTo == nil || s.EffectiveTo.After(s.EffectiveFrom), "effective_to", "must be after effective_from")
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// ActiveAt reports whether the schedule is in effect at t.
+func (s *PriceSchedule) ActiveAt(t time.Time) bool {
+	return !t.Before(s.EffectiveFrom) && (s.EffectiveTo == nil || t.Before(*s.EffectiveTo))
+}
+
+// overlaps reports whether two schedules are in effect at the same time.
+func (s *PriceSchedule) overlaps(other *PriceSchedule) bool {
+	startsBeforeOtherEnds := other.EffectiveTo == nil || s.EffectiveFrom.Before(*other.EffectiveTo)
+	endsAfterOtherStarts := s.EffectiveTo == nil || s.EffectiveTo.After(other.EffectiveFrom)
+
+	return startsBeforeOtherEnds && endsAfterOtherStarts
+}
+
+// ScheduledPrice is the schedule in effect for a coffee, with the base price
+// it replaces.
+type ScheduledPrice struct {
+	ScheduleID    string
+	BasePrice     Money
+	EffectiveFrom time.Time
+	EffectiveTo   *time.Time
+}
+
+// MarshalJSON encodes the base price as a JSON number in major units next to
+// its currency.
+func (p ScheduledPrice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ScheduleID    string      `json:"schedule_id"`
+		BasePrice     json.Number `json:"base_price"`
+		BaseCurrency  string      `json:"base_currency"`
+		EffectiveFrom time.Time   `json:"effective_from"`
+		EffectiveTo   *time.Time  `json:"effective_to"`
+	}{p.ScheduleID, json.Number(p.BasePrice.String()), p.BasePrice.Currency, p.EffectiveFrom, p.EffectiveTo})
+}
+
+// ApplySchedules sets Schedule and EffectivePrice on every coffee with a
+// schedule in effect at t. Price is left as the base price, so a coffee read
+// this way can be written back without storing the scheduled price. The
+// schedules of the whole page are loaded with a single lookup.
+func ApplySchedules(ctx context.Context, schedules ScheduleRepository, coffees []*Coffee, at time.Time) error {
+	if len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(coffees))
+	for i, coffee := range coffees {
+		ids[i] = coffee.ID
+	}
+
+	active, err := schedules.Active(ctx, ids, at)
+	if err != nil {
+		return err
+	}
+
+	for _, coffee := range coffees {
+		schedule, ok := active[coffee.ID]
+		if !ok {
+			continue
+		}
+
+		coffee.Schedule = &ScheduledPrice{
+			ScheduleID:    schedule.ID,
+			BasePrice:     coffee.Price,
+			EffectiveFrom: schedule.EffectiveFrom,
+			EffectiveTo:   schedule.EffectiveTo,
+		}
+		price := schedule.Price
+		coffee.EffectivePrice = &price
+	}
+
+	return nil
+}
+
+// ScheduleTransition is a schedule coming into effect, or going out of
+// effect when Started is false.
+type ScheduleTransition struct {
+	Schedule *PriceSchedule
+	Started  bool
+}
+
+// String describes the transition for the log.
+func (t ScheduleTransition) String() string {
+	if t.Started {
+		return fmt.Sprintf("price schedule %s started: coffee %s sells at %s %s", t.Schedule.ID, t.Schedule.CoffeeID, t.Schedule.Price, t.Schedule.Price.Currency)
+	}
+
+	return fmt.Sprintf("price schedule %s ended: coffee %s is back to its base price", t.Schedule.ID, t.Schedule.CoffeeID)
+}
+
+// ScheduleApplier periodically picks up the price schedules that came into
+// or went out of effect and logs each transition once. Prices themselves are
+// resolved when coffees are read, so a late pass never serves a stale price.
+type ScheduleApplier struct {
+	Schedules ScheduleRepository
+	Interval  time.Duration
+	InfoLog   *log.Logger
+	ErrorLog  *log.Logger
+}
+
+// Run applies schedules every Interval until ctx is done.
+func (a *ScheduleApplier) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.Apply(ctx); err != nil && ctx.Err() == nil {
+			a.ErrorLog.Printf("ScheduleApplier: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Apply runs one pass and returns the transitions it logged.
+func (a *ScheduleApplier) Apply(ctx context.Context) ([]ScheduleTransition, error) {
+	transitions, err := a.Schedules.Transitions(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transition := range transitions {
+		a.InfoLog.Println(transition)
+	}
+
+	return transitions, nil
+}
+
+// PostgresScheduleRepository is a ScheduleRepository backed by the
+// price_schedules table.
+type PostgresScheduleRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresScheduleRepository creates a repository using the given connection pool.
+func NewPostgresScheduleRepository(db *sql.DB) *PostgresScheduleRepository {
+	return &PostgresScheduleRepository{db: db}
+}
+
+const scheduleColumns = `id, coffee_id, price_minor, currency, effective_from, effective_to, started_at, ended_at, created_at`
+
+// scanSchedule reads a row selected with scheduleColumns.
+func scanSchedule(row rowScanner) (*PriceSchedule, error) {
+	var s PriceSchedule
+	if err := row.Scan(&s.ID, &s.CoffeeID, &s.Price.Amount, &s.Price.Currency, &s.EffectiveFrom, &s.EffectiveTo, &s.StartedAt, &s.EndedAt, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// querySchedules runs a query selecting scheduleColumns.
+func querySchedules(ctx context.Context, q queryer, query string, args ...interface{}) ([]*PriceSchedule, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*PriceSchedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// List returns the schedules of a coffee in the order they take effect.
+func (r *PostgresScheduleRepository) List(ctx context.Context, coffeeID string) ([]*PriceSchedule, error) {
+	if !isUUID(coffeeID) {
+		return []*PriceSchedule{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	return querySchedules(ctx, r.db, `SELECT `+scheduleColumns+` FROM price_schedules WHERE coffee_id = $1 ORDER BY effective_from`, coffeeID)
+}
+
+// Active returns the schedules in effect at the given time, keyed by coffee
+// ID. Coffees without one are absent from the map.
+func (r *PostgresScheduleRepository) Active(ctx context.Context, coffeeIDs []string, at time.Time) (map[string]*PriceSchedule, error) {
+	active := make(map[string]*PriceSchedule)
+
+	args := queryArgs{at}
+	placeholders := make([]string, 0, len(coffeeIDs))
+	for _, id := range coffeeIDs {
+		if isUUID(id) {
+			placeholders = append(placeholders, args.add(id)+"::uuid")
+		}
+	}
+	if len(placeholders) == 0 {
+		return active, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+	SELECT ` + scheduleColumns + `
+	FROM price_schedules
+	WHERE coffee_id IN (` + strings.Join(placeholders, ", ") + `)
+	AND effective_from <= $1 AND (effective_to IS NULL OR effective_to > $1)
+	`
+
+	schedules, err := querySchedules(ctx, r.db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schedule := range schedules {
+		active[schedule.CoffeeID] = schedule
+	}
+
+	return active, nil
+}
+
+// Create stores a new schedule, failing with ErrScheduleOverlap when another
+// schedule of the coffee is in effect at any moment of it. The coffee row is
+// locked so that concurrent creations cannot both pass the check.
+func (r *PostgresScheduleRepository) Create(ctx context.Context, schedule PriceSchedule) (*PriceSchedule, error) {
+	if !isUUID(schedule.CoffeeID) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM coffees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, schedule.CoffeeID).Scan(&id); err != nil {
+		return nil, err
+	}
+
+	var overlaps bool
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM price_schedules
+		WHERE coffee_id = $1
+		AND (effective_to IS NULL OR effective_to > $2)
+		AND ($3::timestamptz IS NULL OR effective_from < $3)
+	)
+	`
+	if err := tx.QueryRowContext(ctx, query, schedule.CoffeeID, schedule.EffectiveFrom, schedule.EffectiveTo).Scan(&overlaps); err != nil {
+		return nil, err
+	}
+	if overlaps {
+		return nil, NewError(KindConflict, ErrScheduleOverlap)
+	}
+
+	query = `
+        INSERT INTO price_schedules(coffee_id, price_minor, currency, effective_from, effective_to)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `
+	if err := tx.QueryRowContext(ctx, query, schedule.CoffeeID, schedule.Price.Amount, schedule.Price.Currency, schedule.EffectiveFrom, schedule.EffectiveTo).Scan(&schedule.ID, &schedule.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// Cancel withdraws a schedule at the given time: one that has not taken
+// effect yet is removed, and one in effect ends at that time. Schedules that
+// are over are kept as they were and reported as ErrScheduleEnded.
+func (r *PostgresScheduleRepository) Cancel(ctx context.Context, coffeeID, id string, at time.Time) error {
+	if !isUUID(coffeeID) || !isUUID(id) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	schedule, err := scanSchedule(tx.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM price_schedules WHERE coffee_id = $1 AND id = $2 FOR UPDATE`, coffeeID, id))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case at.Before(schedule.EffectiveFrom):
+		_, err = tx.ExecContext(ctx, `DELETE FROM price_schedules WHERE id = $1`, id)
+	case schedule.ActiveAt(at):
+		_, err = tx.ExecContext(ctx, `UPDATE price_schedules SET effective_to = $2 WHERE id = $1`, id, at)
+	default:
+		return NewError(KindConflict, ErrScheduleEnded)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Transitions marks the schedules that came into or went out of effect by
+// the given time and returns them, starts first. Marking and returning
+// happen in one statement each, so concurrent appliers never both see the
+// same transition.
+func (r *PostgresScheduleRepository) Transitions(ctx context.Context, at time.Time) ([]ScheduleTransition, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	started, err := querySchedules(ctx, tx, `UPDATE price_schedules SET started_at = $1 WHERE started_at IS NULL AND effective_from <= $1 RETURNING `+scheduleColumns, at)
+	if err != nil {
+		return nil, err
+	}
+
+	ended, err := querySchedules(ctx, tx, `UPDATE price_schedules SET ended_at = $1 WHERE ended_at IS NULL AND effective_to <= $1 RETURNING `+scheduleColumns, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return scheduleTransitions(started, ended), nil
+}
+
+// scheduleTransitions lists the starts, then the ends.
+func scheduleTransitions(started, ended []*PriceSchedule) []ScheduleTransition {
+	transitions := make([]ScheduleTransition, 0, len(started)+len(ended))
+	for _, schedule := range started {
+		transitions = append(transitions, ScheduleTransition{Schedule: schedule, Started: true})
+	}
+	for _, schedule := range ended {
+		transitions = append(transitions, ScheduleTransition{Schedule: schedule})
+	}
+
+	return transitions
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+
+	t.Run("Starts Now", func(t *testing.T) {
+		// Test that a schedule without a start takes effect immediately.
+		schedule := PriceSchedule{Price: Money{900, "EUR"}, EffectiveTo: at(time.Hour)}
+
+		if errs := schedule.Validate(now); errs != nil {
+			t.Fatalf("Validate() = %v, want nil", errs)
+		}
+		if !schedule.EffectiveFrom.Equal(now) {
+			t.Errorf("EffectiveFrom = %v, want %v", schedule.EffectiveFrom, now)
+		}
+	})
+
+	t.Run("Invalid Fields", func(t *testing.T) {
+		// Test a free schedule starting in the past and ending before it starts.
+		schedule := PriceSchedule{Price: Money{0, "EUR"}, EffectiveFrom: *at(-time.Hour), EffectiveTo: at(-2 * time.Hour)}
+
+		errs := schedule.Validate(now)
+		for _, field := range []string{"price", "effective_from", "effective_to"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("Validate() = %v, want an error for %s", errs, field)
+			}
+		}
+	})
+}
+
+func TestMemoryScheduleRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	coffees := NewMemoryCoffeeRepository()
+	schedules := NewMemoryScheduleRepository(coffees)
+
+	coffee, _ := coffees.Create(ctx, Coffee{Name: "Geisha", Price: Money{1800, "EUR"}})
+	now := time.Now()
+	tomorrow := now.Add(24 * time.Hour)
+
+	sale, err := schedules.Create(ctx, PriceSchedule{CoffeeID: coffee.ID, Price: Money{1500, "EUR"}, EffectiveFrom: now, EffectiveTo: &tomorrow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raise, err := schedules.Create(ctx, PriceSchedule{CoffeeID: coffee.ID, Price: Money{2000, "EUR"}, EffectiveFrom: tomorrow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Overlap", func(t *testing.T) {
+		// Test that a schedule within the sale is rejected as a conflict.
+		_, err := schedules.Create(ctx, PriceSchedule{CoffeeID: coffee.ID, Price: Money{1000, "EUR"}, EffectiveFrom: now.Add(time.Hour)})
+		if !errors.Is(err, ErrScheduleOverlap) || KindOf(err) != KindConflict {
+			t.Errorf("Create() error = %v, want a conflict wrapping ErrScheduleOverlap", err)
+		}
+	})
+
+	t.Run("Unknown Coffee", func(t *testing.T) {
+		// Test that schedules need an existing coffee.
+		if _, err := schedules.Create(ctx, PriceSchedule{CoffeeID: missingCoffeeID, Price: Money{1000, "EUR"}, EffectiveFrom: now}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Create() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		// Test that reads resolve the price in effect at the requested time.
+		for _, tc := range []struct {
+			at       time.Time
+			want     int64
+			schedule string
+		}{
+			{now.Add(-time.Hour), 1800, ""},
+			{now.Add(time.Hour), 1500, sale.ID},
+			{tomorrow.Add(time.Hour), 2000, raise.ID},
+		} {
+			read, _ := coffees.Get(ctx, coffee.ID)
+			if err := ApplySchedules(ctx, schedules, []*Coffee{read}, tc.at); err != nil {
+				t.Fatalf("ApplySchedules() error = %v", err)
+			}
+
+			if read.SellingPrice().Amount != tc.want || read.Price.Amount != 1800 {
+				t.Errorf("price at %v = %d over %d, want %d over the 18.00 base price", tc.at, read.SellingPrice().Amount, read.Price.Amount, tc.want)
+			}
+			if tc.schedule == "" && read.Schedule != nil || tc.schedule != "" && (read.Schedule == nil || read.Schedule.ScheduleID != tc.schedule || read.Schedule.BasePrice.Amount != 1800) {
+				t.Errorf("Schedule at %v = %+v, want %q over the 18.00 base price", tc.at, read.Schedule, tc.schedule)
+			}
+		}
+	})
+
+	t.Run("Transitions", func(t *testing.T) {
+		// Test that each start and end is reported exactly once.
+		first, err := schedules.Transitions(ctx, now.Add(time.Minute))
+		if err != nil || len(first) != 1 || !first[0].Started || first[0].Schedule.ID != sale.ID {
+			t.Fatalf("Transitions() = %v, %v, want the sale starting", first, err)
+		}
+		second, err := schedules.Transitions(ctx, now.Add(time.Minute))
+		if err != nil || len(second) != 0 {
+			t.Errorf("Transitions() again = %v, %v, want none", second, err)
+		}
+		third, err := schedules.Transitions(ctx, tomorrow)
+		if err != nil || len(third) != 2 || !third[0].Started || third[0].Schedule.ID != raise.ID || third[1].Started || third[1].Schedule.ID != sale.ID {
+			t.Errorf("Transitions(tomorrow) = %v, %v, want the raise starting and the sale ending", third, err)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		// Test that cancelling ends a running schedule and removes a future one.
+		if err := schedules.Cancel(ctx, coffee.ID, sale.ID, now.Add(time.Hour)); err != nil {
+			t.Fatalf("Cancel(sale) error = %v", err)
+		}
+		if err := schedules.Cancel(ctx, coffee.ID, sale.ID, now.Add(2*time.Hour)); !errors.Is(err, ErrScheduleEnded) {
+			t.Errorf("Cancel(sale) again error = %v, want ErrScheduleEnded", err)
+		}
+		if err := schedules.Cancel(ctx, coffee.ID, raise.ID, now.Add(time.Hour)); err != nil {
+			t.Fatalf("Cancel(raise) error = %v", err)
+		}
+
+		list, _ := schedules.List(ctx, coffee.ID)
+		if len(list) != 1 || list[0].ID != sale.ID || !list[0].EffectiveTo.Equal(now.Add(time.Hour)) {
+			t.Errorf("List() = %v, want only the sale, ending an hour in", list)
+		}
+		if err := schedules.Cancel(ctx, missingCoffeeID, sale.ID, now); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Cancel(other coffee) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestPostgresScheduleCreate(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2023, 12, 24, 12, 0, 0, 0, time.UTC)
+	schedule := PriceSchedule{CoffeeID: testCoffeeID, Price: Money{1500, "EUR"}, EffectiveFrom: from}
+	lock := regexp.QuoteMeta("SELECT id FROM coffees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE")
+	overlap := regexp.QuoteMeta("SELECT EXISTS")
+
+	t.Run("Created", func(t *testing.T) {
+		// Test that the coffee is locked and checked for overlaps before inserting.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WithArgs(testCoffeeID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testCoffeeID))
+		mock.ExpectQuery(overlap).WithArgs(testCoffeeID, from, nil).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO price_schedules")).
+			WithArgs(testCoffeeID, int64(1500), "EUR", from, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(missingCoffeeID, from))
+		mock.ExpectCommit()
+
+		created, err := NewPostgresScheduleRepository(db).Create(context.Background(), schedule)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if created.ID != missingCoffeeID {
+			t.Errorf("ID = %q, want %q", created.ID, missingCoffeeID)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Overlap", func(t *testing.T) {
+		// Test that an overlapping schedule is reported without inserting.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testCoffeeID))
+		mock.ExpectQuery(overlap).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		_, err := NewPostgresScheduleRepository(db).Create(context.Background(), schedule)
+		if !errors.Is(err, ErrScheduleOverlap) || KindOf(err) != KindConflict {
+			t.Errorf("Create() error = %v, want a conflict wrapping ErrScheduleOverlap", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
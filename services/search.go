@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptySearch is returned when a search query has no searchable terms.
+var ErrEmptySearch = errors.New("search query must not be empty")
+
+// fuzzyThreshold is the minimum similarity for a typo to count as a match.
+// It matches the pg_trgm word_similarity_threshold used by the <% operator.
+const fuzzyThreshold = 0.6
+
+// Matched terms are first delimited with these private use characters, which
+// survive HTML escaping, and only then turned into <b></b>. The highlight is
+// therefore safe to render as HTML whatever the coffee is named. Delimiters
+// already present in a name or region are stripped before highlighting.
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+// stripHighlight removes the highlight delimiters from s.
+var stripHighlight = strings.NewReplacer(highlightStart, "", highlightStop, "").Replace
+
+// SearchResult is a coffee matched by a search, with its relevance and the
+// HTML-escaped name and region joined with matched terms wrapped in <b></b>.
+type SearchResult struct {
+	Coffee    *Coffee `json:"coffee"`
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// Search ranks coffees that are not deleted and whose name or region match
+// the query, either through full-text search or, for typos, trigram
+// similarity. Results carry the base price; callers apply the price
+// schedules with ApplySchedules.
+func (r *PostgresCoffeeRepository) Search(ctx context.Context, q string, limit int) ([]*SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if strings.TrimSpace(q) == "" {
+		return nil, ErrEmptySearch
+	}
+
+	query := `
+	SELECT id, name, image, roast, region, COALESCE(origin_id::text, ''), price_minor, currency, grind_unit, created_at, updated_at,
+	       ts_rank(search_vector, tsq) + greatest(word_similarity($1, name), word_similarity($1, region)) AS rank,
+	       ts_headline('simple', translate(name || ', ' || region, $4, ''), tsq, $3) AS highlight
+	FROM coffees, plainto_tsquery('simple', $1) AS tsq
+	WHERE deleted_at IS NULL AND (search_vector @@ tsq OR $1 <% name OR $1 <% region)
+	ORDER BY rank DESC, id
+	LIMIT $2
+	`
+
+	options := "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+
+	rows, err := r.db.QueryContext(ctx, query, q, ListOptions{Limit: limit}.pageSize(), options, highlightStart+highlightStop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		var coffee Coffee
+		result := SearchResult{Coffee: &coffee}
+		if err := rows.Scan(
+			&coffee.ID,
+			&coffee.Name,
+			&coffee.Image,
+			&coffee.Roast,
+			&coffee.Region,
+			&coffee.OriginID,
+			&coffee.Price.Amount,
+			&coffee.Price.Currency,
+			&coffee.GrindUnit,
+			&coffee.CreatedAt,
+			&coffee.UpdatedAt,
+			&result.Rank,
+			&result.Highlight,
+		); err != nil {
+			return nil, err
+		}
+
+		result.Highlight = markHighlight(result.Highlight)
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Search approximates the PostgreSQL search: every query term must occur in
+// the name or region as a substring or within the fuzzy edit distance.
+func (m *MemoryCoffeeRepository) Search(ctx context.Context, q string, limit int) ([]*SearchResult, error) {
+	terms := searchWords(q)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []*SearchResult{}
+	for _, coffee := range m.coffees {
+		coffee := coffee
+		if coffee.DeletedAt != nil {
+			continue
+		}
+		if result, ok := matchCoffee(&coffee, terms); ok {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Coffee.ID < results[j].Coffee.ID
+	})
+
+	if size := (ListOptions{Limit: limit}).pageSize(); len(results) > size {
+		results = results[:size]
+	}
+
+	return results, nil
+}
+
+// matchCoffee scores coffee against the lowercased query terms. Exact word
+// matches score 1, substrings 0.9 and typos their similarity, and the rank
+// is the mean score of the terms.
+func matchCoffee(coffee *Coffee, terms []string) (*SearchResult, bool) {
+	text := stripHighlight(coffee.Name + ", " + coffee.Region)
+	words := searchWords(text)
+	matched := make(map[string]bool)
+
+	var total float64
+	for _, term := range terms {
+		var best float64
+		var bestWord string
+		for _, word := range words {
+			score := termScore(term, word)
+			if score > best {
+				best, bestWord = score, word
+			}
+		}
+
+		if best < fuzzyThreshold {
+			return nil, false
+		}
+		total += best
+		matched[bestWord] = true
+	}
+
+	return &SearchResult{
+		Coffee:    coffee,
+		Rank:      total / float64(len(terms)),
+		Highlight: markHighlight(highlightWords(text, matched)),
+	}, true
+}
+
+// termScore rates how well a query term matches one word of a coffee.
+func termScore(term, word string) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.Contains(word, term):
+		return 0.9
+	}
+
+	longest := max(len([]rune(term)), len([]rune(word)))
+
+	return 1 - float64(levenshtein(term, word))/float64(longest)
+}
+
+// highlightWords delimits the words of text whose lowercase form is in
+// matched with highlightStart and highlightStop.
+func highlightWords(text string, matched map[string]bool) string {
+	var b strings.Builder
+	var word []rune
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		if matched[strings.ToLower(string(word))] {
+			b.WriteString(highlightStart + string(word) + highlightStop)
+		} else {
+			b.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+
+	return b.String()
+}
+
+// markHighlight HTML-escapes a highlight delimited by highlightStart and
+// highlightStop and turns the delimiters into <b></b>.
+func markHighlight(s string) string {
+	return strings.NewReplacer(highlightStart, "<b>", highlightStop, "</b>").Replace(html.EscapeString(s))
+}
+
+// searchWords splits s into lowercase words of letters and digits.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// levenshtein returns the edit distance between a and b in runes.
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(br)]
+}
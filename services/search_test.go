@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Ranked Results", func(t *testing.T) {
+		// Test that rank and highlight are scanned alongside the coffee.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "rank", "highlight"}).
+			AddRow("1", "Yirgacheffe", "test.jpg", "Light", "Ethiopia", "", 1300, "EUR", 2, time.Now(), time.Now(), 0.75, "Yirgacheffe, Ethiopia")
+
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE deleted_at IS NULL AND (search_vector @@ tsq OR $1 <% name OR $1 <% region)")).
+			WithArgs("etiopia", DefaultPageSize, sqlmock.AnyArg(), highlightStart+highlightStop).
+			WillReturnRows(rows)
+
+		models := New(db)
+
+		results, err := models.Coffees.Search(context.Background(), "etiopia", 0)
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+
+		if len(results) != 1 || results[0].Coffee.Name != "Yirgacheffe" || results[0].Rank != 0.75 {
+			t.Errorf("Mismatch in search results: got %+v", results)
+		}
+	})
+}
+
+func TestLevenshtein(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Edit Distances", func(t *testing.T) {
+		// Test insertions, substitutions and multi-byte runes.
+		cases := []struct {
+			a, b string
+			want int
+		}{
+			{"etiopia", "ethiopia", 1},
+			{"kenia", "kenya", 1},
+			{"", "java", 4},
+			{"café", "cafe", 1},
+			{"huila", "huila", 0},
+		}
+
+		for _, tc := range cases {
+			if got := levenshtein(tc.a, tc.b); got != tc.want {
+				t.Errorf("levenshtein(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestHighlightWords(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Matched Words Only", func(t *testing.T) {
+		// Test that only matched words are wrapped, keeping their original case.
+		got := markHighlight(highlightWords("Yirgacheffe, Ethiopia", map[string]bool{"ethiopia": true}))
+		if got != "Yirgacheffe, <b>Ethiopia</b>" {
+			t.Errorf("highlightWords() = %q", got)
+		}
+	})
+}
+
+func TestMarkHighlight(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Escaped Markup", func(t *testing.T) {
+		// Test that markup in a coffee name is escaped while matches are still bold.
+		got := markHighlight(highlightWords(`<img src=x onerror="alert(1)"> Cherry & Co, Kenya`, map[string]bool{"cherry": true, "kenya": true}))
+		want := `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <b>Cherry</b> &amp; Co, <b>Kenya</b>`
+		if got != want {
+			t.Errorf("highlight = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Postgres Headline", func(t *testing.T) {
+		// Test that ts_headline output is escaped before its markers become tags.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"id", "name", "image", "roast", "region", "origin_id", "price_minor", "currency", "grind_unit", "created_at", "updated_at", "rank", "highlight"}).
+			AddRow("1", "<script>", "test.jpg", "Light", "Kenya", "", 1300, "EUR", 2, time.Now(), time.Now(), 0.5, "<script>, "+highlightStart+"Kenya"+highlightStop)
+		mock.ExpectQuery("ts_headline").WillReturnRows(rows)
+
+		results, err := New(db).Coffees.Search(context.Background(), "kenya", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := results[0].Highlight; got != "&lt;script&gt;, <b>Kenya</b>" {
+			t.Errorf("highlight = %q", got)
+		}
+	})
+	t.Run("Delimiters In Name", func(t *testing.T) {
+		// Test that delimiter characters stored in a name cannot open or close a highlight.
+		coffee := &Coffee{Name: "Ke" + highlightStop + "nya AA" + highlightStart, Region: "Nyeri"}
+
+		result, ok := matchCoffee(coffee, []string{"kenya"})
+		if !ok {
+			t.Fatal("matchCoffee() did not match")
+		}
+		if want := "<b>Kenya</b> AA, Nyeri"; result.Highlight != want {
+			t.Errorf("highlight = %q, want %q", result.Highlight, want)
+		}
+	})
+}
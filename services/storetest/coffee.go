@@ -0,0 +1,720 @@
+// Package storetest provides conformance tests that every services repository
+// implementation must pass, so that all storage backends behave identically.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidandw190/coffeeshop-api-go/services"
+)
+
+// NewCoffeeRepository returns an empty repository for a single subtest.
+type NewCoffeeRepository func(t *testing.T) services.CoffeeRepository
+
+// TestCoffeeRepository runs the coffee repository conformance suite. Subtests
+// run sequentially and each one receives a fresh, empty repository.
+func TestCoffeeRepository(t *testing.T, newRepo NewCoffeeRepository) {
+	ctx := context.Background()
+
+	t.Run("Create And Get", func(t *testing.T) {
+		// Test that a created coffee is returned intact by Get.
+		repo := newRepo(t)
+
+		input := sampleCoffee("Yirgacheffe")
+		created, err := repo.Create(ctx, input)
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		if created.ID == "" {
+			t.Fatal("Create returned an empty ID")
+		}
+		if created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+			t.Errorf("Create returned zero timestamps: %+v", created)
+		}
+
+		found, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+
+		assertSameCoffee(t, found, created)
+	})
+
+	t.Run("List Empty", func(t *testing.T) {
+		// Test listing a repository without coffees.
+		repo := newRepo(t)
+
+		coffees := listAll(t, repo)
+
+		if len(coffees) != 0 {
+			t.Errorf("Expected 0 coffees, got %d", len(coffees))
+		}
+	})
+
+	t.Run("List Ordering", func(t *testing.T) {
+		// Test that coffees are listed in creation order.
+		repo := newRepo(t)
+
+		created := createMany(t, repo, 5)
+
+		coffees := listAll(t, repo)
+
+		if len(coffees) != len(created) {
+			t.Fatalf("Expected %d coffees, got %d", len(created), len(coffees))
+		}
+		for i := range created {
+			assertSameCoffee(t, coffees[i], created[i])
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		// Test that an update replaces fields and bumps updated_at only.
+		repo := newRepo(t)
+
+		created, err := repo.Create(ctx, sampleCoffee("Sidamo"))
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		changes := *created
+		changes.Name = "Sidamo Natural"
+		changes.Price = services.Money{Amount: 1325, Currency: "USD"}
+		changes.GrindUnit = 4
+
+		updated, err := repo.Update(ctx, changes, created.UpdatedAt)
+		if err != nil {
+			t.Fatalf("Update error: %v", err)
+		}
+
+		if !updated.CreatedAt.Equal(created.CreatedAt) {
+			t.Errorf("Update changed created_at from %v to %v", created.CreatedAt, updated.CreatedAt)
+		}
+		if !updated.UpdatedAt.After(created.UpdatedAt) {
+			t.Errorf("Update did not advance updated_at: %v then %v", created.UpdatedAt, updated.UpdatedAt)
+		}
+
+		found, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+
+		assertSameCoffee(t, found, updated)
+	})
+
+	t.Run("Update Stale Version", func(t *testing.T) {
+		// Test that a write based on an outdated version is rejected untouched.
+		repo := newRepo(t)
+
+		created, err := repo.Create(ctx, sampleCoffee("Huila"))
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		first := *created
+		first.Name = "Huila Washed"
+		if _, err := repo.Update(ctx, first, created.UpdatedAt); err != nil {
+			t.Fatalf("Update error: %v", err)
+		}
+
+		second := *created
+		second.Name = "Huila Honey"
+		if _, err := repo.Update(ctx, second, created.UpdatedAt); !errors.Is(err, services.ErrEditConflict) {
+			t.Fatalf("Expected ErrEditConflict, got %v", err)
+		}
+
+		found, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+		if found.Name != first.Name {
+			t.Errorf("Expected name %q after the rejected write, got %q", first.Name, found.Name)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		// Test that a deleted coffee is gone from Get and List.
+		repo := newRepo(t)
+
+		created, err := repo.Create(ctx, sampleCoffee("Antigua"))
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		if err := repo.Delete(ctx, created.ID); err != nil {
+			t.Fatalf("Delete error: %v", err)
+		}
+
+		if _, err := repo.Get(ctx, created.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get after Delete: expected sql.ErrNoRows, got %v", err)
+		}
+
+		coffees := listAll(t, repo)
+		if len(coffees) != 0 {
+			t.Errorf("Expected 0 coffees after Delete, got %d", len(coffees))
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		// Test that a deleted coffee is listed on request and comes back on Restore.
+		repo := newRepo(t)
+
+		created, err := repo.Create(ctx, sampleCoffee("Antigua"))
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		if _, err := repo.Restore(ctx, created.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Restore before Delete: expected sql.ErrNoRows, got %v", err)
+		}
+
+		if err := repo.Delete(ctx, created.ID); err != nil {
+			t.Fatalf("Delete error: %v", err)
+		}
+		if err := repo.Delete(ctx, created.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Delete twice: expected sql.ErrNoRows, got %v", err)
+		}
+		if _, err := repo.Update(ctx, *created, time.Time{}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Update after Delete: expected sql.ErrNoRows, got %v", err)
+		}
+
+		page, err := repo.List(ctx, services.ListOptions{IncludeDeleted: true})
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+		if len(page.Coffees) != 1 || page.Coffees[0].DeletedAt == nil {
+			t.Fatalf("Expected the deleted coffee with deleted_at, got %+v", page.Coffees)
+		}
+		if !page.Coffees[0].UpdatedAt.After(created.UpdatedAt) {
+			t.Errorf("Delete kept updated_at %v, want it bumped past %v", page.Coffees[0].UpdatedAt, created.UpdatedAt)
+		}
+
+		restored, err := repo.Restore(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Restore error: %v", err)
+		}
+		if restored.DeletedAt != nil || !restored.UpdatedAt.After(created.UpdatedAt) {
+			t.Errorf("Restore returned %+v, want a live coffee with a newer updated_at", restored)
+		}
+
+		found, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Get after Restore error: %v", err)
+		}
+		assertSameCoffee(t, found, restored)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		// Test that Purge only removes coffees deleted before the cut-off.
+		repo := newRepo(t)
+
+		created := createMany(t, repo, 3)
+		for _, coffee := range created[:2] {
+			if err := repo.Delete(ctx, coffee.ID); err != nil {
+				t.Fatalf("Delete error: %v", err)
+			}
+		}
+
+		if purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+			t.Errorf("Purge before retention: got %d, %v, want 0", purged, err)
+		}
+
+		purged, err := repo.Purge(ctx, time.Now().Add(time.Minute))
+		if err != nil || purged != 2 {
+			t.Fatalf("Purge: got %d, %v, want 2", purged, err)
+		}
+
+		if _, err := repo.Restore(ctx, created[0].ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Restore after Purge: expected sql.ErrNoRows, got %v", err)
+		}
+
+		page, err := repo.List(ctx, services.ListOptions{IncludeDeleted: true})
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+		if len(page.Coffees) != 1 || page.Coffees[0].ID != created[2].ID {
+			t.Errorf("Expected only the live coffee after Purge, got %v", coffeeNames(page.Coffees))
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		// Test that every lookup by an unknown ID reports sql.ErrNoRows.
+		repo := newRepo(t)
+
+		const missingID = "00000000-0000-4000-8000-000000000000"
+
+		if _, err := repo.Get(ctx, missingID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get: expected sql.ErrNoRows, got %v", err)
+		}
+		if _, err := repo.Update(ctx, services.Coffee{ID: missingID}, time.Time{}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Update: expected sql.ErrNoRows, got %v", err)
+		}
+		if err := repo.Delete(ctx, missingID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Delete: expected sql.ErrNoRows, got %v", err)
+		}
+		if _, err := repo.Get(ctx, "not-a-uuid"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get malformed ID: expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("Concurrent Creates", func(t *testing.T) {
+		// Test that parallel inserts all land with distinct IDs.
+		repo := newRepo(t)
+
+		const writers = 20
+
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := repo.Create(ctx, sampleCoffee(fmt.Sprintf("Concurrent %d", i)))
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+		}
+
+		coffees := listAll(t, repo)
+
+		ids := make(map[string]bool)
+		for _, coffee := range coffees {
+			ids[coffee.ID] = true
+		}
+		if len(ids) != writers {
+			t.Errorf("Expected %d distinct coffees, got %d", writers, len(ids))
+		}
+	})
+
+	t.Run("Concurrent Conditional Updates", func(t *testing.T) {
+		// Test that exactly one of several writers holding the same version wins.
+		repo := newRepo(t)
+
+		created, err := repo.Create(ctx, sampleCoffee("Tarrazu"))
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		const writers = 8
+
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				changes := *created
+				changes.Name = fmt.Sprintf("Tarrazu %d", i)
+				_, err := repo.Update(ctx, changes, created.UpdatedAt)
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		var wins int
+		for err := range errs {
+			switch {
+			case err == nil:
+				wins++
+			case !errors.Is(err, services.ErrEditConflict):
+				t.Errorf("Expected ErrEditConflict for losing writers, got %v", err)
+			}
+		}
+
+		if wins != 1 {
+			t.Errorf("Expected exactly 1 successful update, got %d", wins)
+		}
+	})
+
+	t.Run("Pagination Boundaries", func(t *testing.T) {
+		// Test limit and offset paging around the edges of the listing.
+		repo := newRepo(t)
+		created := createMany(t, repo, 5)
+
+		cases := []struct {
+			name     string
+			opts     services.ListOptions
+			want     []*services.Coffee
+			wantNext bool
+		}{
+			{"Default Limit", services.ListOptions{}, created, false},
+			{"Limit Below Total", services.ListOptions{Limit: 2}, created[:2], true},
+			{"Limit Equals Total", services.ListOptions{Limit: 5}, created, false},
+			{"Limit Above Maximum", services.ListOptions{Limit: services.MaxPageSize + 1}, created, false},
+			{"Offset Inside", services.ListOptions{Limit: 2, Offset: 3}, created[3:], false},
+			{"Offset At End", services.ListOptions{Offset: 5}, nil, false},
+			{"Offset Past End", services.ListOptions{Offset: 50}, nil, false},
+		}
+
+		for _, tc := range cases {
+			page, err := repo.List(ctx, tc.opts)
+			if err != nil {
+				t.Fatalf("%s: List error: %v", tc.name, err)
+			}
+
+			if page.Total != len(created) {
+				t.Errorf("%s: expected total %d, got %d", tc.name, len(created), page.Total)
+			}
+			if (page.NextCursor != "") != tc.wantNext {
+				t.Errorf("%s: expected next cursor %v, got %q", tc.name, tc.wantNext, page.NextCursor)
+			}
+			if len(page.Coffees) != len(tc.want) {
+				t.Fatalf("%s: expected %d coffees, got %d", tc.name, len(tc.want), len(page.Coffees))
+			}
+			for i := range tc.want {
+				assertSameCoffee(t, page.Coffees[i], tc.want[i])
+			}
+		}
+	})
+
+	t.Run("Cursor Walk", func(t *testing.T) {
+		// Test that following next cursors visits every coffee exactly once.
+		repo := newRepo(t)
+		created := createMany(t, repo, 7)
+
+		var visited []*services.Coffee
+		opts := services.ListOptions{Limit: 3}
+		for pages := 0; ; pages++ {
+			if pages > len(created) {
+				t.Fatal("Cursor walk did not terminate")
+			}
+
+			page, err := repo.List(ctx, opts)
+			if err != nil {
+				t.Fatalf("List error: %v", err)
+			}
+			visited = append(visited, page.Coffees...)
+
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+
+		if len(visited) != len(created) {
+			t.Fatalf("Expected %d coffees, got %d", len(created), len(visited))
+		}
+		for i := range created {
+			assertSameCoffee(t, visited[i], created[i])
+		}
+	})
+
+	t.Run("Cursor Skips Deleted Position", func(t *testing.T) {
+		// Test that a cursor stays valid after the coffee it points at is deleted.
+		repo := newRepo(t)
+		created := createMany(t, repo, 4)
+
+		page, err := repo.List(ctx, services.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+
+		if err := repo.Delete(ctx, created[1].ID); err != nil {
+			t.Fatalf("Delete error: %v", err)
+		}
+
+		next, err := repo.List(ctx, services.ListOptions{Limit: 2, Cursor: page.NextCursor})
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+
+		if len(next.Coffees) != 2 || next.Coffees[0].ID != created[2].ID {
+			t.Errorf("Expected the page to resume at %s, got %+v", created[2].ID, next.Coffees)
+		}
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		// Test that malformed cursors and cursors mixed with offsets are rejected.
+		repo := newRepo(t)
+		created := createMany(t, repo, 3)
+
+		if _, err := repo.List(ctx, services.ListOptions{Cursor: "not-a-cursor"}); !errors.Is(err, services.ErrInvalidCursor) {
+			t.Errorf("Malformed cursor: expected ErrInvalidCursor, got %v", err)
+		}
+
+		page, err := repo.List(ctx, services.ListOptions{Limit: 1})
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+		if len(page.Coffees) != 1 || page.Coffees[0].ID != created[0].ID {
+			t.Fatalf("Expected the first coffee, got %+v", page.Coffees)
+		}
+
+		if _, err := repo.List(ctx, services.ListOptions{Cursor: page.NextCursor, Offset: 1}); !errors.Is(err, services.ErrInvalidCursor) {
+			t.Errorf("Cursor with offset: expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		// Test roast and region lists and the inclusive price range, which
+		// only matches coffees priced in its currency.
+		repo := newRepo(t)
+		menu := createMenu(t, repo)
+
+		kyoto := sampleCoffee("Kyoto")
+		kyoto.Region, kyoto.Price = "Japan", services.Money{Amount: 1000, Currency: "JPY"}
+		if _, err := repo.Create(ctx, kyoto); err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		priceMin, priceMax := int64(900), int64(1200)
+		cases := []struct {
+			name string
+			opts services.ListOptions
+			want []string
+		}{
+			{"Roast", services.ListOptions{Roasts: []string{"dark"}}, []string{"Sumatra", "Java"}},
+			{"Roast List", services.ListOptions{Roasts: []string{"LIGHT", "Dark"}}, []string{"Kenya", "Sumatra", "Java"}},
+			{"Region", services.ListOptions{Regions: []string{"indonesia"}}, []string{"Sumatra", "Java"}},
+			{"Price Range", services.ListOptions{PriceMin: &priceMin, PriceMax: &priceMax}, []string{"Kenya", "Java"}},
+			{"Price Minimum", services.ListOptions{PriceMin: &priceMax}, []string{"Kenya", "Huila"}},
+			{"Price Currency", services.ListOptions{PriceMin: &priceMin, PriceMax: &priceMax, PriceCurrency: "JPY"}, []string{"Kyoto"}},
+			{"Combined", services.ListOptions{Roasts: []string{"dark"}, PriceMax: &priceMax}, []string{"Sumatra", "Java"}},
+			{"No Match", services.ListOptions{Regions: []string{"Peru"}}, nil},
+		}
+
+		for _, tc := range cases {
+			page, err := repo.List(ctx, tc.opts)
+			if err != nil {
+				t.Fatalf("%s: List error: %v", tc.name, err)
+			}
+
+			if got := coffeeNames(page.Coffees); !equalNames(got, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+			if page.Total != len(tc.want) {
+				t.Errorf("%s: expected total %d, got %d", tc.name, len(tc.want), page.Total)
+			}
+		}
+
+		if len(menu) != 4 {
+			t.Fatalf("Expected 4 menu coffees, got %d", len(menu))
+		}
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		// Test whitelisted sort fields, descending order and stable paging.
+		repo := newRepo(t)
+		createMenu(t, repo)
+
+		cases := []struct {
+			name string
+			sort string
+			want []string
+		}{
+			{"Price Ascending", "price", []string{"Sumatra", "Java", "Kenya", "Huila"}},
+			{"Price Descending", "-price", []string{"Huila", "Kenya", "Java", "Sumatra"}},
+			{"Roast Then Newest", "roast,-created_at", []string{"Java", "Sumatra", "Kenya", "Huila"}},
+			{"Name", "name", []string{"Huila", "Java", "Kenya", "Sumatra"}},
+		}
+
+		for _, tc := range cases {
+			fields, err := services.ParseSort(tc.sort)
+			if err != nil {
+				t.Fatalf("%s: ParseSort error: %v", tc.name, err)
+			}
+
+			first, err := repo.List(ctx, services.ListOptions{Sort: fields, Limit: 2})
+			if err != nil {
+				t.Fatalf("%s: List error: %v", tc.name, err)
+			}
+			second, err := repo.List(ctx, services.ListOptions{Sort: fields, Limit: 2, Offset: 2})
+			if err != nil {
+				t.Fatalf("%s: List error: %v", tc.name, err)
+			}
+
+			if first.NextCursor != "" {
+				t.Errorf("%s: expected no cursor for a custom sort, got %q", tc.name, first.NextCursor)
+			}
+
+			got := coffeeNames(append(first.Coffees, second.Coffees...))
+			if !equalNames(got, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+		}
+
+		fields, _ := services.ParseSort("price")
+		if _, err := repo.List(ctx, services.ListOptions{Sort: fields, Cursor: "eyJpIjoieCJ9"}); !errors.Is(err, services.ErrInvalidCursor) {
+			t.Errorf("Cursor with sort: expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		// Test exact, typo tolerant and empty searches over name and region.
+		repo := newRepo(t)
+		createMenu(t, repo)
+		yirgacheffe, err := repo.Create(ctx, services.Coffee{Name: "Yirgacheffe", Roast: "light", Region: "Ethiopia", Price: services.Money{Amount: 1300, Currency: "EUR"}, GrindUnit: 2, Image: "https://example.com/yirgacheffe.jpg"})
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+
+		results, err := repo.Search(ctx, "ethiopia", 10)
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+		if len(results) != 1 || results[0].Coffee.ID != yirgacheffe.ID {
+			t.Fatalf("Expected only Yirgacheffe for an exact region match, got %+v", results)
+		}
+		if !strings.Contains(results[0].Highlight, "<b>Ethiopia</b>") {
+			t.Errorf("Expected the region to be highlighted, got %q", results[0].Highlight)
+		}
+
+		results, err = repo.Search(ctx, "etiopia", 10)
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+		if len(results) != 1 || results[0].Coffee.ID != yirgacheffe.ID {
+			t.Errorf("Expected a misspelled region to find Yirgacheffe, got %+v", results)
+		}
+
+		results, err = repo.Search(ctx, "Indonesia", 10)
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("Expected 2 Indonesian coffees, got %d", len(results))
+		}
+
+		results, err = repo.Search(ctx, "Guatemala", 10)
+		if err != nil {
+			t.Fatalf("Search error: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("Expected no results, got %+v", results)
+		}
+
+		if _, err := repo.Search(ctx, "  ", 10); !errors.Is(err, services.ErrEmptySearch) {
+			t.Errorf("Blank query: expected ErrEmptySearch, got %v", err)
+		}
+	})
+}
+
+// createMenu creates four coffees with distinct roasts, regions and prices,
+// in the order Kenya, Sumatra, Java, Huila.
+func createMenu(t *testing.T, repo services.CoffeeRepository) []*services.Coffee {
+	t.Helper()
+
+	menu := []services.Coffee{
+		{Name: "Kenya", Roast: "light", Region: "Kenya", Price: services.Money{Amount: 1200, Currency: "EUR"}, GrindUnit: 2, Image: "https://example.com/kenya.jpg"},
+		{Name: "Sumatra", Roast: "dark", Region: "Indonesia", Price: services.Money{Amount: 850, Currency: "EUR"}, GrindUnit: 3, Image: "https://example.com/sumatra.jpg"},
+		{Name: "Java", Roast: "dark", Region: "Indonesia", Price: services.Money{Amount: 900, Currency: "EUR"}, GrindUnit: 3, Image: "https://example.com/java.jpg"},
+		{Name: "Huila", Roast: "medium", Region: "Colombia", Price: services.Money{Amount: 1475, Currency: "EUR"}, GrindUnit: 2, Image: "https://example.com/huila.jpg"},
+	}
+
+	created := make([]*services.Coffee, 0, len(menu))
+	for _, coffee := range menu {
+		stored, err := repo.Create(context.Background(), coffee)
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+		created = append(created, stored)
+	}
+
+	return created
+}
+
+// coffeeNames returns the names of coffees in order.
+func coffeeNames(coffees []*services.Coffee) []string {
+	var names []string
+	for _, coffee := range coffees {
+		names = append(names, coffee.Name)
+	}
+
+	return names
+}
+
+// equalNames compares two ordered name lists.
+func equalNames(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// createMany creates n coffees one after another and returns them in
+// creation order.
+func createMany(t *testing.T, repo services.CoffeeRepository, n int) []*services.Coffee {
+	t.Helper()
+
+	created := make([]*services.Coffee, 0, n)
+	for i := 0; i < n; i++ {
+		coffee, err := repo.Create(context.Background(), sampleCoffee(fmt.Sprintf("Coffee %d", i)))
+		if err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+		created = append(created, coffee)
+	}
+
+	return created
+}
+
+// listAll walks every page of the listing and returns the coffees in order.
+func listAll(t *testing.T, repo services.CoffeeRepository) []*services.Coffee {
+	t.Helper()
+
+	var coffees []*services.Coffee
+	opts := services.ListOptions{Limit: services.MaxPageSize}
+	for {
+		page, err := repo.List(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+		coffees = append(coffees, page.Coffees...)
+
+		if page.NextCursor == "" {
+			return coffees
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// sampleCoffee returns a fully populated coffee ready to be created.
+func sampleCoffee(name string) services.Coffee {
+	return services.Coffee{
+		Name:      name,
+		Roast:     "medium",
+		Image:     "https://example.com/coffee.jpg",
+		Region:    "Ethiopia",
+		Price:     services.Money{Amount: 1250, Currency: "EUR"},
+		GrindUnit: 2,
+	}
+}
+
+// assertSameCoffee compares two coffees field by field, using time equality
+// rather than struct equality so that time zones do not matter.
+func assertSameCoffee(t *testing.T, got, want *services.Coffee) {
+	t.Helper()
+
+	if got.ID != want.ID ||
+		got.Name != want.Name ||
+		got.Roast != want.Roast ||
+		got.Image != want.Image ||
+		got.Region != want.Region ||
+		got.Price != want.Price ||
+		got.GrindUnit != want.GrindUnit ||
+		!got.CreatedAt.Equal(want.CreatedAt) ||
+		!got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("Mismatch in coffee data: expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxNameLength = 100
+
+// ValidationErrors maps JSON field names to the reason they were rejected.
+type ValidationErrors map[string]string
+
+// Error lists the invalid fields in a stable order.
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field := range v {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for i, field := range fields {
+		fields[i] = field + " " + v[field]
+	}
+
+	return "validation failed: " + strings.Join(fields, "; ")
+}
+
+// check records message against field unless ok holds or field already failed.
+func (v ValidationErrors) check(ok bool, field, message string) {
+	if _, exists := v[field]; !ok && !exists {
+		v[field] = message
+	}
+}
+
+// Validate checks the client supplied fields of a coffee and returns the
+// problems found, or nil when the coffee is valid. The roast and grind unit
+// are checked against the lookup tables by CheckCoffeeLookups.
+func (c *Coffee) Validate() ValidationErrors {
+	v := ValidationErrors{}
+
+	v.check(strings.TrimSpace(c.Name) != "", "name", "must be provided")
+	v.check(utf8.RuneCountInString(c.Name) <= maxNameLength, "name", fmt.Sprintf("must not be more than %d characters long", maxNameLength))
+
+	v.check(strings.TrimSpace(c.Region) != "", "region", "must be provided")
+	v.check(c.OriginID == "" || isUUID(c.OriginID), "origin_id", "must be the id of an existing origin")
+
+	v.check(c.Roast != "", "roast", "must be provided")
+
+	v.check(c.Price.Amount > 0, "price", "must be greater than zero")
+	v.check(IsCurrencyCode(c.Price.Currency), "currency", "must be a three-letter ISO 4217 code")
+
+	v.check(c.Image != "", "image", "must be provided")
+	v.check(isHTTPURL(c.Image), "image", "must be an absolute http or https URL")
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// isHTTPURL reports whether s is an absolute http(s) URL with a host.
+func isHTTPURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestCoffeeValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() Coffee {
+		return Coffee{
+			Name:      "TestCoffee",
+			Image:     "https://example.com/test.jpg",
+			Region:    "Kenya",
+			Roast:     "light",
+			Price:     Money{Amount: 999, Currency: "EUR"},
+			GrindUnit: 1,
+		}
+	}
+
+	t.Run("Valid Coffee", func(t *testing.T) {
+		// Test that a complete coffee passes validation.
+		coffee := valid()
+		if errs := coffee.Validate(); errs != nil {
+			t.Errorf("Validate() = %v, want nil", errs)
+		}
+	})
+
+	t.Run("Empty Coffee", func(t *testing.T) {
+		// Test that every required field is reported once.
+		coffee := Coffee{}
+		errs := coffee.Validate()
+
+		for _, field := range []string{"name", "region", "roast", "price", "image"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("Validate() did not report %s: %v", field, errs)
+			}
+		}
+
+		if errs["roast"] != "must be provided" {
+			t.Errorf("Validate() roast = %q, want the first failed check", errs["roast"])
+		}
+	})
+
+	t.Run("Invalid Values", func(t *testing.T) {
+		// Test range and URL format checks.
+		coffee := valid()
+		coffee.Price.Amount = -1
+		coffee.Price.Currency = "euro"
+		coffee.Image = "javascript:alert(1)"
+
+		errs := coffee.Validate()
+		for _, field := range []string{"price", "currency", "image"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("Validate() did not report %s: %v", field, errs)
+			}
+		}
+
+		if _, ok := errs["name"]; ok {
+			t.Errorf("Validate() reported a valid name: %v", errs)
+		}
+	})
+
+	t.Run("Error Message", func(t *testing.T) {
+		// Test that the error string lists fields in a stable order.
+		errs := ValidationErrors{"price": "must be greater than zero", "name": "must be provided"}
+
+		want := "validation failed: name must be provided; price must be greater than zero"
+		if errs.Error() != want {
+			t.Errorf("Error() = %q, want %q", errs.Error(), want)
+		}
+	})
+}
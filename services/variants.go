@@ -0,0 +1,494 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrDuplicateSKU is returned when a variant is stored with a SKU that
+// another variant already uses.
+var ErrDuplicateSKU = errors.New("the sku is already used by another variant")
+
+// Variant formats: bags of beans or ground coffee sold by weight, and cups
+// served in the shop.
+const (
+	FormatBag = "bag"
+	FormatCup = "cup"
+)
+
+// VariantFormats lists the accepted values of Variant.Format.
+var VariantFormats = []string{FormatBag, FormatCup}
+
+const (
+	maxSKULength  = 64
+	maxSizeLength = 32
+	maxBagWeight  = 5000
+)
+
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// VariantRepository is the storage contract for the sellable variants of
+// coffees. Get, Update and Delete report a missing variant as sql.ErrNoRows,
+// and writes report a SKU clash as ErrDuplicateSKU.
+type VariantRepository interface {
+	List(ctx context.Context, coffeeID string) ([]*Variant, error)
+	Find(ctx context.Context, coffeeIDs []string) (map[string][]*Variant, error)
+	Get(ctx context.Context, coffeeID, id string) (*Variant, error)
+	Create(ctx context.Context, variant Variant) (*Variant, error)
+	Update(ctx context.Context, variant Variant) (*Variant, error)
+	Delete(ctx context.Context, coffeeID, id string) error
+}
+
+// Variant is one way a coffee is sold, such as a 250g bag or a large cup,
+// with its own SKU and price. GrindUnits lists the grind settings a bag can
+// be ordered with; cups have none and no weight.
+type Variant struct {
+	ID          string    `json:"id"`
+	CoffeeID    string    `json:"coffee_id"`
+	SKU         string    `json:"sku"`
+	Format      string    `json:"format"`
+	Size        string    `json:"size"`
+	WeightGrams *int      `json:"weight_grams"`
+	Price       Money     `json:"price"`
+	GrindUnits  []int16   `json:"grind_units"`
+	Available   bool      `json:"available"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Stock is the stock of the variant across locations, attached by
+	// AttachStock. It is nil for variants without stock levels.
+	Stock *StockStatus `json:"stock,omitempty"`
+}
+
+// variantFields has the fields of Variant without its JSON methods.
+type variantFields Variant
+
+// MarshalJSON encodes the price like Coffee does, as a JSON number in major
+// units next to its currency.
+func (v Variant) MarshalJSON() ([]byte, error) {
+	grindUnits := v.GrindUnits
+	if grindUnits == nil {
+		grindUnits = []int16{}
+	}
+
+	return json.Marshal(struct {
+		variantFields
+		Price      json.Number `json:"price"`
+		Currency   string      `json:"currency"`
+		GrindUnits []int16     `json:"grind_units"`
+	}{
+		variantFields: variantFields(v),
+		Price:         json.Number(v.Price.String()),
+		Currency:      v.Price.Currency,
+		GrindUnits:    grindUnits,
+	})
+}
+
+// UnmarshalJSON decodes a numeric price in major units. The currency member is
+// optional and defaults to the current currency, or DefaultCurrency, so
+// callers can preset the currency of the coffee. Members missing from data
+// keep their current values, and a stock echoed back by the client is ignored.
+func (v *Variant) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*variantFields
+		Price    json.Number     `json:"price"`
+		Currency string          `json:"currency"`
+		Stock    json.RawMessage `json:"stock"`
+	}{variantFields: (*variantFields)(v)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	v.SKU = strings.TrimSpace(v.SKU)
+	v.Format = strings.ToLower(strings.TrimSpace(v.Format))
+
+	currency := strings.ToUpper(strings.TrimSpace(aux.Currency))
+	if currency == "" {
+		currency = v.Price.Currency
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	v.Price = Money{Currency: currency}
+	if aux.Price == "" {
+		return nil
+	}
+
+	price, err := ParseMoney(aux.Price.String(), currency)
+	if err != nil {
+		return ValidationErrors{"price": fmt.Sprintf("must be a decimal amount with at most %d decimal places for %s", CurrencyExponent(currency), currency)}
+	}
+	v.Price = price
+
+	return nil
+}
+
+// Validate checks the client supplied fields of a variant and returns the
+// problems found, or nil when the variant is valid.
+func (v *Variant) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+
+	errs.check(v.SKU != "", "sku", "must be provided")
+	errs.check(len(v.SKU) <= maxSKULength, "sku", fmt.Sprintf("must not be more than %d characters long", maxSKULength))
+	errs.check(skuPattern.MatchString(v.SKU), "sku", "must contain only letters, digits, dots, dashes and underscores")
+
+	errs.check(slices.Contains(VariantFormats, v.Format), "format", "must be one of "+strings.Join(VariantFormats, ", "))
+
+	errs.check(strings.TrimSpace(v.Size) != "", "size", "must be provided")
+	errs.check(utf8.RuneCountInString(v.Size) <= maxSizeLength, "size", fmt.Sprintf("must not be more than %d characters long", maxSizeLength))
+
+	switch v.Format {
+	case FormatBag:
+		errs.check(v.WeightGrams != nil, "weight_grams", "must be provided for bags")
+		errs.check(v.WeightGrams == nil || (*v.WeightGrams > 0 && *v.WeightGrams <= maxBagWeight), "weight_grams", fmt.Sprintf("must be between 1 and %d grams", maxBagWeight))
+	case FormatCup:
+		errs.check(v.WeightGrams == nil, "weight_grams", "must not be set for cups")
+		errs.check(len(v.GrindUnits) == 0, "grind_units", "must be empty for cups")
+	}
+
+	seen := make(map[int16]bool, len(v.GrindUnits))
+	for _, unit := range v.GrindUnits {
+		errs.check(!seen[unit], "grind_units", "must not contain duplicates")
+		seen[unit] = true
+	}
+
+	errs.check(v.Price.Amount > 0, "price", "must be greater than zero")
+	errs.check(IsCurrencyCode(v.Price.Currency), "currency", "must be a three-letter ISO 4217 code")
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// AttachVariants sets Variants on every coffee, loading the variants of the
+// whole page with a single lookup. Coffees without variants get an empty
+// list.
+func AttachVariants(ctx context.Context, variants VariantRepository, coffees []*Coffee) error {
+	if len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(coffees))
+	for i, coffee := range coffees {
+		ids[i] = coffee.ID
+	}
+
+	found, err := variants.Find(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, coffee := range coffees {
+		coffee.Variants = found[coffee.ID]
+		if coffee.Variants == nil {
+			coffee.Variants = []*Variant{}
+		}
+	}
+
+	return nil
+}
+
+// sortVariants orders variants the way they are listed: bags before cups,
+// lightest bag first, then by SKU.
+func sortVariants(variants []*Variant) {
+	weight := func(v *Variant) int {
+		if v.WeightGrams == nil {
+			return 0
+		}
+		return *v.WeightGrams
+	}
+
+	sort.Slice(variants, func(i, j int) bool {
+		a, b := variants[i], variants[j]
+		if a.Format != b.Format {
+			return a.Format < b.Format
+		}
+		if weight(a) != weight(b) {
+			return weight(a) < weight(b)
+		}
+		return a.SKU < b.SKU
+	})
+}
+
+// formatGrindUnits encodes units for string_to_array in SQL.
+func formatGrindUnits(units []int16) string {
+	parts := make([]string, len(units))
+	for i, unit := range units {
+		parts[i] = strconv.Itoa(int(unit))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// parseGrindUnits decodes the output of array_to_string in SQL.
+func parseGrindUnits(s string) ([]int16, error) {
+	units := []int16{}
+	if s == "" {
+		return units, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		unit, err := strconv.ParseInt(part, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grind unit %q: %w", part, err)
+		}
+		units = append(units, int16(unit))
+	}
+
+	return units, nil
+}
+
+// PostgresVariantRepository is a VariantRepository backed by the
+// coffee_variants table.
+type PostgresVariantRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresVariantRepository creates a repository using the given connection pool.
+func NewPostgresVariantRepository(db *sql.DB) *PostgresVariantRepository {
+	return &PostgresVariantRepository{db: db}
+}
+
+const variantColumns = `id, coffee_id, sku, format, size, weight_grams, price_minor, currency, array_to_string(grind_units, ','), available, created_at, updated_at`
+
+const variantOrder = `format, COALESCE(weight_grams, 0), sku`
+
+// scanVariant reads a row selected with variantColumns.
+func scanVariant(row rowScanner) (*Variant, error) {
+	var variant Variant
+	var grindUnits string
+	if err := row.Scan(
+		&variant.ID,
+		&variant.CoffeeID,
+		&variant.SKU,
+		&variant.Format,
+		&variant.Size,
+		&variant.WeightGrams,
+		&variant.Price.Amount,
+		&variant.Price.Currency,
+		&grindUnits,
+		&variant.Available,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	units, err := parseGrindUnits(grindUnits)
+	if err != nil {
+		return nil, err
+	}
+	variant.GrindUnits = units
+
+	return &variant, nil
+}
+
+// List returns the variants of a coffee, bags before cups and lightest first.
+func (r *PostgresVariantRepository) List(ctx context.Context, coffeeID string) ([]*Variant, error) {
+	variants := []*Variant{}
+	if !isUUID(coffeeID) {
+		return variants, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + variantColumns + ` FROM coffee_variants WHERE coffee_id = $1 ORDER BY ` + variantOrder
+	rows, err := r.db.QueryContext(ctx, query, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// Find returns the variants of the given coffees keyed by coffee ID, each
+// list in the order of List. Coffees without variants are absent from the map.
+func (r *PostgresVariantRepository) Find(ctx context.Context, coffeeIDs []string) (map[string][]*Variant, error) {
+	variants := make(map[string][]*Variant)
+
+	var args queryArgs
+	placeholders := make([]string, 0, len(coffeeIDs))
+	for _, id := range coffeeIDs {
+		if isUUID(id) {
+			placeholders = append(placeholders, args.add(id)+"::uuid")
+		}
+	}
+	if len(placeholders) == 0 {
+		return variants, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + variantColumns + ` FROM coffee_variants WHERE coffee_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY ` + variantOrder
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants[variant.CoffeeID] = append(variants[variant.CoffeeID], variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// Get retrieves a variant of a coffee by its ID.
+func (r *PostgresVariantRepository) Get(ctx context.Context, coffeeID, id string) (*Variant, error) {
+	if !isUUID(coffeeID) || !isUUID(id) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + variantColumns + ` FROM coffee_variants WHERE coffee_id = $1 AND id = $2`
+
+	return scanVariant(r.db.QueryRowContext(ctx, query, coffeeID, id))
+}
+
+// Create inserts a new variant. A coffee that does not exist is reported as
+// sql.ErrNoRows.
+func (r *PostgresVariantRepository) Create(ctx context.Context, variant Variant) (*Variant, error) {
+	if !isUUID(variant.CoffeeID) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO coffee_variants(coffee_id, sku, format, size, weight_grams, price_minor, currency, grind_units, available, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, string_to_array($8, ',')::smallint[], $9, $10, $10)
+        RETURNING id, created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		variant.CoffeeID,
+		variant.SKU,
+		variant.Format,
+		variant.Size,
+		variant.WeightGrams,
+		variant.Price.Amount,
+		variant.Price.Currency,
+		formatGrindUnits(variant.GrindUnits),
+		variant.Available,
+		time.Now(),
+	).Scan(&variant.ID, &variant.CreatedAt, &variant.UpdatedAt)
+
+	if err := variantWriteError(err); err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// Update overwrites the editable fields of an existing variant.
+func (r *PostgresVariantRepository) Update(ctx context.Context, variant Variant) (*Variant, error) {
+	if !isUUID(variant.CoffeeID) || !isUUID(variant.ID) {
+		return nil, sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE coffee_variants
+        SET sku = $1, format = $2, size = $3, weight_grams = $4, price_minor = $5, currency = $6,
+            grind_units = string_to_array($7, ',')::smallint[], available = $8, updated_at = $9
+        WHERE coffee_id = $10 AND id = $11
+        RETURNING created_at, updated_at
+    `
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		variant.SKU,
+		variant.Format,
+		variant.Size,
+		variant.WeightGrams,
+		variant.Price.Amount,
+		variant.Price.Currency,
+		formatGrindUnits(variant.GrindUnits),
+		variant.Available,
+		time.Now(),
+		variant.CoffeeID,
+		variant.ID,
+	).Scan(&variant.CreatedAt, &variant.UpdatedAt)
+
+	if err := variantWriteError(err); err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// Delete removes a variant of a coffee.
+func (r *PostgresVariantRepository) Delete(ctx context.Context, coffeeID, id string) error {
+	if !isUUID(coffeeID) || !isUUID(id) {
+		return sql.ErrNoRows
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM coffee_variants WHERE coffee_id = $1 AND id = $2`, coffeeID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// variantWriteError translates the constraint violations of a variant write:
+// a taken SKU is ErrDuplicateSKU and a missing coffee is sql.ErrNoRows.
+func variantWriteError(err error) error {
+	switch {
+	case hasSQLState(err, pgUniqueViolation):
+		return NewError(KindConflict, ErrDuplicateSKU)
+	case hasSQLState(err, pgForeignKeyViolation):
+		return sql.ErrNoRows
+	}
+
+	return err
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestVariantValidate(t *testing.T) {
+	t.Parallel()
+
+	grams := func(g int) *int { return &g }
+
+	t.Run("Valid Variants", func(t *testing.T) {
+		// Test a ground bag and a cup, the two formats sold.
+		variants := []Variant{
+			{SKU: "YIRG-250", Format: FormatBag, Size: "250g", WeightGrams: grams(250), Price: Money{899, "EUR"}, GrindUnits: []int16{0, 4, 6}},
+			{SKU: "YIRG-CUP-L", Format: FormatCup, Size: "large", Price: Money{420, "EUR"}},
+		}
+
+		for _, variant := range variants {
+			if errs := variant.Validate(); errs != nil {
+				t.Errorf("Validate(%s) = %v, want nil", variant.SKU, errs)
+			}
+		}
+	})
+
+	t.Run("Invalid Fields", func(t *testing.T) {
+		// Test that each broken field is reported under its own key.
+		variant := Variant{SKU: "bad sku", Format: "box", Price: Money{0, "EUR"}}
+
+		errs := variant.Validate()
+		for _, field := range []string{"sku", "format", "size", "price"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("Validate() = %v, want an error for %s", errs, field)
+			}
+		}
+	})
+
+	t.Run("Format Rules", func(t *testing.T) {
+		// Test that bags need a weight while cups take neither weight nor grind.
+		bag := Variant{SKU: "A", Format: FormatBag, Size: "1kg", Price: Money{3000, "EUR"}}
+		cup := Variant{SKU: "B", Format: FormatCup, Size: "small", WeightGrams: grams(18), GrindUnits: []int16{6}, Price: Money{300, "EUR"}}
+
+		if _, ok := bag.Validate()["weight_grams"]; !ok {
+			t.Error("Validate() accepted a bag without a weight")
+		}
+		errs := cup.Validate()
+		if _, ok := errs["weight_grams"]; !ok {
+			t.Error("Validate() accepted a cup with a weight")
+		}
+		if _, ok := errs["grind_units"]; !ok {
+			t.Error("Validate() accepted a cup with grind units")
+		}
+	})
+
+	t.Run("Duplicate Grind Units", func(t *testing.T) {
+		// Test that a grind may only be offered once.
+		variant := Variant{SKU: "A", Format: FormatBag, Size: "250g", WeightGrams: grams(250), GrindUnits: []int16{2, 2}, Price: Money{899, "EUR"}}
+
+		if _, ok := variant.Validate()["grind_units"]; !ok {
+			t.Error("Validate() accepted duplicate grind units")
+		}
+	})
+}
+
+func TestVariantJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Preset Defaults", func(t *testing.T) {
+		// Test that members missing from the body keep the preset currency and availability.
+		variant := Variant{Price: Money{Currency: "GBP"}, Available: true}
+
+		if err := json.Unmarshal([]byte(`{"sku":" yirg-500 ","format":"Bag","price":15.5}`), &variant); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if variant.SKU != "yirg-500" || variant.Format != FormatBag || variant.Price != (Money{1550, "GBP"}) || !variant.Available {
+			t.Errorf("Unmarshal() = %+v", variant)
+		}
+	})
+
+	t.Run("Encoded Price", func(t *testing.T) {
+		// Test that the price is a number next to its currency and grind units are never null.
+		data, err := json.Marshal(Variant{Format: FormatCup, Price: Money{420, "EUR"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got map[string]interface{}
+		json.Unmarshal(data, &got)
+		if got["price"] != 4.2 || got["currency"] != "EUR" || !reflect.DeepEqual(got["grind_units"], []interface{}{}) {
+			t.Errorf("Marshal() = %s", data)
+		}
+	})
+}
+
+func TestMemoryVariantRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	grams := func(g int) *int { return &g }
+
+	newStore := func(t *testing.T) (*MemoryVariantRepository, *Coffee) {
+		coffees := NewMemoryCoffeeRepository()
+		coffee, err := coffees.Create(ctx, Coffee{Name: "Yirgacheffe", Roast: "light", Price: Money{1300, "EUR"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewMemoryVariantRepository(coffees), coffee
+	}
+
+	t.Run("Listing Order", func(t *testing.T) {
+		// Test that bags come before cups, lightest first.
+		variants, coffee := newStore(t)
+		for _, variant := range []Variant{
+			{CoffeeID: coffee.ID, SKU: "CUP", Format: FormatCup},
+			{CoffeeID: coffee.ID, SKU: "1KG", Format: FormatBag, WeightGrams: grams(1000)},
+			{CoffeeID: coffee.ID, SKU: "250G", Format: FormatBag, WeightGrams: grams(250)},
+		} {
+			if _, err := variants.Create(ctx, variant); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		listed, err := variants.List(ctx, coffee.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var skus []string
+		for _, variant := range listed {
+			skus = append(skus, variant.SKU)
+		}
+		if want := []string{"250G", "1KG", "CUP"}; !reflect.DeepEqual(skus, want) {
+			t.Errorf("List() = %v, want %v", skus, want)
+		}
+	})
+
+	t.Run("Duplicate SKU", func(t *testing.T) {
+		// Test that SKUs are unique regardless of case.
+		variants, coffee := newStore(t)
+		if _, err := variants.Create(ctx, Variant{CoffeeID: coffee.ID, SKU: "YIRG-250"}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := variants.Create(ctx, Variant{CoffeeID: coffee.ID, SKU: "yirg-250"})
+		if !errors.Is(err, ErrDuplicateSKU) || KindOf(err) != KindConflict {
+			t.Errorf("Create() error = %v, want a conflict wrapping ErrDuplicateSKU", err)
+		}
+	})
+
+	t.Run("Unknown Coffee", func(t *testing.T) {
+		// Test that variants can only be added to stored coffees.
+		variants, _ := newStore(t)
+
+		if _, err := variants.Create(ctx, Variant{CoffeeID: missingCoffeeID, SKU: "X"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Create() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("Wrong Coffee", func(t *testing.T) {
+		// Test that a variant is only reachable under its own coffee.
+		variants, coffee := newStore(t)
+		variant, err := variants.Create(ctx, Variant{CoffeeID: coffee.ID, SKU: "X"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := variants.Get(ctx, missingCoffeeID, variant.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Get() error = %v, want sql.ErrNoRows", err)
+		}
+		if err := variants.Delete(ctx, missingCoffeeID, variant.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Delete() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestAttachVariants(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	coffees := NewMemoryCoffeeRepository()
+	variants := NewMemoryVariantRepository(coffees)
+
+	withVariant, _ := coffees.Create(ctx, Coffee{Name: "A"})
+	withoutVariant, _ := coffees.Create(ctx, Coffee{Name: "B"})
+	if _, err := variants.Create(ctx, Variant{CoffeeID: withVariant.ID, SKU: "A-CUP", Format: FormatCup}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AttachVariants(ctx, variants, []*Coffee{withVariant, withoutVariant}); err != nil {
+		t.Fatalf("AttachVariants() error = %v", err)
+	}
+
+	if len(withVariant.Variants) != 1 || withVariant.Variants[0].SKU != "A-CUP" {
+		t.Errorf("Variants = %v, want [A-CUP]", withVariant.Variants)
+	}
+	if withoutVariant.Variants == nil || len(withoutVariant.Variants) != 0 {
+		t.Errorf("Variants = %v, want an empty list", withoutVariant.Variants)
+	}
+}
+
+func TestPostgresVariantCreate(t *testing.T) {
+	t.Parallel()
+
+	variant := Variant{CoffeeID: testCoffeeID, SKU: "YIRG-250", Format: FormatBag, Size: "250g", Price: Money{899, "EUR"}, GrindUnits: []int16{0, 6}, Available: true}
+	insert := regexp.QuoteMeta("string_to_array($8, ',')::smallint[]")
+
+	t.Run("Grind Units", func(t *testing.T) {
+		// Test that grind units are sent as a list for string_to_array.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectQuery(insert).
+			WithArgs(testCoffeeID, "YIRG-250", FormatBag, "250g", variant.WeightGrams, int64(899), "EUR", "0,6", true, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(missingCoffeeID, variant.CreatedAt, variant.UpdatedAt))
+
+		if _, err := NewPostgresVariantRepository(db).Create(context.Background(), variant); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Duplicate SKU", func(t *testing.T) {
+		// Test that the unique index violation is reported as ErrDuplicateSKU.
+		db, mock := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectQuery(insert).WillReturnError(sqlStateError(pgUniqueViolation))
+
+		_, err := NewPostgresVariantRepository(db).Create(context.Background(), variant)
+		if !errors.Is(err, ErrDuplicateSKU) || KindOf(err) != KindConflict {
+			t.Errorf("Create() error = %v, want a conflict wrapping ErrDuplicateSKU", err)
+		}
+	})
+}
+
+func TestParseGrindUnits(t *testing.T) {
+	t.Parallel()
+
+	units, err := parseGrindUnits("0,4,6")
+	if err != nil || !reflect.DeepEqual(units, []int16{0, 4, 6}) {
+		t.Errorf("parseGrindUnits() = %v, %v", units, err)
+	}
+
+	units, err = parseGrindUnits("")
+	if err != nil || units == nil || len(units) != 0 {
+		t.Errorf("parseGrindUnits(\"\") = %v, %v, want an empty list", units, err)
+	}
+}